@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Deployed without the HTTP API's JWT authorizer, set $JWT_ISSUER and
+// $JWT_AUDIENCE (a comma separated list) for the handler to verify tokens
+// itself against the issuer's keys, fetched from $JWT_JWKS_URL or else the
+// issuer's /.well-known/jwks.json. $JWT_CLOCK_SKEW is the leeway, in seconds,
+// given to exp, nbf and iat.
+//
+// For tests and local runs $JWT_TEST_KEY_FILE names a PEM RSA private key to
+// trust instead of the issuer's keys; `meditation sign-token` signs tokens
+// with it.
+
+// JWT_CLOCK_SKEW is the leeway given unless $JWT_CLOCK_SKEW says otherwise.
+const JWT_CLOCK_SKEW = 60 * time.Second
+
+// testKeyId is the kid a TestSigner signs with unless given another.
+const testKeyId = "tempora-test"
+
+// Authenticator verifies bearer tokens in-process.
+type Authenticator struct {
+	Keys   KeySource
+	Policy TokenPolicy
+}
+
+// authenticator is nil when the HTTP API authenticates requests.
+var authenticator *Authenticator
+
+func authenticatorFromEnv() (*Authenticator, error) {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	audiences := []string{}
+	for _, audience := range strings.Split(os.Getenv("JWT_AUDIENCE"), ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			audiences = append(audiences, audience)
+		}
+	}
+	if len(audiences) == 0 {
+		return nil, errors.New("$JWT_AUDIENCE is required with $JWT_ISSUER")
+	}
+	skew := JWT_CLOCK_SKEW
+	if seconds, err := strconv.Atoi(os.Getenv("JWT_CLOCK_SKEW")); err == nil && seconds >= 0 {
+		skew = time.Duration(seconds) * time.Second
+	}
+	policy := TokenPolicy{Issuer: issuer, Audiences: audiences, ClockSkew: skew}
+
+	if path := os.Getenv("JWT_TEST_KEY_FILE"); path != "" {
+		signer, err := LoadTestSigner(path, issuer, audiences[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Authenticator{Keys: signer.Keys(), Policy: policy}, nil
+	}
+	url := os.Getenv("JWT_JWKS_URL")
+	if url == "" {
+		url = strings.TrimSuffix(issuer, "/") + "/.well-known/jwks.json"
+	}
+	return &Authenticator{Keys: NewJWKSCache(url), Policy: policy}, nil
+}
+
+// authenticate verifies the request's bearer token and passes its claims on
+// as the HTTP API's authorizer would. Only the public endpoints can be
+// called without one.
+func (a *Authenticator) authenticate(req *events.APIGatewayV2HTTPRequest, now time.Time) *events.APIGatewayV2HTTPResponse {
+	claims := map[string]string{}
+	token, ok := bearerToken(*req)
+	if ok {
+		verified, err := verifyJWT(token, a.Keys, a.Policy, now)
+		if err != nil {
+			log.Printf("rejected a token: %v", err)
+			return unauthorized("the bearer token is invalid")
+		}
+		claims = flattenClaims(verified)
+	} else if !isPublicRoute(*req) {
+		return unauthorized("a bearer token is required")
+	}
+	req.RequestContext.Authorizer = &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+		JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+			Claims: claims,
+		},
+	}
+	return nil
+}
+
+func bearerToken(req events.APIGatewayV2HTTPRequest) (string, bool) {
+	header, ok := getHeader(req, "Authorization")
+	if !ok {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
+}
+
+// isPublicRoute reports whether an endpoint needs no login, matching the
+// routes without an authorizer in serverless.yml.
+func isPublicRoute(req events.APIGatewayV2HTTPRequest) bool {
+	path := req.RequestContext.HTTP.Path
+	if !strings.HasPrefix(path, "/public/") {
+		return false
+	}
+	return req.RequestContext.HTTP.Method == "GET" || strings.HasSuffix(path, "/reports")
+}
+
+// TestSigner signs tokens with a local key, standing in for the identity
+// provider.
+type TestSigner struct {
+	Key      *rsa.PrivateKey
+	KeyId    string
+	Issuer   string
+	Audience string
+}
+
+func NewTestSigner(issuer string, audience string) (*TestSigner, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &TestSigner{Key: key, KeyId: testKeyId, Issuer: issuer, Audience: audience}, nil
+}
+
+// LoadTestSigner reads a PKCS #1 or PKCS #8 PEM RSA private key, as
+// `openssl genrsa` writes.
+func LoadTestSigner(path string, issuer string, audience string) (*TestSigner, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New(path + " is not a PEM file")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &TestSigner{Key: key, KeyId: testKeyId, Issuer: issuer, Audience: audience}, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New(path + " is not an RSA private key")
+	}
+	return &TestSigner{Key: key, KeyId: testKeyId, Issuer: issuer, Audience: audience}, nil
+}
+
+func (s *TestSigner) Keys() KeySource {
+	return StaticKeys{s.KeyId: &s.Key.PublicKey}
+}
+
+// JWKS publishes the signer's key, as an identity provider would.
+func (s *TestSigner) JWKS() JWKS {
+	return JWKS{Keys: []JWK{{
+		Kty: "RSA",
+		Kid: s.KeyId,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(s.Key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.Key.E)).Bytes()),
+	}}}
+}
+
+// Sign signs `claims` with RS256, filling in iss, aud, iat and exp, good for
+// `ttl`, unless they're set.
+func (s *TestSigner) Sign(claims map[string]interface{}, now time.Time, ttl time.Duration) (string, error) {
+	full := map[string]interface{}{
+		"iss": s.Issuer,
+		"aud": s.Audience,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for name, value := range claims {
+		full[name] = value
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": s.KeyId})
+	payload, err := json.Marshal(full)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// runSignToken prints a token signed with $JWT_TEST_KEY_FILE, e.g.
+// `meditation sign-token -sub alex -roles moderator`.
+func runSignToken(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("sign-token", flag.ContinueOnError)
+	flags.SetOutput(out)
+	sub := flags.String("sub", "", "the user id to sign in as")
+	roles := flags.String("roles", "", "a comma separated list of roles to grant")
+	ttl := flags.Duration("ttl", time.Hour, "how long the token is good for")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if *sub == "" {
+		fmt.Fprintln(out, "-sub is required")
+		flags.Usage()
+		return 2
+	}
+	path, issuer := os.Getenv("JWT_TEST_KEY_FILE"), os.Getenv("JWT_ISSUER")
+	audience := strings.TrimSpace(strings.Split(os.Getenv("JWT_AUDIENCE"), ",")[0])
+	if path == "" || issuer == "" || audience == "" {
+		fmt.Fprintln(out, "$JWT_TEST_KEY_FILE, $JWT_ISSUER and $JWT_AUDIENCE are required")
+		return 2
+	}
+
+	signer, err := LoadTestSigner(path, issuer, audience)
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+	claims := map[string]interface{}{"sub": *sub}
+	if *roles != "" {
+		claims[claimName("ROLES_CLAIM", ROLES_CLAIM)] = strings.Split(*roles, ",")
+	}
+	token, err := signer.Sign(claims, time.Now(), *ttl)
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+	fmt.Fprintln(out, token)
+	return 0
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const MAX_CITATION_FIELD_LENGTH = 200
+
+// Citation records where a meditation's text comes from.
+type Citation struct {
+	Author      string `json:"author,omitempty"`
+	Work        string `json:"work,omitempty"`
+	Section     string `json:"section,omitempty"` // e.g. "chapter 3", or "4:4-7" in scripture
+	Translation string `json:"translation,omitempty"`
+	License     string `json:"license,omitempty"`
+
+	// set when the work is a book of the Bible
+	Scripture *ScriptureReference `json:"scripture,omitempty"`
+}
+
+// ScriptureReference is a parsed reference such as "Philippians 4:4-7". A
+// zero verse means the whole chapter, a zero end chapter means the reference
+// doesn't leave its first chapter.
+type ScriptureReference struct {
+	Book       string `json:"book"`
+	Chapter    int    `json:"chapter"`
+	Verse      int    `json:"verse,omitempty"`
+	EndChapter int    `json:"endChapter,omitempty"`
+	EndVerse   int    `json:"endVerse,omitempty"`
+}
+
+var ErrInvalidCitation = errors.New("invalid citation")
+
+// the books of the Bible, including the deuterocanonical ones read in the
+// liturgy, each with its usual abbreviations
+var scriptureBooks = [][]string{
+	{"Genesis", "gen", "gn"},
+	{"Exodus", "exod", "ex"},
+	{"Leviticus", "lev", "lv"},
+	{"Numbers", "num", "nm"},
+	{"Deuteronomy", "deut", "dt"},
+	{"Joshua", "josh", "jos"},
+	{"Judges", "judg", "jgs"},
+	{"Ruth", "ru"},
+	{"1 Samuel", "1sam", "1sm"},
+	{"2 Samuel", "2sam", "2sm"},
+	{"1 Kings", "1kgs", "1kg"},
+	{"2 Kings", "2kgs", "2kg"},
+	{"1 Chronicles", "1chr", "1chron"},
+	{"2 Chronicles", "2chr", "2chron"},
+	{"Ezra", "ezr"},
+	{"Nehemiah", "neh"},
+	{"Tobit", "tob"},
+	{"Judith", "jdt"},
+	{"Esther", "esth", "est"},
+	{"1 Maccabees", "1macc", "1mc"},
+	{"2 Maccabees", "2macc", "2mc"},
+	{"Job", "jb"},
+	{"Psalms", "psalm", "ps", "pss", "psa"},
+	{"Proverbs", "prov", "prv"},
+	{"Ecclesiastes", "eccl", "eccles", "qoheleth"},
+	{"Song of Songs", "song", "songofsolomon", "canticles", "sg"},
+	{"Wisdom", "wis", "wisdomofsolomon"},
+	{"Sirach", "sir", "ecclesiasticus"},
+	{"Isaiah", "isa", "is"},
+	{"Jeremiah", "jer"},
+	{"Lamentations", "lam"},
+	{"Baruch", "bar"},
+	{"Ezekiel", "ezek", "ez"},
+	{"Daniel", "dan", "dn"},
+	{"Hosea", "hos"},
+	{"Joel", "jl"},
+	{"Amos", "am"},
+	{"Obadiah", "obad", "ob"},
+	{"Jonah", "jon"},
+	{"Micah", "mic"},
+	{"Nahum", "nah"},
+	{"Habakkuk", "hab"},
+	{"Zephaniah", "zeph"},
+	{"Haggai", "hag"},
+	{"Zechariah", "zech"},
+	{"Malachi", "mal"},
+	{"Matthew", "matt", "mt"},
+	{"Mark", "mk", "mar"},
+	{"Luke", "lk"},
+	{"John", "jn", "jhn"},
+	{"Acts", "act"},
+	{"Romans", "rom"},
+	{"1 Corinthians", "1cor"},
+	{"2 Corinthians", "2cor"},
+	{"Galatians", "gal"},
+	{"Ephesians", "eph"},
+	{"Philippians", "phil", "php"},
+	{"Colossians", "col"},
+	{"1 Thessalonians", "1thess", "1thes", "1th"},
+	{"2 Thessalonians", "2thess", "2thes", "2th"},
+	{"1 Timothy", "1tim", "1tm"},
+	{"2 Timothy", "2tim", "2tm"},
+	{"Titus", "tit", "ti"},
+	{"Philemon", "phlm", "philem"},
+	{"Hebrews", "heb"},
+	{"James", "jas"},
+	{"1 Peter", "1pet", "1pt"},
+	{"2 Peter", "2pet", "2pt"},
+	{"1 John", "1jn", "1jhn"},
+	{"2 John", "2jn", "2jhn"},
+	{"3 John", "3jn", "3jhn"},
+	{"Jude", "jud"},
+	{"Revelation", "rev", "apocalypse", "apoc"},
+}
+
+// scriptureBookAliases maps every compacted name and abbreviation (see
+// compactBookName) to the book's name.
+var scriptureBookAliases = func() map[string]string {
+	aliases := make(map[string]string)
+	for _, names := range scriptureBooks {
+		aliases[compactBookName(names[0])] = names[0]
+		for _, alias := range names[1:] {
+			aliases[alias] = names[0]
+		}
+	}
+	return aliases
+}()
+
+// compactBookName lowercases a book name, spells a leading ordinal as a digit
+// and drops spaces and dots, so "I Cor." and "1 Corinthians" become "1cor"
+// and "1corinthians".
+func compactBookName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for prefix, digit := range map[string]string{
+		"first ": "1", "second ": "2", "third ": "3",
+		"i ": "1", "ii ": "2", "iii ": "3",
+	} {
+		if strings.HasPrefix(name, prefix) {
+			name = digit + strings.TrimPrefix(name, prefix)
+			break
+		}
+	}
+	return strings.NewReplacer(" ", "", ".", "").Replace(name)
+}
+
+// book, chapter[:verse][-[chapter:]verse]
+var scriptureReferencePattern = regexp.MustCompile(`^(.*[A-Za-z.])\s*(\d+)(?::(\d+))?(?:\s*[-–]\s*(\d+)(?::(\d+))?)?$`)
+
+// parseScriptureReference parses references like "Philippians 4:4",
+// "Phil 4:4-7", "1 John 3:16-4:2" or "Ps 23".
+func parseScriptureReference(s string) (ScriptureReference, bool) {
+	match := scriptureReferencePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return ScriptureReference{}, false
+	}
+	book, ok := scriptureBookAliases[compactBookName(match[1])]
+	if !ok {
+		return ScriptureReference{}, false
+	}
+	number := func(i int) int {
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	ref := ScriptureReference{Book: book, Chapter: number(2), Verse: number(3)}
+	switch {
+	case match[5] != "":
+		// across chapters, 3:16-4:2
+		ref.EndChapter = number(4)
+		ref.EndVerse = number(5)
+	case match[4] != "" && match[3] != "":
+		// within a chapter, 4:4-7
+		ref.EndVerse = number(4)
+	case match[4] != "":
+		// whole chapters, 23-24
+		ref.EndChapter = number(4)
+	}
+	if ref.Chapter < 1 || (match[3] != "" && ref.Verse < 1) {
+		return ScriptureReference{}, false
+	}
+	if ref.EndChapter != 0 && ref.EndChapter < ref.Chapter {
+		return ScriptureReference{}, false
+	}
+	if ref.EndVerse != 0 && ref.EndChapter == 0 && ref.EndVerse < ref.Verse {
+		return ScriptureReference{}, false
+	}
+	if ref.EndChapter == ref.Chapter && ref.EndVerse == 0 {
+		ref.EndChapter = 0
+	}
+	return ref, true
+}
+
+// Section is the reference without its book, e.g. "4:4-7".
+func (r ScriptureReference) Section() string {
+	section := strconv.Itoa(r.Chapter)
+	if r.Verse != 0 {
+		section += ":" + strconv.Itoa(r.Verse)
+	}
+	switch {
+	case r.EndChapter != 0 && r.EndVerse != 0:
+		section += fmt.Sprintf("-%d:%d", r.EndChapter, r.EndVerse)
+	case r.EndChapter != 0:
+		section += "-" + strconv.Itoa(r.EndChapter)
+	case r.EndVerse != 0:
+		section += "-" + strconv.Itoa(r.EndVerse)
+	}
+	return section
+}
+
+func (r ScriptureReference) String() string {
+	return r.Book + " " + r.Section()
+}
+
+// normalizeCitation trims the citation and, when the work and section (or
+// the section alone) are a scripture reference, parses it and spells the book
+// the usual way. An empty citation is nil.
+func normalizeCitation(c *Citation) (*Citation, error) {
+	if c == nil {
+		return nil, nil
+	}
+	normalized := Citation{
+		Author:      strings.TrimSpace(c.Author),
+		Work:        strings.TrimSpace(c.Work),
+		Section:     strings.TrimSpace(c.Section),
+		Translation: strings.TrimSpace(c.Translation),
+		License:     strings.TrimSpace(c.License),
+	}
+	if normalized == (Citation{}) {
+		return nil, nil
+	}
+	for _, field := range []string{normalized.Author, normalized.Work, normalized.Section, normalized.Translation, normalized.License} {
+		if len(field) > MAX_CITATION_FIELD_LENGTH {
+			return nil, fmt.Errorf("%w: fields are limited to %d characters", ErrInvalidCitation, MAX_CITATION_FIELD_LENGTH)
+		}
+	}
+	if normalized.Author == "" && normalized.Work == "" && normalized.Section == "" {
+		return nil, fmt.Errorf("%w: an author, work or scripture reference is required", ErrInvalidCitation)
+	}
+
+	if ref, ok := parseScriptureReference(normalized.Work + " " + normalized.Section); ok {
+		normalized.Work = ref.Book
+		normalized.Section = ref.Section()
+		normalized.Scripture = &ref
+	} else if normalized.Work == "" && normalized.Section != "" {
+		return nil, fmt.Errorf("%w: %q is not a scripture reference, so a work is required", ErrInvalidCitation, normalized.Section)
+	}
+	return &normalized, nil
+}
+
+// citationIndexKeys lists the author and work partitions a public meditation
+// belongs in, with the names to show for them.
+func citationIndexKeys(public bool, c *Citation) map[string]string {
+	keys := make(map[string]string)
+	if !public || c == nil {
+		return keys
+	}
+	if author := normalizeTag(c.Author); author != "" {
+		keys["author#"+author] = c.Author
+	}
+	if work := normalizeTag(c.Work); work != "" {
+		keys["work#"+work] = c.Work
+	}
+	return keys
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseScriptureReference(t *testing.T) {
+	cases := map[string]ScriptureReference{
+		"Philippians 4:4":   {Book: "Philippians", Chapter: 4, Verse: 4},
+		"Phil. 4:4-7":       {Book: "Philippians", Chapter: 4, Verse: 4, EndVerse: 7},
+		"1 John 3:16-4:2":   {Book: "1 John", Chapter: 3, Verse: 16, EndChapter: 4, EndVerse: 2},
+		"I Cor 13":          {Book: "1 Corinthians", Chapter: 13},
+		"Ps 23–24":          {Book: "Psalms", Chapter: 23, EndChapter: 24},
+		"Song of Songs 2:1": {Book: "Song of Songs", Chapter: 2, Verse: 1},
+		"sirach 2:1-3:1":    {Book: "Sirach", Chapter: 2, Verse: 1, EndChapter: 3, EndVerse: 1},
+	}
+	for s, expected := range cases {
+		ref, ok := parseScriptureReference(s)
+		if !ok {
+			t.Errorf("could not parse %q", s)
+			continue
+		}
+		if diff := deep.Equal(ref, expected); diff != nil {
+			t.Errorf("%q: %v", s, diff)
+		}
+	}
+
+	for _, s := range []string{"On Prayer 153", "Philippians", "Phil 4:7-4", "Phil 0:1", "Isaiah 40:1-39:2"} {
+		if ref, ok := parseScriptureReference(s); ok {
+			t.Errorf("expected %q not to parse, got %+v", s, ref)
+		}
+	}
+
+	ref, _ := parseScriptureReference("1 Jn 3:16-4:2")
+	if ref.String() != "1 John 3:16-4:2" {
+		t.Errorf("unexpected string %q", ref.String())
+	}
+}
+
+func TestNormalizeCitation(t *testing.T) {
+	citation, err := normalizeCitation(&Citation{Work: " Phil ", Section: "4:4", Translation: "RSV"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Citation{
+		Work:        "Philippians",
+		Section:     "4:4",
+		Translation: "RSV",
+		Scripture:   &ScriptureReference{Book: "Philippians", Chapter: 4, Verse: 4},
+	}
+	if diff := deep.Equal(citation, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// a reference on its own is enough
+	citation, _ = normalizeCitation(&Citation{Section: "Philippians 4:4"})
+	if citation.Work != "Philippians" || citation.Section != "4:4" {
+		t.Errorf("unexpected citation %+v", citation)
+	}
+
+	citation, _ = normalizeCitation(&Citation{Author: "Evagrius Ponticus", Work: "On Prayer", Section: "153", License: "public-domain"})
+	if citation.Scripture != nil || citation.Work != "On Prayer" {
+		t.Errorf("unexpected citation %+v", citation)
+	}
+
+	citation, err = normalizeCitation(&Citation{Translation: " "})
+	if citation != nil || err != nil {
+		t.Errorf("expected an empty citation to be nil, got %+v, %v", citation, err)
+	}
+	_, err = normalizeCitation(&Citation{Section: "chapter 3"})
+	if !errors.Is(err, ErrInvalidCitation) {
+		t.Errorf("expected %v, got %v", ErrInvalidCitation, err)
+	}
+	_, err = normalizeCitation(&Citation{License: "CC-BY-4.0"})
+	if !errors.Is(err, ErrInvalidCitation) {
+		t.Errorf("expected %v, got %v", ErrInvalidCitation, err)
+	}
+
+	keys := citationIndexKeys(true, &Citation{Author: "Evagrius Ponticus", Work: "On Prayer"})
+	if diff := deep.Equal(keys, map[string]string{"author#evagrius-ponticus": "Evagrius Ponticus", "work#on-prayer": "On Prayer"}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// a cached token this close to expiring is refreshed rather than used
+const tokenExpirySlack = time.Minute
+
+// CachedToken is an environment's access token, kept between runs.
+type CachedToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+func (t CachedToken) valid(now time.Time) bool {
+	return t.AccessToken != "" && now.Add(tokenExpirySlack).Before(t.ExpiresAt)
+}
+
+func tokenPath(envName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tempora", "tokens", envName+".json"), nil
+}
+
+func loadToken(envName string) (CachedToken, error) {
+	token := CachedToken{}
+	p, err := tokenPath(envName)
+	if err != nil {
+		return token, err
+	}
+	raw, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return token, nil
+	}
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(raw, &token)
+	return token, err
+}
+
+func saveToken(envName string, token CachedToken) error {
+	p, err := tokenPath(envName)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(p, token)
+}
+
+// tokenResponse is Auth0's /oauth/token response, or its error.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (r tokenResponse) cached(now time.Time) CachedToken {
+	return CachedToken{
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+		ExpiresAt:    now.Add(time.Duration(r.ExpiresIn) * time.Second),
+	}
+}
+
+// postForm posts a form to the environment's Auth0 domain and decodes the
+// JSON reply, which Auth0 sends for errors too.
+func postForm(env Environment, endpoint string, form url.Values, reply interface{}) error {
+	resp, err := http.PostForm("https://"+env.AuthDomain+endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+func requestToken(env Environment, form url.Values) (tokenResponse, error) {
+	reply := tokenResponse{}
+	err := postForm(env, "/oauth/token", form, &reply)
+	if err != nil {
+		return reply, err
+	}
+	if reply.Error != "" {
+		return reply, errors.New(reply.Error + ": " + reply.ErrorDescription)
+	}
+	return reply, nil
+}
+
+// accessToken returns a usable token for the environment: $TEMPORA_TOKEN if
+// set, else the cached one, refreshing it if it has expired.
+func accessToken(envName string, env Environment) (string, error) {
+	if token := os.Getenv("TEMPORA_TOKEN"); token != "" {
+		return token, nil
+	}
+	cached, err := loadToken(envName)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if cached.valid(now) {
+		return cached.AccessToken, nil
+	}
+	if cached.RefreshToken == "" {
+		return "", fmt.Errorf("not logged in to %s; run tempora login --env %s", envName, envName)
+	}
+
+	reply, err := requestToken(env, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {env.ClientID},
+		"refresh_token": {cached.RefreshToken},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not refresh the token for %s, run tempora login: %w", envName, err)
+	}
+	refreshed := reply.cached(now)
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = cached.RefreshToken
+	}
+	return refreshed.AccessToken, saveToken(envName, refreshed)
+}
+
+// runLogin signs in with Auth0's device flow: it prints a code to confirm in
+// the browser and waits. With --client-credentials it signs in as the
+// application instead, using $TEMPORA_CLIENT_SECRET, as scripts and CI do.
+func runLogin(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("login", out)
+	clientCredentials := fs.Bool("client-credentials", false, "sign in as the application with $TEMPORA_CLIENT_SECRET")
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	envName, env, err := config.environment(opts.env)
+	if err != nil {
+		return err
+	}
+
+	var reply tokenResponse
+	if *clientCredentials {
+		secret := os.Getenv("TEMPORA_CLIENT_SECRET")
+		if secret == "" {
+			return errors.New("TEMPORA_CLIENT_SECRET is not set")
+		}
+		reply, err = requestToken(env, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {env.ClientID},
+			"client_secret": {secret},
+			"audience":      {env.Audience},
+		})
+	} else {
+		reply, err = deviceLogin(env, out)
+	}
+	if err != nil {
+		return err
+	}
+	err = saveToken(envName, reply.cached(time.Now()))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Logged in to "+envName+".")
+	return nil
+}
+
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Error                   string `json:"error"`
+	ErrorDescription        string `json:"error_description"`
+}
+
+func deviceLogin(env Environment, out io.Writer) (tokenResponse, error) {
+	code := deviceCodeResponse{}
+	err := postForm(env, "/oauth/device/code", url.Values{
+		"client_id": {env.ClientID},
+		"audience":  {env.Audience},
+		"scope":     {"openid profile offline_access"},
+	}, &code)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	if code.Error != "" {
+		return tokenResponse{}, errors.New(code.Error + ": " + code.ErrorDescription)
+	}
+	fmt.Fprintf(out, "Open %s and confirm the code %s.\n", code.VerificationURIComplete, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		reply := tokenResponse{}
+		err := postForm(env, "/oauth/token", url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"client_id":   {env.ClientID},
+			"device_code": {code.DeviceCode},
+		}, &reply)
+		if err != nil {
+			return reply, err
+		}
+		switch reply.Error {
+		case "":
+			return reply, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return reply, errors.New(strings.TrimSpace(reply.Error + ": " + reply.ErrorDescription))
+		}
+	}
+	return tokenResponse{}, errors.New("the code expired before it was confirmed")
+}
+
+func runLogout(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("logout", out)
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	envName, _, err := config.environment(opts.env)
+	if err != nil {
+		return err
+	}
+	p, err := tokenPath(envName)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
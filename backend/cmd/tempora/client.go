@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// uploadContentTypes are the files the API accepts, by extension.
+var uploadContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// Client calls the API of one environment.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// APIError is a response outside 2xx.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// newClient builds a client for the environment chosen by `opts`, signed in
+// with its cached token.
+func newClient(opts *options) (*Client, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	envName, env, err := config.environment(opts.env)
+	if err != nil {
+		return nil, err
+	}
+	token, err := accessToken(envName, env)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{BaseURL: env.APIURL, Token: token, HTTP: http.DefaultClient}, nil
+}
+
+// Response is a successful API response.
+type Response struct {
+	Body []byte
+	ETag string
+}
+
+// do sends a request with `body` marshalled as JSON (or raw, if it's
+// already []byte) and returns the response, or an *APIError.
+func (c *Client) do(method string, path string, body interface{}, headers map[string]string) (Response, error) {
+	var reader io.Reader
+	if raw, ok := body.([]byte); ok {
+		reader = bytes.NewReader(raw)
+	} else if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return Response{}, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Response{}, &APIError{Status: resp.StatusCode, Message: errorMessage(raw)}
+	}
+	return Response{Body: raw, ETag: resp.Header.Get("ETag")}, nil
+}
+
+// errorMessage digs the message out of an error body, which is
+// {"error": "..."} from the API, {"message": "..."} from API Gateway.
+func errorMessage(body []byte) string {
+	parsed := struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(body, &parsed) == nil {
+		if parsed.Error != "" {
+			return parsed.Error
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// getJSON GETs `path` into `v`, returning the raw body and ETag too.
+func (c *Client) getJSON(path string, v interface{}) (Response, error) {
+	resp, err := c.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return resp, err
+	}
+	return resp, json.Unmarshal(resp.Body, v)
+}
+
+// patch applies a merge patch to `path`, guarded by the version the caller
+// last read.
+func (c *Client) patch(path string, etag string, fields map[string]interface{}) (Response, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return Response{}, err
+	}
+	return c.do(http.MethodPatch, path, raw, map[string]string{
+		"Content-Type": mergePatchContentType,
+		"If-Match":     etag,
+	})
+}
+
+// deleteCurrent deletes `path` at its current version.
+func (c *Client) deleteCurrent(path string) error {
+	resp, err := c.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodDelete, path, nil, map[string]string{"If-Match": resp.ETag})
+	return err
+}
+
+// upload puts a local file in the upload bucket through a presigned URL
+// from /upload-url and returns its upload key.
+func (c *Client) upload(file string) (string, error) {
+	contentType, ok := uploadContentTypes[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return "", errors.New(file + " is not an mp3, m4a, jpeg or png")
+	}
+	return c.uploadAs(file, contentType)
+}
+
+func (c *Client) uploadAs(file string, contentType string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	presigned := struct {
+		URL string `json:"uploadUrl"`
+		Key string `json:"uploadKey"`
+	}{}
+	_, err = c.getJSON("/upload-url", &presigned)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, presigned.URL, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("upload of %s failed: %s %s", file, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return presigned.Key, nil
+}
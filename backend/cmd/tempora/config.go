@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Environment is one deployment of the API (dev, prod, a self-hosted
+// install) and the Auth0 application used to sign in to it.
+type Environment struct {
+	APIURL     string `json:"apiUrl"`
+	AuthDomain string `json:"authDomain"` // e.g. tenant.us.auth0.com
+	Audience   string `json:"audience"`
+	ClientID   string `json:"clientId"`
+}
+
+type Config struct {
+	Default      string                 `json:"default"`
+	Environments map[string]Environment `json:"environments"`
+}
+
+var errNoEnvironment = errors.New("no environment is configured; run tempora config set")
+
+// configPath is $TEMPORA_CONFIG, or config.json in the user's config
+// directory.
+func configPath() (string, error) {
+	if p := os.Getenv("TEMPORA_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tempora", "config.json"), nil
+}
+
+func loadConfig() (Config, error) {
+	config := Config{Environments: map[string]Environment{}}
+	p, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	raw, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(raw, &config)
+	if config.Environments == nil {
+		config.Environments = map[string]Environment{}
+	}
+	return config, err
+}
+
+func saveConfig(config Config) error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(p, config)
+}
+
+// writePrivateFile writes `v` as JSON readable only by the user, since the
+// config and token cache hold credentials.
+func writePrivateFile(p string, v interface{}) error {
+	err := os.MkdirAll(filepath.Dir(p), 0700)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, raw, 0600)
+}
+
+// environment returns the environment named `name`, or the default one.
+func (c Config) environment(name string) (string, Environment, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return "", Environment{}, errNoEnvironment
+	}
+	env, ok := c.Environments[name]
+	if !ok {
+		return "", Environment{}, fmt.Errorf("no environment named %q; run tempora config set --env %s", name, name)
+	}
+	return name, env, nil
+}
+
+func runConfig(args []string, out io.Writer) error {
+	return subcommand("config", map[string]func([]string, io.Writer) error{
+		"set":  runConfigSet,
+		"list": runConfigList,
+	}, []string{"set", "list"}, args, out)
+}
+
+// runConfigSet adds or changes an environment. The first one added becomes
+// the default.
+func runConfigSet(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("config set", out)
+	apiURL := fs.String("api-url", "", "the API's base URL")
+	authDomain := fs.String("auth-domain", "", "the Auth0 domain")
+	audience := fs.String("audience", "", "the API's Auth0 audience")
+	clientID := fs.String("client-id", "", "the Auth0 client id")
+	makeDefault := fs.Bool("default", false, "make this the default environment")
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if opts.env == "" {
+		return errors.New("--env is required")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	env := config.Environments[opts.env]
+	if isSet(fs, "api-url") {
+		env.APIURL = strings.TrimSuffix(*apiURL, "/")
+	}
+	if isSet(fs, "auth-domain") {
+		env.AuthDomain = *authDomain
+	}
+	if isSet(fs, "audience") {
+		env.Audience = *audience
+	}
+	if isSet(fs, "client-id") {
+		env.ClientID = *clientID
+	}
+	config.Environments[opts.env] = env
+	if *makeDefault || config.Default == "" {
+		config.Default = opts.env
+	}
+	return saveConfig(config)
+}
+
+func runConfigList(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("config list", out)
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(out, config)
+	}
+
+	names := []string{}
+	for name := range config.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENV\tAPI URL\tAUTH DOMAIN\tDEFAULT")
+	for _, name := range names {
+		env := config.Environments[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, env.APIURL, env.AuthDomain, yesNo(name == config.Default))
+	}
+	return w.Flush()
+}
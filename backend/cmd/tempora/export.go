@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type exportResponse struct {
+	DownloadURL string    `json:"downloadUrl"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Meditations int       `json:"meditationCount"`
+	Sequences   int       `json:"sequenceCount"`
+}
+
+// runExport saves an archive of everything the user created, from
+// GET /me/export, to --file (by default tempora-export-<date>.zip).
+func runExport(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("export", out)
+	file := fs.String("file", "tempora-export-"+time.Now().Format("2006-01-02")+".zip", "where to save the archive")
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	export := exportResponse{}
+	_, err = client.getJSON("/me/export", &export)
+	if err != nil {
+		return err
+	}
+
+	// the link is presigned, so it goes without our token
+	resp, err := client.HTTP.Get(export.DownloadURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("could not download the archive: " + resp.Status)
+	}
+	f, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		return printJSON(out, map[string]interface{}{
+			"file":            *file,
+			"meditationCount": export.Meditations,
+			"sequenceCount":   export.Sequences,
+		})
+	}
+	_, err = fmt.Fprintf(out, "Saved %d meditations and %d sequences to %s.\n", export.Meditations, export.Sequences, *file)
+	return err
+}
+
+type importReport struct {
+	DryRun  bool `json:"dryRun"`
+	Created int  `json:"created"`
+	Failed  int  `json:"failed"`
+	Items   []struct {
+		Type     string   `json:"type"`
+		SourceID string   `json:"sourceId"`
+		ID       string   `json:"id"`
+		Name     string   `json:"name"`
+		Result   string   `json:"result"`
+		Error    string   `json:"error"`
+		Missing  []string `json:"missingMeditationIds"`
+	} `json:"items"`
+}
+
+// runImport recreates the contents of an export archive, from this or another
+// environment, through POST /me/import. Everything gets a new id.
+func runImport(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("import", out)
+	dryRun := fs.Bool("dry-run", false, "check the archive without importing anything")
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora import <archive.zip> [--dry-run]")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	uploadKey, err := client.uploadAs(positional[0], "application/zip")
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(http.MethodPost, "/me/import", map[string]interface{}{
+		"uploadKey": uploadKey,
+		"dryRun":    *dryRun,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	report := importReport{}
+	err = json.Unmarshal(resp.Body, &report)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		err = printRaw(out, resp.Body)
+	} else {
+		err = printImportReport(out, report)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d items could not be imported", report.Failed)
+	}
+	return nil
+}
+
+func printImportReport(out io.Writer, report importReport) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RESULT\tTYPE\tFROM\tID\tNAME\tERROR")
+	for _, item := range report.Items {
+		problem := item.Error
+		if len(item.Missing) > 0 {
+			problem = "left out " + strings.Join(item.Missing, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", item.Result, item.Type, item.SourceID, item.ID, truncate(item.Name, 40), problem)
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	if report.DryRun {
+		_, err = fmt.Fprintf(out, "Would import %d items.\n", report.Created)
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Imported %d items.\n", report.Created)
+	return err
+}
@@ -0,0 +1,152 @@
+// Command tempora is a command-line client for the Tempora API.
+//
+//	tempora config set --env dev --api-url https://... --auth-domain tenant.auth0.com --audience ... --client-id ...
+//	tempora login
+//	tempora meditations create --name "On Prayer 1" --text "..." --audio 001.mp3
+//	tempora meditations list -o json
+//
+// Every command takes --env to pick the environment (otherwise the default,
+// or $TEMPORA_ENV) and --output/-o table|json.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, out io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{"config", "show or change the environments", runConfig},
+		{"login", "sign in to an environment", runLogin},
+		{"logout", "forget an environment's cached token", runLogout},
+		{"meditations", "list, get, create, update or delete meditations", runMeditations},
+		{"sequences", "list, get, create, update or delete sequences", runSequences},
+		{"export", "download an archive of everything you created", runExport},
+		{"import", "recreate everything in an export archive", runImport},
+	}
+}
+
+var errUsage = errors.New("usage")
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "usage: tempora <command> [arguments]")
+	fmt.Fprintln(out)
+	for _, c := range commands() {
+		fmt.Fprintf(out, "  %-12s %s\n", c.name, c.summary)
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, out io.Writer, errOut io.Writer) int {
+	if len(args) == 0 {
+		usage(errOut)
+		return 2
+	}
+	for _, c := range commands() {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], out)
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintln(errOut, "tempora: "+err.Error())
+			return 1
+		}
+		return 0
+	}
+	usage(errOut)
+	return 2
+}
+
+// options are the flags every command takes.
+type options struct {
+	env    string
+	output string
+}
+
+func newFlagSet(name string, out io.Writer) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet("tempora "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	opts := &options{}
+	fs.StringVar(&opts.env, "env", os.Getenv("TEMPORA_ENV"), "the environment to use")
+	fs.StringVar(&opts.output, "output", "table", "table or json")
+	fs.StringVar(&opts.output, "o", "table", "shorthand for --output")
+	return fs, opts
+}
+
+// parseArgs parses flags wherever they appear among the positional
+// arguments, so `tempora meditations get <id> -o json` works.
+func parseArgs(fs *flag.FlagSet, opts *options, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return nil, errors.New("--output must be table or json")
+	}
+	return positional, nil
+}
+
+// stringList is a flag that may be repeated, e.g. --tag a --tag b.
+type stringList []string
+
+func (l *stringList) String() string {
+	return fmt.Sprint([]string(*l))
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// isSet reports whether the flag `name` was given.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// subcommand dispatches `tempora <group> <verb> ...` to `verbs`.
+func subcommand(group string, verbs map[string]func([]string, io.Writer) error, order []string, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		if verb, ok := verbs[args[0]]; ok {
+			return verb(args[1:], out)
+		}
+	}
+	fmt.Fprintf(out, "usage: tempora %s <", group)
+	for i, v := range order {
+		if i > 0 {
+			fmt.Fprint(out, "|")
+		}
+		fmt.Fprint(out, v)
+	}
+	fmt.Fprintln(out, "> [arguments]")
+	return errUsage
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAPI records what the CLI sends and answers like the API would.
+type fakeAPI struct {
+	t        *testing.T
+	server   *httptest.Server
+	uploads  map[string]string // upload key -> content type
+	requests []string
+	lastBody map[string]interface{}
+	lastIf   string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{t: t, uploads: map[string]string{}}
+	api.server = httptest.NewServer(http.HandlerFunc(api.handle))
+	return api
+}
+
+func (api *fakeAPI) handle(w http.ResponseWriter, r *http.Request) {
+	api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+	if !strings.HasPrefix(r.URL.Path, "/s3/") && r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"Unauthorized"}`))
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	api.lastBody = nil
+	json.Unmarshal(body, &api.lastBody)
+	api.lastIf = r.Header.Get("If-Match")
+
+	meditation := `{"_id":"m1","_version":3,"name":"On Prayer 1","text":"If you wish...","durationSeconds":42,"isPublic":true}`
+	switch r.Method + " " + r.URL.Path {
+	case "GET /upload-url":
+		w.Write([]byte(`{"uploadUrl":"` + api.server.URL + `/s3/upload/1","uploadKey":"upload/1"}`))
+	case "PUT /s3/upload/1":
+		api.uploads["upload/1"] = r.Header.Get("Content-Type")
+	case "POST /meditations":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(meditation))
+	case "GET /meditations/m1", "PATCH /meditations/m1":
+		w.Header().Set("ETag", `"3"`)
+		w.Write([]byte(meditation))
+	case "DELETE /meditations/m1":
+		w.WriteHeader(http.StatusNoContent)
+	case "GET /me/export":
+		w.Write([]byte(`{"downloadUrl":"` + api.server.URL + `/s3/exports/1.zip","meditationCount":1,"sequenceCount":0}`))
+	case "GET /s3/exports/1.zip":
+		w.Write([]byte("PK archive"))
+	case "POST /me/import":
+		w.Write([]byte(`{"created":1,"failed":1,"items":[` +
+			`{"type":"meditation","sourceId":"m1","id":"m9","name":"On Prayer 1","result":"created"},` +
+			`{"type":"meditation","sourceId":"m2","name":"On Prayer 2","result":"failed","error":"audio/m2.mp3: file is not an mp4 or mp3"}]}`))
+	case "GET /meditations":
+		w.Write([]byte(`[` + meditation + `]`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"No meditation with id x was found"}`))
+	}
+}
+
+// setupCLI points the CLI at the fake API, signed in with a fixed token.
+func setupCLI(t *testing.T, api *fakeAPI) {
+	dir, err := ioutil.TempDir("", "tempora")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.Setenv("TEMPORA_CONFIG", filepath.Join(dir, "config.json"))
+	os.Setenv("TEMPORA_TOKEN", "test-token")
+	os.Setenv("TEMPORA_ENV", "")
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPORA_CONFIG")
+		os.Unsetenv("TEMPORA_TOKEN")
+	})
+
+	code := run([]string{"config", "set", "--env", "test", "--api-url", api.server.URL + "/"}, ioutil.Discard, ioutil.Discard)
+	if code != 0 {
+		t.Fatalf("config set exited %d", code)
+	}
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	code := run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestMeditationCommands(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	setupCLI(t, api)
+
+	audio := filepath.Join(os.Getenv("TEMPORA_CONFIG"), "..", "001.mp3")
+	ioutil.WriteFile(audio, []byte("ID3"), 0600)
+
+	t.Run("create uploads the audio then creates the meditation", func(t *testing.T) {
+		code, out, errOut := runCLI("meditations", "create", "--name", "On Prayer 1", "--text", "If you wish...", "--audio", audio, "--public", "--tag", "evagrius")
+		if code != 0 {
+			t.Fatalf("exited %d: %s", code, errOut)
+		}
+		if api.uploads["upload/1"] != "audio/mpeg" {
+			t.Errorf("Expected an mp3 upload, got %+v", api.uploads)
+		}
+		if api.lastBody["uploadKey"] != "upload/1" || api.lastBody["isPublic"] != true {
+			t.Errorf("Unexpected create body %+v", api.lastBody)
+		}
+		if !strings.Contains(out, "On Prayer 1") || !strings.Contains(out, "0:42") {
+			t.Errorf("Unexpected output %q", out)
+		}
+	})
+
+	t.Run("update patches only the given fields at the current version", func(t *testing.T) {
+		code, _, errOut := runCLI("meditations", "update", "m1", "--public=false", "-o", "json")
+		if code != 0 {
+			t.Fatalf("exited %d: %s", code, errOut)
+		}
+		if len(api.lastBody) != 1 || api.lastBody["isPublic"] != false {
+			t.Errorf("Unexpected patch %+v", api.lastBody)
+		}
+		if api.lastIf != `"3"` {
+			t.Errorf("Expected If-Match \"3\", got %q", api.lastIf)
+		}
+	})
+
+	t.Run("delete sends the current version", func(t *testing.T) {
+		code, _, errOut := runCLI("meditations", "delete", "m1")
+		if code != 0 {
+			t.Fatalf("exited %d: %s", code, errOut)
+		}
+		if api.requests[len(api.requests)-1] != "DELETE /meditations/m1" || api.lastIf != `"3"` {
+			t.Errorf("Unexpected requests %+v, If-Match %q", api.requests, api.lastIf)
+		}
+	})
+
+	t.Run("list prints a table", func(t *testing.T) {
+		code, out, _ := runCLI("meditations", "list")
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if code != 0 || len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.HasPrefix(lines[1], "m1") {
+			t.Errorf("Unexpected table %q", out)
+		}
+	})
+
+	t.Run("API errors are reported", func(t *testing.T) {
+		code, _, errOut := runCLI("meditations", "get", "x")
+		if code != 1 || !strings.Contains(errOut, "404 Not Found: No meditation with id x was found") {
+			t.Errorf("Unexpected error %d %q", code, errOut)
+		}
+	})
+
+	t.Run("files the API won't take are refused before uploading", func(t *testing.T) {
+		code, _, errOut := runCLI("meditations", "create", "--name", "n", "--text", "t", "--audio", "notes.txt")
+		if code != 1 || !strings.Contains(errOut, "not an mp3") {
+			t.Errorf("Unexpected error %d %q", code, errOut)
+		}
+	})
+}
+
+func TestConfig(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	setupCLI(t, api)
+
+	runCLI("config", "set", "--env", "prod", "--api-url", "https://api.example.com")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Default != "test" || config.Environments["test"].APIURL != api.server.URL {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	name, env, err := config.environment("prod")
+	if err != nil || name != "prod" || env.APIURL != "https://api.example.com" {
+		t.Errorf("Unexpected environment %s %+v %v", name, env, err)
+	}
+	_, _, err = config.environment("staging")
+	if err == nil {
+		t.Error("Expected an unknown environment to be an error")
+	}
+}
+
+func TestCachedToken(t *testing.T) {
+	now := time.Now()
+	if !(CachedToken{AccessToken: "t", ExpiresAt: now.Add(time.Hour)}).valid(now) {
+		t.Error("Expected a fresh token to be valid")
+	}
+	if (CachedToken{AccessToken: "t", ExpiresAt: now.Add(30 * time.Second)}).valid(now) {
+		t.Error("Expected a token about to expire to be refreshed")
+	}
+}
+
+func TestExport(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	setupCLI(t, api)
+
+	file := filepath.Join(os.Getenv("TEMPORA_CONFIG"), "..", "export.zip")
+	code, out, errOut := runCLI("export", "--file", file)
+	if code != 0 {
+		t.Fatalf("exited %d: %s", code, errOut)
+	}
+	saved, _ := ioutil.ReadFile(file)
+	if string(saved) != "PK archive" {
+		t.Errorf("Unexpected archive %q", saved)
+	}
+	if !strings.Contains(out, "Saved 1 meditations and 0 sequences") {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestImport(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	setupCLI(t, api)
+
+	file := filepath.Join(os.Getenv("TEMPORA_CONFIG"), "..", "export.zip")
+	ioutil.WriteFile(file, []byte("PK archive"), 0600)
+	code, out, errOut := runCLI("import", file)
+	if api.uploads["upload/1"] != "application/zip" || api.lastBody["uploadKey"] != "upload/1" {
+		t.Errorf("Expected the archive to be uploaded then imported, got %+v %+v", api.uploads, api.lastBody)
+	}
+	if !strings.Contains(out, "m9") || !strings.Contains(out, "Imported 1 items.") {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if code != 1 || !strings.Contains(errOut, "1 items could not be imported") {
+		t.Errorf("Expected the failure to be reported, got %d %q", code, errOut)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+func runMeditations(args []string, out io.Writer) error {
+	return subcommand("meditations", map[string]func([]string, io.Writer) error{
+		"list":   listMeditations,
+		"get":    getMeditation,
+		"create": createMeditation,
+		"update": updateMeditation,
+		"delete": deleteMeditation,
+	}, []string{"list", "get", "create", "update", "delete"}, args, out)
+}
+
+func listMeditations(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("meditations list", out)
+	public := fs.Bool("public", false, "list everyone's public meditations instead of your own")
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	path := "/meditations"
+	if *public {
+		path = "/public/meditations"
+	}
+	meditations := []meditation{}
+	resp, err := client.getJSON(path, &meditations)
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printRaw(out, resp.Body)
+	}
+	return printMeditationTable(out, meditations)
+}
+
+func getMeditation(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("meditations get", out)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora meditations get <id>")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	m := meditation{}
+	resp, err := client.getJSON("/meditations/"+url.PathEscape(positional[0]), &m)
+	if err != nil {
+		return err
+	}
+	return printMeditationResponse(out, opts, resp)
+}
+
+func printMeditationResponse(out io.Writer, opts *options, resp Response) error {
+	if opts.output == "json" {
+		return printRaw(out, resp.Body)
+	}
+	m := meditation{}
+	err := json.Unmarshal(resp.Body, &m)
+	if err != nil {
+		return err
+	}
+	return printMeditation(out, m)
+}
+
+// meditationFlags are the fields create and update share.
+type meditationFlags struct {
+	name       *string
+	text       *string
+	audio      *string
+	public     *bool
+	tags       stringList
+	categories stringList
+}
+
+func addMeditationFlags(fs *flag.FlagSet) *meditationFlags {
+	f := &meditationFlags{
+		name:   fs.String("name", "", "the meditation's name"),
+		text:   fs.String("text", "", "the meditation's text"),
+		audio:  fs.String("audio", "", "an mp3 or m4a of the text, 90 seconds at most"),
+		public: fs.Bool("public", false, "share the meditation publicly"),
+	}
+	fs.Var(&f.tags, "tag", "a tag, may be repeated")
+	fs.Var(&f.categories, "category", "a category, may be repeated")
+	return f
+}
+
+func createMeditation(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("meditations create", out)
+	f := addMeditationFlags(fs)
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if *f.name == "" || *f.text == "" || *f.audio == "" {
+		return errors.New("--name, --text and --audio are required")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	uploadKey, err := client.upload(*f.audio)
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(http.MethodPost, "/meditations", map[string]interface{}{
+		"uploadKey":  uploadKey,
+		"name":       *f.name,
+		"text":       *f.text,
+		"isPublic":   *f.public,
+		"tags":       []string(f.tags),
+		"categories": []string(f.categories),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return printMeditationResponse(out, opts, resp)
+}
+
+// updateMeditation changes only the fields given, at the version it has just
+// read.
+func updateMeditation(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("meditations update", out)
+	f := addMeditationFlags(fs)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora meditations update <id> [--name ...] [--text ...] [--audio ...] [--public=true|false]")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	path := "/meditations/" + url.PathEscape(positional[0])
+	current, err := client.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{}
+	if isSet(fs, "name") {
+		fields["name"] = *f.name
+	}
+	if isSet(fs, "text") {
+		fields["text"] = *f.text
+	}
+	if isSet(fs, "public") {
+		fields["isPublic"] = *f.public
+	}
+	if isSet(fs, "tag") {
+		fields["tags"] = []string(f.tags)
+	}
+	if isSet(fs, "category") {
+		fields["categories"] = []string(f.categories)
+	}
+	if *f.audio != "" {
+		uploadKey, err := client.upload(*f.audio)
+		if err != nil {
+			return err
+		}
+		fields["uploadKey"] = uploadKey
+	}
+	if len(fields) == 0 {
+		return errors.New("nothing to update")
+	}
+	resp, err := client.patch(path, current.ETag, fields)
+	if err != nil {
+		return err
+	}
+	return printMeditationResponse(out, opts, resp)
+}
+
+func deleteMeditation(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("meditations delete", out)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora meditations delete <id>")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	return client.deleteCurrent("/meditations/" + url.PathEscape(positional[0]))
+}
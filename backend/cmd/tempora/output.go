@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The API's resources, as much of them as the tables show. JSON output
+// prints the API's response as is.
+
+type meditation struct {
+	ID         string    `json:"_id"`
+	UpdatedAt  time.Time `json:"_updatedAt"`
+	Version    int64     `json:"_version"`
+	Name       string    `json:"name"`
+	Text       string    `json:"text"`
+	URL        string    `json:"audioUrl"`
+	Duration   int64     `json:"durationSeconds"`
+	Public     bool      `json:"isPublic"`
+	AuthorName string    `json:"authorName"`
+	Tags       []string  `json:"tags"`
+	Categories []string  `json:"categories"`
+}
+
+type sequence struct {
+	ID            string       `json:"_id"`
+	UpdatedAt     time.Time    `json:"_updatedAt"`
+	Version       int64        `json:"_version"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	ImageURL      string       `json:"imageUrl"`
+	Public        bool         `json:"isPublic"`
+	Meditations   []meditation `json:"meditations"`
+	TotalDuration int64        `json:"totalDurationSeconds"`
+	Tags          []string     `json:"tags"`
+	Categories    []string     `json:"categories"`
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(raw))
+	return err
+}
+
+// printRaw pretty-prints a JSON response body.
+func printRaw(out io.Writer, body []byte) error {
+	var indented bytes.Buffer
+	err := json.Indent(&indented, body, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, indented.String())
+	return err
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func formatDuration(seconds int64) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// truncate shortens `s` to `n` runes for a table cell.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func printMeditationTable(out io.Writer, meditations []meditation) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDURATION\tPUBLIC\tUPDATED")
+	for _, m := range meditations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.ID, truncate(m.Name, 40), formatDuration(m.Duration), yesNo(m.Public), m.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func printMeditation(out io.Writer, m meditation) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", m.ID)
+	fmt.Fprintf(w, "Name\t%s\n", m.Name)
+	fmt.Fprintf(w, "Public\t%s\n", yesNo(m.Public))
+	fmt.Fprintf(w, "Duration\t%s\n", formatDuration(m.Duration))
+	fmt.Fprintf(w, "Audio\t%s\n", m.URL)
+	if len(m.Tags) > 0 {
+		fmt.Fprintf(w, "Tags\t%s\n", strings.Join(m.Tags, ", "))
+	}
+	if len(m.Categories) > 0 {
+		fmt.Fprintf(w, "Categories\t%s\n", strings.Join(m.Categories, ", "))
+	}
+	fmt.Fprintf(w, "Version\t%d\n", m.Version)
+	fmt.Fprintf(w, "Updated\t%s\n", m.UpdatedAt.Local().Format(time.RFC1123))
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "\n%s\n", m.Text)
+	return err
+}
+
+func printSequenceTable(out io.Writer, sequences []sequence) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tMEDITATIONS\tPUBLIC\tUPDATED")
+	for _, s := range sequences {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.ID, truncate(s.Name, 40), len(s.Meditations), yesNo(s.Public), s.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func printSequence(out io.Writer, s sequence) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", s.ID)
+	fmt.Fprintf(w, "Name\t%s\n", s.Name)
+	fmt.Fprintf(w, "Description\t%s\n", truncate(s.Description, 80))
+	fmt.Fprintf(w, "Public\t%s\n", yesNo(s.Public))
+	fmt.Fprintf(w, "Duration\t%s\n", formatDuration(s.TotalDuration))
+	fmt.Fprintf(w, "Version\t%d\n", s.Version)
+	fmt.Fprintf(w, "Updated\t%s\n", s.UpdatedAt.Local().Format(time.RFC1123))
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	if len(s.Meditations) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tID\tNAME\tDURATION")
+	for i, m := range s.Meditations {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, m.ID, truncate(m.Name, 40), formatDuration(m.Duration))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+func runSequences(args []string, out io.Writer) error {
+	return subcommand("sequences", map[string]func([]string, io.Writer) error{
+		"list":   listSequences,
+		"get":    getSequence,
+		"create": createSequence,
+		"update": updateSequence,
+		"delete": deleteSequence,
+	}, []string{"list", "get", "create", "update", "delete"}, args, out)
+}
+
+func listSequences(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("sequences list", out)
+	public := fs.Bool("public", false, "list everyone's public sequences instead of your own")
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	path := "/sequences"
+	if *public {
+		path = "/public/sequences"
+	}
+	sequences := []sequence{}
+	resp, err := client.getJSON(path, &sequences)
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printRaw(out, resp.Body)
+	}
+	return printSequenceTable(out, sequences)
+}
+
+func getSequence(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("sequences get", out)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora sequences get <id>")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(http.MethodGet, "/sequences/"+url.PathEscape(positional[0]), nil, nil)
+	if err != nil {
+		return err
+	}
+	return printSequenceResponse(out, opts, resp)
+}
+
+func printSequenceResponse(out io.Writer, opts *options, resp Response) error {
+	if opts.output == "json" {
+		return printRaw(out, resp.Body)
+	}
+	s := sequence{}
+	err := json.Unmarshal(resp.Body, &s)
+	if err != nil {
+		return err
+	}
+	return printSequence(out, s)
+}
+
+// sequenceFlags are the fields create and update share.
+type sequenceFlags struct {
+	name        *string
+	description *string
+	image       *string
+	public      *bool
+	meditations stringList
+	tags        stringList
+	categories  stringList
+}
+
+func addSequenceFlags(fs *flag.FlagSet) *sequenceFlags {
+	f := &sequenceFlags{
+		name:        fs.String("name", "", "the sequence's name"),
+		description: fs.String("description", "", "the sequence's description"),
+		image:       fs.String("image", "", "a jpeg or png cover image"),
+		public:      fs.Bool("public", false, "share the sequence publicly"),
+	}
+	fs.Var(&f.meditations, "meditation", "a meditation id, repeated in order")
+	fs.Var(&f.tags, "tag", "a tag, may be repeated")
+	fs.Var(&f.categories, "category", "a category, may be repeated")
+	return f
+}
+
+func createSequence(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("sequences create", out)
+	f := addSequenceFlags(fs)
+	_, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if *f.name == "" || *f.description == "" || *f.image == "" {
+		return errors.New("--name, --description and --image are required")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	uploadKey, err := client.upload(*f.image)
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(http.MethodPost, "/sequences", map[string]interface{}{
+		"uploadKey":     uploadKey,
+		"name":          *f.name,
+		"description":   *f.description,
+		"isPublic":      *f.public,
+		"meditationIds": []string(f.meditations),
+		"tags":          []string(f.tags),
+		"categories":    []string(f.categories),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return printSequenceResponse(out, opts, resp)
+}
+
+// updateSequence changes only the fields given, at the version it has just
+// read. --meditation replaces the whole list.
+func updateSequence(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("sequences update", out)
+	f := addSequenceFlags(fs)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora sequences update <id> [--name ...] [--description ...] [--image ...] [--meditation <id>]... [--public=true|false]")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	path := "/sequences/" + url.PathEscape(positional[0])
+	current, err := client.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{}
+	if isSet(fs, "name") {
+		fields["name"] = *f.name
+	}
+	if isSet(fs, "description") {
+		fields["description"] = *f.description
+	}
+	if isSet(fs, "public") {
+		fields["isPublic"] = *f.public
+	}
+	if isSet(fs, "meditation") {
+		fields["meditationIds"] = []string(f.meditations)
+		fields["steps"] = nil
+	}
+	if isSet(fs, "tag") {
+		fields["tags"] = []string(f.tags)
+	}
+	if isSet(fs, "category") {
+		fields["categories"] = []string(f.categories)
+	}
+	if *f.image != "" {
+		uploadKey, err := client.upload(*f.image)
+		if err != nil {
+			return err
+		}
+		fields["uploadKey"] = uploadKey
+	}
+	if len(fields) == 0 {
+		return errors.New("nothing to update")
+	}
+	resp, err := client.patch(path, current.ETag, fields)
+	if err != nil {
+		return err
+	}
+	return printSequenceResponse(out, opts, resp)
+}
+
+func deleteSequence(args []string, out io.Writer) error {
+	fs, opts := newFlagSet("sequences delete", out)
+	positional, err := parseArgs(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: tempora sequences delete <id>")
+	}
+	client, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	return client.deleteCurrent("/sequences/" + url.PathEscape(positional[0]))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	MAX_BATCH_GET_ITEMS_SIZE  = 100
+	MAX_BATCH_WRITE_ITEM_SIZE = 25
+)
+
+// BatchExecutor issues BatchGetItem/BatchWriteItem requests against a single
+// table. Requests are split into chunks the API will accept, chunks are run
+// with bounded concurrency, and any UnprocessedKeys/UnprocessedItems (or
+// failed calls) are retried with exponential backoff and full jitter.
+type BatchExecutor struct {
+	svc         dynamodbiface.DynamoDBAPI
+	tableName   string
+	MaxRetries  int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Concurrency int
+
+	sleep func(time.Duration)
+}
+
+func NewBatchExecutor(svc dynamodbiface.DynamoDBAPI, tableName string) BatchExecutor {
+	return BatchExecutor{
+		svc:         svc,
+		tableName:   tableName,
+		MaxRetries:  8,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Concurrency: 4,
+		sleep:       time.Sleep,
+	}
+}
+
+// BatchError aggregates the errors from every chunk that could not be
+// completed.
+type BatchError struct {
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "batch operation failed: " + strings.Join(msgs, "; ")
+}
+
+// Is lets errors.Is match against any of the aggregated errors.
+func (e *BatchError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before retry number `attempt` (0-based), chosen
+// uniformly from [0, min(MaxDelay, BaseDelay * 2^attempt)).
+func (e BatchExecutor) backoff(attempt int) time.Duration {
+	ceiling := e.MaxDelay
+	if attempt < 30 && e.BaseDelay<<uint(attempt) < ceiling {
+		ceiling = e.BaseDelay << uint(attempt)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// runChunks calls fn for each chunk index with at most Concurrency calls in
+// flight, collecting the non-nil errors into a BatchError.
+func (e BatchExecutor) runChunks(chunkCount int, fn func(idx int) error) error {
+	concurrency := e.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, chunkCount)
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < chunkCount; idx++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[idx] = fn(idx)
+		}(idx)
+	}
+	wg.Wait()
+
+	batchErr := &BatchError{}
+	for _, err := range errs {
+		if err != nil {
+			batchErr.Errors = append(batchErr.Errors, err)
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// GetItems fetches every key, returning the items in no particular order.
+// Keys that don't exist in the table are simply absent from the result.
+func (e BatchExecutor) GetItems(keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	chunks := [][]map[string]*dynamodb.AttributeValue{}
+	for start := 0; start < len(keys); start += MAX_BATCH_GET_ITEMS_SIZE {
+		end := start + MAX_BATCH_GET_ITEMS_SIZE
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunks = append(chunks, keys[start:end])
+	}
+
+	results := make([][]map[string]*dynamodb.AttributeValue, len(chunks))
+	err := e.runChunks(len(chunks), func(idx int) error {
+		items, err := e.getChunk(chunks[idx])
+		results[idx] = items
+		return err
+	})
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, r := range results {
+		items = append(items, r...)
+	}
+	return items, err
+}
+
+func (e BatchExecutor) getChunk(keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	pending := keys
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > e.MaxRetries {
+			if lastErr != nil {
+				return items, lastErr
+			}
+			return items, &UnprocessedError{Count: len(pending)}
+		}
+		if attempt > 0 {
+			e.sleep(e.backoff(attempt - 1))
+		}
+
+		resp, err := e.svc.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				e.tableName: {
+					Keys: pending,
+				},
+			},
+		})
+		if err != nil {
+			if !isRetryableBatchError(err) {
+				return items, err
+			}
+			lastErr = err
+			continue
+		}
+		lastErr = nil
+		items = append(items, resp.Responses[e.tableName]...)
+
+		pending = nil
+		if unprocessed, ok := resp.UnprocessedKeys[e.tableName]; ok && unprocessed != nil {
+			pending = unprocessed.Keys
+		}
+	}
+	return items, nil
+}
+
+// WriteItems issues every put/delete request.
+func (e BatchExecutor) WriteItems(requests []*dynamodb.WriteRequest) error {
+	chunks := [][]*dynamodb.WriteRequest{}
+	for start := 0; start < len(requests); start += MAX_BATCH_WRITE_ITEM_SIZE {
+		end := start + MAX_BATCH_WRITE_ITEM_SIZE
+		if end > len(requests) {
+			end = len(requests)
+		}
+		chunks = append(chunks, requests[start:end])
+	}
+
+	return e.runChunks(len(chunks), func(idx int) error {
+		return e.writeChunk(chunks[idx])
+	})
+}
+
+func (e BatchExecutor) writeChunk(requests []*dynamodb.WriteRequest) error {
+	pending := requests
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > e.MaxRetries {
+			if lastErr != nil {
+				return lastErr
+			}
+			return &UnprocessedError{Count: len(pending)}
+		}
+		if attempt > 0 {
+			e.sleep(e.backoff(attempt - 1))
+		}
+
+		resp, err := e.svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				e.tableName: pending,
+			},
+		})
+		if err != nil {
+			if !isRetryableBatchError(err) {
+				return err
+			}
+			lastErr = err
+			continue
+		}
+		lastErr = nil
+		pending = resp.UnprocessedItems[e.tableName]
+	}
+	return nil
+}
+
+// UnprocessedError is returned when DynamoDB keeps handing back unprocessed
+// keys/items after every retry has been used up.
+type UnprocessedError struct {
+	Count int
+}
+
+func (e *UnprocessedError) Error() string {
+	return "dynamodb left " + strconv.Itoa(e.Count) + " item(s) unprocessed after all retries"
+}
+
+// isRetryableBatchError reports whether a failed batch call is worth retrying.
+// Malformed requests and missing tables will fail the same way every time.
+func isRetryableBatchError(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "ValidationException", dynamodb.ErrCodeResourceNotFoundException:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// flakyDynamo hands back half of every request as unprocessed for the first
+// `flakyCalls` calls, then processes everything.
+type flakyDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	mu         sync.Mutex
+	calls      int
+	flakyCalls int
+	err        error
+	written    int
+}
+
+func (f *flakyDynamo) nextCall() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.calls
+}
+
+func (f *flakyDynamo) BatchGetItem(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	call := f.nextCall()
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]*dynamodb.AttributeValue{},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}
+	for table, ka := range in.RequestItems {
+		keys := ka.Keys
+		if call <= f.flakyCalls && len(keys) > 1 {
+			out.UnprocessedKeys[table] = &dynamodb.KeysAndAttributes{Keys: keys[len(keys)/2:]}
+			keys = keys[:len(keys)/2]
+		}
+		out.Responses[table] = keys
+	}
+	return out, nil
+}
+
+func (f *flakyDynamo) BatchWriteItem(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	call := f.nextCall()
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]*dynamodb.WriteRequest{},
+	}
+	for table, reqs := range in.RequestItems {
+		if len(reqs) > MAX_BATCH_WRITE_ITEM_SIZE {
+			return nil, errors.New("too many write requests in one batch")
+		}
+		if call <= f.flakyCalls && len(reqs) > 1 {
+			out.UnprocessedItems[table] = reqs[len(reqs)/2:]
+			reqs = reqs[:len(reqs)/2]
+		}
+		f.mu.Lock()
+		f.written += len(reqs)
+		f.mu.Unlock()
+	}
+	return out, nil
+}
+
+func testExecutor(svc dynamodbiface.DynamoDBAPI) BatchExecutor {
+	e := NewBatchExecutor(svc, "table")
+	e.sleep = func(time.Duration) {}
+	return e
+}
+
+func buildKeys(count int) []map[string]*dynamodb.AttributeValue {
+	keys := make([]map[string]*dynamodb.AttributeValue, count)
+	for i := range keys {
+		keys[i] = map[string]*dynamodb.AttributeValue{
+			"pk": {S: aws.String("med#" + strconv.Itoa(i))},
+		}
+	}
+	return keys
+}
+
+func TestBatchExecutor(t *testing.T) {
+	t.Run("Get retries unprocessed keys", func(t *testing.T) {
+		svc := &flakyDynamo{flakyCalls: 3}
+		items, err := testExecutor(svc).GetItems(buildKeys(250))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(items) != 250 {
+			t.Errorf("Expected %d items, got %d", 250, len(items))
+		}
+	})
+
+	t.Run("Write retries unprocessed items", func(t *testing.T) {
+		svc := &flakyDynamo{flakyCalls: 5}
+		keys := buildKeys(110)
+		requests := make([]*dynamodb.WriteRequest, len(keys))
+		for i, k := range keys {
+			requests[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: k}}
+		}
+		err := testExecutor(svc).WriteItems(requests)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if svc.written != 110 {
+			t.Errorf("Expected %d items written, got %d", 110, svc.written)
+		}
+	})
+
+	t.Run("Gives up after MaxRetries", func(t *testing.T) {
+		svc := &flakyDynamo{flakyCalls: 1000}
+		e := testExecutor(svc)
+		e.MaxRetries = 2
+		e.Concurrency = 1
+		_, err := e.GetItems(buildKeys(8))
+		if err == nil {
+			t.Error("Expected an error, got nil")
+		}
+		if svc.calls != 3 {
+			t.Errorf("Expected %d calls, got %d", 3, svc.calls)
+		}
+	})
+
+	t.Run("Aggregates errors from every chunk", func(t *testing.T) {
+		svc := &flakyDynamo{err: awserr.New("ValidationException", "bad key", nil)}
+		_, err := testExecutor(svc).GetItems(buildKeys(250))
+		batchErr, ok := err.(*BatchError)
+		if !ok {
+			t.Errorf("Expected a *BatchError, got %v", err)
+			return
+		}
+		if len(batchErr.Errors) != 3 {
+			t.Errorf("Expected %d errors, got %d", 3, len(batchErr.Errors))
+		}
+		if svc.calls != 3 {
+			t.Errorf("Expected non-retryable errors not to be retried, got %d calls", svc.calls)
+		}
+	})
+
+	t.Run("Backoff stays under MaxDelay", func(t *testing.T) {
+		e := testExecutor(nil)
+		for attempt := 0; attempt < 64; attempt++ {
+			if d := e.backoff(attempt); d < 0 || d >= e.MaxDelay {
+				t.Errorf("attempt %d: delay %s out of range", attempt, d)
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ForkSequence saves `fork`, a copy of the sequence `sourceId`, and adds one
+// to the original's forkCount in the same transaction. The count is kept on
+// the original's record but outside its versioned fields, so forking never
+// invalidates the author's If-Match.
+func (store DynamoMeditationStore) ForkSequence(sourceId string, fork Sequence) error {
+	fork.ForkedFrom = sourceId
+	count := &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:           aws.String(store.tableName),
+			Key:                 sequenceKey(sourceId),
+			UpdateExpression:    aws.String("ADD #forkCount :one"),
+			ConditionExpression: aws.String("attribute_exists(#pk)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pk":        aws.String("pk"),
+				"#forkCount": aws.String("forkCount"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":one": {
+					N: aws.String("1"),
+				},
+			},
+		},
+	}
+	err := store.saveSequence(fork, count)
+	if isConditionalCheckFailure(err) {
+		// the original was deleted in the meantime; the fork stands on its own
+		return store.SaveSequence(fork)
+	}
+	return err
+}
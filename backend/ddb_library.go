@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// A user's library of bookmarked public meditations and sequences is kept in
+// their partition. Each entry is `fav#<savedAt>#med#<id>` or
+// `fav#<savedAt>#seq#<id>`, savedAt in zero-padded unix nanoseconds, so the
+// library lists most recently saved first. A marker, `saved#med#<id>` or
+// `saved#seq#<id>`, names the entry for the item so it's saved only once and
+// can be found again to remove it.
+
+const (
+	LibraryMeditation = "meditation"
+	LibrarySequence   = "sequence"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+type LibraryRecord struct {
+	Pk        string `dynamodbav:"pk"`
+	Sk        string `dynamodbav:"sk"`
+	Type      string `dynamodbav:"type"`
+	UpdatedAt string `dynamodbav:"lastUpdated"`
+	ItemType  string `dynamodbav:"itemType"`
+	ItemID    string `dynamodbav:"itemId"`
+}
+
+type LibraryMarkerRecord struct {
+	Pk    string `dynamodbav:"pk"`
+	Sk    string `dynamodbav:"sk"`
+	Type  string `dynamodbav:"type"`
+	Entry string `dynamodbav:"entry"` // the entry's sk
+}
+
+func libraryItemSk(itemType string, itemId string) string {
+	if itemType == LibrarySequence {
+		return "seq#" + itemId
+	}
+	return "med#" + itemId
+}
+
+func libraryEntrySk(itemType string, itemId string, savedAt time.Time) string {
+	return fmt.Sprintf("fav#%019d#%s", savedAt.UnixNano(), libraryItemSk(itemType, itemId))
+}
+
+func libraryMarkerKey(userId string, itemType string, itemId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(userKey(userId)),
+		},
+		"sk": {
+			S: aws.String("saved#" + libraryItemSk(itemType, itemId)),
+		},
+	}
+}
+
+// AddToLibrary saves the item to the user's library. Saving an item already
+// there leaves it where it is.
+func (store DynamoMeditationStore) AddToLibrary(userId string, itemType string, itemId string) error {
+	now := time.Now().UTC()
+	entry, err := dynamodbattribute.MarshalMap(LibraryRecord{
+		Pk:        userKey(userId),
+		Sk:        libraryEntrySk(itemType, itemId, now),
+		Type:      "fav",
+		UpdatedAt: now.Format(time.RFC3339),
+		ItemType:  itemType,
+		ItemID:    itemId,
+	})
+	if err != nil {
+		return err
+	}
+	marker, err := dynamodbattribute.MarshalMap(LibraryMarkerRecord{
+		Pk:    userKey(userId),
+		Sk:    *libraryMarkerKey(userId, itemType, itemId)["sk"].S,
+		Type:  "saved",
+		Entry: *entry["sk"].S,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = store.transactWithRetry(store.batch(), []*dynamodb.TransactWriteItem{
+		{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(store.tableName),
+				Item:                marker,
+				ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+				ExpressionAttributeNames: map[string]*string{
+					"#pk": aws.String("pk"),
+				},
+			},
+		},
+		{
+			Put: &dynamodb.Put{
+				TableName: aws.String(store.tableName),
+				Item:      entry,
+			},
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return nil
+	}
+	return err
+}
+
+func (store DynamoMeditationStore) RemoveFromLibrary(userId string, itemType string, itemId string) error {
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(store.tableName),
+		Key:            libraryMarkerKey(userId, itemType, itemId),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Item == nil {
+		return nil
+	}
+	marker := LibraryMarkerRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &marker)
+	if err != nil {
+		return err
+	}
+
+	err = store.transactWithRetry(store.batch(), []*dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				TableName:           aws.String(store.tableName),
+				Key:                 libraryMarkerKey(userId, itemType, itemId),
+				ConditionExpression: aws.String("#entry = :entry"),
+				ExpressionAttributeNames: map[string]*string{
+					"#entry": aws.String("entry"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":entry": {
+						S: aws.String(marker.Entry),
+					},
+				},
+			},
+		},
+		{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(store.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"pk": {
+						S: aws.String(userKey(userId)),
+					},
+					"sk": {
+						S: aws.String(marker.Entry),
+					},
+				},
+			},
+		},
+	})
+	// someone else removed it first
+	if isConditionalCheckFailure(err) {
+		return nil
+	}
+	return err
+}
+
+// ListLibrary returns up to `limit` library entries, most recently saved
+// first and optionally of a single item type, and a token for the next page
+// ("" on the last one).
+func (store DynamoMeditationStore) ListLibrary(userId string, itemType string, limit int64, pageToken string) ([]LibraryRecord, string, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(userKey(userId)),
+			},
+			":prefix": {
+				S: aws.String("fav#"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if itemType != "" {
+		params.FilterExpression = aws.String("#itemType = :itemType")
+		params.ExpressionAttributeNames["#itemType"] = aws.String("itemType")
+		params.ExpressionAttributeValues[":itemType"] = &dynamodb.AttributeValue{
+			S: aws.String(itemType),
+		}
+	}
+	if pageToken != "" {
+		sk, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || !strings.HasPrefix(string(sk), "fav#") {
+			return []LibraryRecord{}, "", ErrInvalidPageToken
+		}
+		params.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String(userKey(userId)),
+			},
+			"sk": {
+				S: aws.String(string(sk)),
+			},
+		}
+	}
+
+	// the limit counts entries read before the filter, so keep reading
+	// until the page is full
+	records := []LibraryRecord{}
+	for {
+		params.Limit = aws.Int64(limit - int64(len(records)))
+		resp, err := store.svc.Query(params)
+		if err != nil {
+			return []LibraryRecord{}, "", err
+		}
+		page := []LibraryRecord{}
+		err = dynamodbattribute.UnmarshalListOfMaps(resp.Items, &page)
+		if err != nil {
+			return []LibraryRecord{}, "", err
+		}
+		records = append(records, page...)
+
+		sk, ok := resp.LastEvaluatedKey["sk"]
+		if !ok || sk.S == nil {
+			return records, "", nil
+		}
+		if int64(len(records)) >= limit {
+			return records, base64.RawURLEncoding.EncodeToString([]byte(*sk.S)), nil
+		}
+		params.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ModerationItem is a meditation or sequence awaiting a moderator's decision.
+type ModerationItem struct {
+	Type        string      `json:"type"` // meditation or sequence
+	ID          string      `json:"_id"`
+	Name        string      `json:"name"`
+	UserId      string      `json:"_userId"`
+	SubmittedAt time.Time   `json:"submittedAt"`
+	Meditation  *Meditation `json:"meditation,omitempty"`
+	Sequence    *Sequence   `json:"sequence,omitempty"`
+}
+
+// ListModerationQueue lists the published and scheduled items awaiting
+// review, longest waiting first.
+func (store DynamoMeditationStore) ListModerationQueue() ([]ModerationItem, error) {
+	items := []ModerationItem{}
+	for _, pppk := range []string{reviewPppk, scheduledPppk} {
+		params := &dynamodb.QueryInput{
+			TableName:              aws.String(store.tableName),
+			IndexName:              aws.String("gs3"),
+			KeyConditionExpression: aws.String("#pppk = :pppk"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pppk": aws.String("pppk"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":pppk": {
+					S: aws.String(pppk),
+				},
+			},
+		}
+		var unmarshalErr error
+		err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			for _, item := range page.Items {
+				if item["type"] == nil {
+					continue
+				}
+				switch aws.StringValue(item["type"].S) {
+				case "med":
+					record := MeditationRecord{}
+					unmarshalErr = dynamodbattribute.UnmarshalMap(item, &record)
+					m := record.toMeditation()
+					if unmarshalErr == nil && awaitingReview(m.Status, m.Moderation) {
+						items = append(items, ModerationItem{Type: LibraryMeditation, ID: m.ID, Name: m.Name, UserId: m.UserId, SubmittedAt: m.UpdatedAt, Meditation: &m})
+					}
+				case "seq":
+					record := SequenceRecord{}
+					unmarshalErr = dynamodbattribute.UnmarshalMap(item, &record)
+					s := record.toSequence()
+					if unmarshalErr == nil && awaitingReview(s.Status, s.Moderation) {
+						items = append(items, ModerationItem{Type: LibrarySequence, ID: s.ID, Name: s.Name, UserId: s.UserId, SubmittedAt: s.UpdatedAt, Sequence: &s})
+					}
+				}
+				if unmarshalErr != nil {
+					return false
+				}
+			}
+			return true
+		})
+		if err == nil {
+			err = unmarshalErr
+		}
+		if err != nil {
+			return []ModerationItem{}, err
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].SubmittedAt.Before(items[j].SubmittedAt)
+	})
+	return items, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/segmentio/ksuid"
+)
+
+// Notifications live in the user's own partition under
+// `notification#<ksuid>`, so they sort by when they were sent, and TTL
+// deletes them once NOTIFICATION_RETENTION_DAYS have passed.
+
+const NOTIFICATION_RETENTION_DAYS = 90
+
+const NotificationModeration = "moderation"
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type Notification struct {
+	ID        string    `json:"_id"`
+	UserId    string    `json:"_userId"`
+	CreatedAt time.Time `json:"_createdAt"`
+
+	Type     string `json:"type"`
+	ItemType string `json:"itemType"` // meditation or sequence
+	ItemID   string `json:"itemId"`
+	ItemName string `json:"itemName"`
+	Result   string `json:"result,omitempty"` // for moderation, approved or rejected
+	Reason   string `json:"reason,omitempty"`
+}
+
+type NotificationRecord struct {
+	Pk           string       `dynamodbav:"pk"`
+	Sk           string       `dynamodbav:"sk"`
+	Type         string       `dynamodbav:"type"`
+	ExpiresAt    int64        `dynamodbav:"expiresAt"`
+	Notification Notification `dynamodbav:"notification"`
+}
+
+func notificationKey(userId string, notificationId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(userKey(userId)),
+		},
+		"sk": {
+			S: aws.String("notification#" + notificationId),
+		},
+	}
+}
+
+// SaveNotification sends `n` to its user, filling in its id and CreatedAt.
+func (store DynamoMeditationStore) SaveNotification(n Notification) (Notification, error) {
+	id, err := ksuid.NewRandomWithTime(n.CreatedAt)
+	if err != nil {
+		return Notification{}, err
+	}
+	n.ID = id.String()
+	n.CreatedAt = n.CreatedAt.UTC()
+	item, err := dynamodbattribute.MarshalMap(&NotificationRecord{
+		Pk:           userKey(n.UserId),
+		Sk:           "notification#" + n.ID,
+		Type:         "notification",
+		ExpiresAt:    n.CreatedAt.Add(NOTIFICATION_RETENTION_DAYS * 24 * time.Hour).Unix(),
+		Notification: n,
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+	_, err = store.svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(store.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+	return n, nil
+}
+
+// ListNotifications returns the user's notifications, newest first.
+func (store DynamoMeditationStore) ListNotifications(userId string) ([]Notification, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :notification)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(userKey(userId)),
+			},
+			":notification": {
+				S: aws.String("notification#"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	notifications := []Notification{}
+	now := time.Now().Unix()
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []NotificationRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		if unmarshalErr != nil {
+			return false
+		}
+		for _, r := range records {
+			// TTL can be a day or two behind
+			if r.ExpiresAt > now {
+				notifications = append(notifications, r.Notification)
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return []Notification{}, err
+	}
+	return notifications, nil
+}
+
+func (store DynamoMeditationStore) DeleteNotification(userId string, notificationId string) error {
+	_, err := store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(store.tableName),
+		Key:                 notificationKey(userId, notificationId),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrNotificationNotFound
+	}
+	return err
+}
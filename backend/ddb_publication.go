@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// listScheduledKeys returns the pks of every scheduled meditation and
+// sequence whose publishAt has passed.
+func (store DynamoMeditationStore) listScheduledKeys(now time.Time) ([]string, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		IndexName:              aws.String("gs3"),
+		KeyConditionExpression: aws.String("#pppk = :scheduled"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pppk": aws.String("pppk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":scheduled": {
+				S: aws.String(scheduledPppk),
+			},
+		},
+	}
+	due := []string{}
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			var publication Publication
+			pk := aws.StringValue(item["pk"].S)
+			switch {
+			case strings.HasPrefix(pk, "med#"):
+				record := MeditationRecord{}
+				unmarshalErr = dynamodbattribute.UnmarshalMap(item, &record)
+				publication = record.toMeditation().publication()
+			case strings.HasPrefix(pk, "seq#"):
+				record := SequenceRecord{}
+				unmarshalErr = dynamodbattribute.UnmarshalMap(item, &record)
+				publication = record.toSequence().publication()
+			}
+			if unmarshalErr != nil {
+				return false
+			}
+			if publication.isDue(now) {
+				due = append(due, pk)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return []string{}, err
+	}
+	return due, unmarshalErr
+}
+
+// PublishDue publishes every scheduled meditation and sequence whose
+// publishAt has passed, returning the pks of those it published. An item
+// edited meanwhile is left for the next run.
+func (store DynamoMeditationStore) PublishDue(now time.Time) ([]string, error) {
+	due, err := store.listScheduledKeys(now)
+	if err != nil {
+		return []string{}, err
+	}
+
+	published := []string{}
+	failed := []string{}
+	for _, pk := range due {
+		if strings.HasPrefix(pk, "med#") {
+			err = store.publishMeditation(strings.TrimPrefix(pk, "med#"), now)
+		} else {
+			err = store.publishSequence(strings.TrimPrefix(pk, "seq#"), now)
+		}
+		if errors.Is(err, ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, pk+": "+err.Error())
+			continue
+		}
+		published = append(published, pk)
+	}
+	if len(failed) > 0 {
+		return published, fmt.Errorf("could not publish %s", strings.Join(failed, "; "))
+	}
+	return published, nil
+}
+
+func (store DynamoMeditationStore) publishMeditation(id string, now time.Time) error {
+	m, err := store.GetMeditation(id)
+	if err != nil {
+		return err
+	}
+	// trashed or already dealt with
+	if !m.publication().isDue(now) {
+		return nil
+	}
+	m.setPublication(Publication{Status: StatusPublished})
+	return store.UpdateMeditation(m)
+}
+
+func (store DynamoMeditationStore) publishSequence(id string, now time.Time) error {
+	s, err := store.GetSequenceById(id)
+	if errors.Is(err, ErrSequenceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !s.publication().isDue(now) {
+		return nil
+	}
+	s.setPublication(Publication{Status: StatusPublished})
+	return store.UpdateSequence(s)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Rate limits count requests per key in fixed windows, one record per
+// window under pk `ratelimit#<key>`, which TTL deletes once the window has
+// passed.
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// takeRateLimit counts a request against `key`, failing with ErrRateLimited
+// once `limit` requests have been made in the current `window`.
+func (store DynamoMeditationStore) takeRateLimit(key string, limit int, window time.Duration, now time.Time) error {
+	start := now.Truncate(window)
+	_, err := store.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(store.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String("ratelimit#" + key),
+			},
+			"sk": {
+				S: aws.String("window#" + strconv.FormatInt(start.Unix(), 10)),
+			},
+		},
+		UpdateExpression:    aws.String("SET #expiresAt = :expiresAt ADD #count :one"),
+		ConditionExpression: aws.String("attribute_not_exists(#count) OR #count < :limit"),
+		ExpressionAttributeNames: map[string]*string{
+			"#count":     aws.String("count"),
+			"#expiresAt": aws.String("expiresAt"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {
+				N: aws.String("1"),
+			},
+			":limit": {
+				N: aws.String(strconv.Itoa(limit)),
+			},
+			":expiresAt": {
+				N: aws.String(strconv.FormatInt(start.Add(2*window).Unix(), 10)),
+			},
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrRateLimited
+	}
+	return err
+}
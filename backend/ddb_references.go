@@ -0,0 +1,551 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Every meditation record carries a `refCount` of its author's sequences that
+// reference it. The count and the med#/seq# relation record are always
+// written in the same transaction, and a meditation can only be deleted while
+// its count is zero, so an author's sequence can never end up pointing at a
+// meditation that was deleted underneath it. Other users' sequences borrowing
+// a public meditation don't count towards it: their relation records are
+// marked `borrowed`, and once the meditation is deleted it reads as
+// unavailable in their sequences, see redactUnavailable.
+
+const MAX_TRANSACT_ITEMS = 25
+
+var ErrMeditationInUse = errors.New("meditation is still part of one or more sequences")
+var ErrMeditationNotFound = errors.New("meditation not found")
+var ErrMeditationMissing = errors.New("one or more of the meditationIDs does not exist")
+
+func meditationKey(id string) map[string]*dynamodb.AttributeValue {
+	r := mapMeditationToMeditationRecord(Meditation{ID: id})
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(r.Pk),
+		},
+		"sk": {
+			S: aws.String(r.Sk),
+		},
+	}
+}
+
+func relationKey(meditationId string, sequenceId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String("med#" + meditationId),
+		},
+		"sk": {
+			S: aws.String("seq#" + sequenceId),
+		},
+	}
+}
+
+// referenceCheck fails the surrounding transaction if the meditation doesn't
+// exist or is in the trash, without counting the reference.
+func (store DynamoMeditationStore) referenceCheck(meditationId string) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			TableName:           aws.String(store.tableName),
+			Key:                 meditationKey(meditationId),
+			ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(#deletedAt)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pk":        aws.String("pk"),
+				"#deletedAt": aws.String("deletedAt"),
+			},
+		},
+	}
+}
+
+// referenceCountUpdate adds delta to a meditation's refCount, failing the
+// surrounding transaction if the meditation doesn't exist or is in the trash.
+func (store DynamoMeditationStore) referenceCountUpdate(meditationId string, delta int) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:           aws.String(store.tableName),
+			Key:                 meditationKey(meditationId),
+			UpdateExpression:    aws.String("ADD #refCount :delta"),
+			ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(#deletedAt)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pk":        aws.String("pk"),
+				"#refCount":  aws.String("refCount"),
+				"#deletedAt": aws.String("deletedAt"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":delta": {
+					N: aws.String(strconv.Itoa(delta)),
+				},
+			},
+		},
+	}
+}
+
+func (store DynamoMeditationStore) addReferenceActions(meditationId string, sequenceId string, borrowed bool) ([]*dynamodb.TransactWriteItem, error) {
+	relationRecord := mapMeditationAndSequenceToMeditationAndSequenceRelationRecord(Meditation{ID: meditationId}, Sequence{ID: sequenceId})
+	relationRecord.Borrowed = borrowed
+	item, err := dynamodbattribute.MarshalMap(relationRecord)
+	if err != nil {
+		return nil, err
+	}
+	check := store.referenceCountUpdate(meditationId, 1)
+	if borrowed {
+		check = store.referenceCheck(meditationId)
+	}
+	return []*dynamodb.TransactWriteItem{
+		{
+			Put: &dynamodb.Put{
+				TableName: aws.String(store.tableName),
+				Item:      item,
+			},
+		},
+		check,
+	}, nil
+}
+
+func (store DynamoMeditationStore) removeReferenceActions(meditationId string, sequenceId string, borrowed bool) []*dynamodb.TransactWriteItem {
+	actions := []*dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(store.tableName),
+				Key:       relationKey(meditationId, sequenceId),
+			},
+		},
+	}
+	if !borrowed {
+		actions = append(actions, store.referenceCountUpdate(meditationId, -1))
+	}
+	return actions
+}
+
+// borrowedMeditations reports, for each of `added`, whether it is another
+// author's than `ownerId`, failing with ErrMeditationMissing if one doesn't
+// exist.
+func (store DynamoMeditationStore) borrowedMeditations(ownerId string, added []string) (map[string]bool, error) {
+	borrowed := make(map[string]bool)
+	if len(added) == 0 {
+		return borrowed, nil
+	}
+	meditations, err := store.GetMeditationsByIds(added)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range meditations {
+		if m.ID == "" {
+			return nil, fmt.Errorf("%w: %s", ErrMeditationMissing, added[i])
+		}
+		borrowed[m.ID] = m.UserId != ownerId
+	}
+	return borrowed, nil
+}
+
+// borrowedReferences reports, for each of `removed`, whether the sequence's
+// relation record to it is marked borrowed.
+func (store DynamoMeditationStore) borrowedReferences(sequenceId string, removed []string) (map[string]bool, error) {
+	borrowed := make(map[string]bool)
+	if len(removed) == 0 {
+		return borrowed, nil
+	}
+	ids := dedupIds(removed)
+	keys := make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, id := range ids {
+		keys[i] = relationKey(id, sequenceId)
+	}
+	items, err := store.batch().GetItems(keys)
+	if err != nil {
+		return nil, err
+	}
+	records := []MeditationSequenceRelationRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		borrowed[strings.TrimPrefix(r.Pk, "med#")] = r.Borrowed
+	}
+	return borrowed, nil
+}
+
+// adjustMeditationReferences creates the relation records for `added` and
+// removes them for `removed`, keeping the refCount of each meditation of the
+// sequence's owner, `ownerId`, in step.
+func (store DynamoMeditationStore) adjustMeditationReferences(sequenceId string, ownerId string, added []string, removed []string) error {
+	addBorrowed, err := store.borrowedMeditations(ownerId, added)
+	if err != nil {
+		return err
+	}
+	removeBorrowed, err := store.borrowedReferences(sequenceId, removed)
+	if err != nil {
+		return err
+	}
+
+	perTransaction := MAX_TRANSACT_ITEMS / 2
+
+	addGroups := [][]string{}
+	for start := 0; start < len(added); start += perTransaction {
+		end := start + perTransaction
+		if end > len(added) {
+			end = len(added)
+		}
+		addGroups = append(addGroups, added[start:end])
+	}
+	removeGroups := [][]string{}
+	for start := 0; start < len(removed); start += perTransaction {
+		end := start + perTransaction
+		if end > len(removed) {
+			end = len(removed)
+		}
+		removeGroups = append(removeGroups, removed[start:end])
+	}
+
+	e := store.batch()
+	applied := make([]bool, len(addGroups)+len(removeGroups))
+	err = e.runChunks(len(applied), func(idx int) error {
+		if idx < len(addGroups) {
+			actions := []*dynamodb.TransactWriteItem{}
+			for _, id := range addGroups[idx] {
+				a, err := store.addReferenceActions(id, sequenceId, addBorrowed[id])
+				if err != nil {
+					return err
+				}
+				actions = append(actions, a...)
+			}
+			err := store.transactWithRetry(e, actions)
+			if isConditionalCheckFailure(err) {
+				return ErrMeditationMissing
+			}
+			applied[idx] = err == nil
+			return err
+		}
+
+		group := removeGroups[idx-len(addGroups)]
+		actions := []*dynamodb.TransactWriteItem{}
+		for _, id := range group {
+			actions = append(actions, store.removeReferenceActions(id, sequenceId, removeBorrowed[id])...)
+		}
+		err := store.transactWithRetry(e, actions)
+		if !isConditionalCheckFailure(err) {
+			applied[idx] = err == nil
+			return err
+		}
+		// one of the meditations is already gone, so there is no count to
+		// decrement: just clear out the relation records one at a time
+		for _, id := range group {
+			err = store.transactWithRetry(e, store.removeReferenceActions(id, sequenceId, removeBorrowed[id]))
+			if isConditionalCheckFailure(err) {
+				_, err = store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+					TableName: aws.String(store.tableName),
+					Key:       relationKey(id, sequenceId),
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		applied[idx] = true
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	// undo the groups that did go through so the counts stay accurate; this is
+	// best effort, a failure here only leaves a count too high (which blocks
+	// deletion) or a stale relation record
+	for idx, ok := range applied {
+		if !ok {
+			continue
+		}
+		if idx < len(addGroups) {
+			for _, id := range addGroups[idx] {
+				store.transactWithRetry(e, store.removeReferenceActions(id, sequenceId, addBorrowed[id]))
+			}
+			continue
+		}
+		for _, id := range removeGroups[idx-len(addGroups)] {
+			if actions, marshalErr := store.addReferenceActions(id, sequenceId, removeBorrowed[id]); marshalErr == nil {
+				store.transactWithRetry(e, actions)
+			}
+		}
+	}
+	return err
+}
+
+// transactWithRetry retries transactions that were cancelled only because of
+// a conflicting concurrent transaction.
+func (store DynamoMeditationStore) transactWithRetry(e BatchExecutor, actions []*dynamodb.TransactWriteItem) error {
+	var err error
+	for attempt := 0; attempt <= e.MaxRetries; attempt++ {
+		if attempt > 0 {
+			e.sleep(e.backoff(attempt - 1))
+		}
+		_, err = store.svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		})
+		if !isTransactionConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func cancellationReasonCodes(err error) []string {
+	if err == nil {
+		return nil
+	}
+	codes := []string{}
+	if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		for _, reason := range canceled.CancellationReasons {
+			if reason != nil && reason.Code != nil {
+				codes = append(codes, *reason.Code)
+			}
+		}
+		return codes
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		// older endpoints (and localstack) only report the reasons in the message
+		if aerr.Code() == dynamodb.ErrCodeTransactionCanceledException {
+			for _, code := range []string{"ConditionalCheckFailed", "TransactionConflict"} {
+				if strings.Contains(aerr.Message(), code) {
+					codes = append(codes, code)
+				}
+			}
+		}
+	}
+	return codes
+}
+
+func isConditionalCheckFailure(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return true
+	}
+	for _, code := range cancellationReasonCodes(err) {
+		if code == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
+
+func isTransactionConflict(err error) bool {
+	for _, code := range cancellationReasonCodes(err) {
+		if code == "TransactionConflict" {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseMeditations reports whether every one of `meditations` may be put in
+// one of the user's sequences: they must exist and be the user's own or
+// public.
+func (store DynamoMeditationStore) CanUseMeditations(userId string, meditations []Meditation) bool {
+	for _, m := range meditations {
+		if m.ID == "" {
+			return false
+		}
+		if m.UserId != userId && !m.Public {
+			return false
+		}
+	}
+	return true
+}
+
+// addedMeditations returns those of `meditations`, fetched for
+// `meditationIds`, that `sequence` doesn't have yet. The ones it has may stay
+// even once they're no longer usable, as redactUnavailable shows them.
+func addedMeditations(meditationIds []string, meditations []Meditation, sequence Sequence) []Meditation {
+	has := make(map[string]bool)
+	for _, m := range sequence.Meditations {
+		has[m.ID] = true
+	}
+	added := []Meditation{}
+	for i, m := range meditations {
+		if !has[meditationIds[i]] {
+			added = append(added, m)
+		}
+	}
+	return added
+}
+
+// redactUnavailable blanks out the meditations in a sequence owned by
+// `ownerId` that its owner can no longer play, i.e. another author's
+// meditation that has since been made private or deleted. Their place in the
+// sequence is kept so the owner can see what went missing.
+func redactUnavailable(meditationIds []string, meditations []Meditation, ownerId string) []Meditation {
+	redacted := make([]Meditation, len(meditations))
+	for i, m := range meditations {
+		if m.ID != "" && (m.UserId == ownerId || m.Public) {
+			redacted[i] = m
+			continue
+		}
+		redacted[i] = Meditation{
+			ID:          meditationIds[i],
+			UserId:      m.UserId,
+			AuthorName:  m.AuthorName,
+			Unavailable: true,
+		}
+	}
+	return redacted
+}
+
+// DeleteMeditation moves the meditation to the trash (see ddb_trash.go) only
+// if none of its author's sequences, trashed ones included, references it.
+// The check happens in the same write as the delete.
+func (store DynamoMeditationStore) DeleteMeditation(id string) error {
+	return store.DeleteMeditationIfVersion(id, AnyVersion)
+}
+
+// ownSequenceIdsByMeditationId lists the sequences of the meditation's author,
+// `ownerId`, that reference it, trashed ones included.
+func (store DynamoMeditationStore) ownSequenceIdsByMeditationId(meditationId string, ownerId string) ([]string, error) {
+	sequenceIds, err := store.GetSequenceIdsByMeditationId(meditationId)
+	if err != nil || len(sequenceIds) == 0 {
+		return []string{}, err
+	}
+	keys := make([]map[string]*dynamodb.AttributeValue, len(sequenceIds))
+	for i, sequenceId := range sequenceIds {
+		keys[i] = sequenceKey(sequenceId)
+	}
+	items, err := store.batch().GetItems(keys)
+	if err != nil {
+		return []string{}, err
+	}
+	records := []SequenceRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return []string{}, err
+	}
+	own := []string{}
+	for _, r := range records {
+		if r.Sequence.Sequence.UserId == ownerId {
+			own = append(own, r.Sequence.Sequence.ID)
+		}
+	}
+	return own, nil
+}
+
+// DeleteMeditationIfVersion is DeleteMeditation, additionally requiring the
+// meditation to still be at `version`.
+func (store DynamoMeditationStore) DeleteMeditationIfVersion(id string, version int64) error {
+	existing, err := store.GetMeditation(id)
+	if err != nil {
+		return err
+	}
+	if existing.ID == "" {
+		return ErrMeditationNotFound
+	}
+
+	// 1) relation records written before reference counting existed aren't
+	// reflected in refCount, so check them explicitly too
+	sequenceIds, err := store.ownSequenceIdsByMeditationId(id, existing.UserId)
+	if err != nil {
+		return err
+	}
+	if len(sequenceIds) > 0 {
+		return fmt.Errorf("cannot delete meditation while it is still part of %d sequence(s): %w", len(sequenceIds), ErrMeditationInUse)
+	}
+
+	// 2) trash it, provided nothing references it
+	names := map[string]*string{
+		"#refCount": aws.String("refCount"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":zero": {
+			N: aws.String("0"),
+		},
+	}
+	params := store.trashUpdate(meditationKey(id), existing.UserId, version, "(attribute_not_exists(#refCount) OR #refCount <= :zero)", names, values, time.Now())
+	resp, err := store.svc.UpdateItem(params)
+	if isConditionalCheckFailure(err) {
+		m, getErr := store.GetMeditation(id)
+		if getErr != nil {
+			return getErr
+		}
+		if m.ID == "" {
+			return ErrMeditationNotFound
+		}
+		if version != AnyVersion && m.Version != version {
+			return ErrVersionMismatch
+		}
+		return ErrMeditationInUse
+	}
+	if err != nil {
+		return err
+	}
+
+	// 3) take it out of the tag and search indexes
+	deleted := MeditationRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Attributes, &deleted)
+	if err == nil {
+		store.reindexTaxonomy("med#"+id, meditationIndexKeys(deleted.toMeditation()), []string{}, nil)
+		store.reindexSearch("med#"+id, meditationSearchDocument(deleted.toMeditation()), SearchDocument{})
+	}
+	return nil
+}
+
+// DeleteMeditationCascade removes the meditation from every one of its
+// author's sequences that references it, and then deletes it. Other users'
+// sequences that borrowed it while it was public are left alone; it reads as
+// unavailable in them. Pass AnyVersion to skip the version check.
+func (store DynamoMeditationStore) DeleteMeditationCascade(id string, version int64) error {
+	m, err := store.GetMeditation(id)
+	if err != nil {
+		return err
+	}
+	if m.ID == "" {
+		return ErrMeditationNotFound
+	}
+	if version != AnyVersion && m.Version != version {
+		return ErrVersionMismatch
+	}
+
+	sequenceIds, err := store.ownSequenceIdsByMeditationId(id, m.UserId)
+	if err != nil {
+		return err
+	}
+	for _, sequenceId := range sequenceIds {
+		record, err := store.getAnySequenceRecord(sequenceId)
+		if err != nil {
+			return err
+		}
+		if record.DeletedAt != "" {
+			// only let go of it; restoring the sequence drops it, see
+			// RestoreSequence
+			err = store.adjustMeditationReferences(sequenceId, m.UserId, []string{}, []string{id})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		remaining := []Meditation{}
+		for _, mID := range record.Sequence.MeditationIDs {
+			if mID != id {
+				remaining = append(remaining, Meditation{ID: mID})
+			}
+		}
+		remainingSteps := []SequenceStep{}
+		for _, step := range record.steps() {
+			if step.MeditationID != id {
+				remainingSteps = append(remainingSteps, step)
+			}
+		}
+		s := record.toSequence()
+		s.Meditations = remaining
+		s.Steps = remainingSteps
+		err = store.UpdateSequence(s)
+		if err != nil {
+			return err
+		}
+	}
+	return store.DeleteMeditationIfVersion(id, version)
+}
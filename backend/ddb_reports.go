@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Reports on a public meditation or sequence live in the item's own
+// partition, one per reporter under `report#<reporter>`, so nobody counts
+// twice. A summary under sk "reports" keeps the count and, with ppk
+// "reported", lists the item for moderators (gs2). Once enough people have
+// reported an item it is hidden, back in the moderation queue, until a
+// moderator decides on it, which clears its reports.
+
+// REPORT_HIDE_THRESHOLD is how many reports hide an item unless
+// $REPORT_HIDE_THRESHOLD says otherwise.
+const REPORT_HIDE_THRESHOLD = 5
+
+const reportedPpk = "reported"
+
+var ErrAlreadyReported = errors.New("already reported")
+var ErrNoReports = errors.New("no reports")
+
+type Report struct {
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+// ReportedItem is a meditation or sequence with the reports made on it.
+type ReportedItem struct {
+	Type           string         `json:"type"` // meditation or sequence
+	ID             string         `json:"_id"`
+	Name           string         `json:"name"`
+	UserId         string         `json:"_userId"`
+	Count          int            `json:"count"`
+	Hidden         bool           `json:"hidden"` // whether the reports hid it
+	LastReportedAt time.Time      `json:"lastReportedAt"`
+	Reasons        map[string]int `json:"reasons"` // how many reports gave each reason
+	Reports        []Report       `json:"reports"`
+	Meditation     *Meditation    `json:"meditation,omitempty"`
+	Sequence       *Sequence      `json:"sequence,omitempty"`
+}
+
+type ReportRecord struct {
+	Pk     string `dynamodbav:"pk"`
+	Sk     string `dynamodbav:"sk"`
+	Type   string `dynamodbav:"type"`
+	Report Report `dynamodbav:"report"`
+}
+
+type ReportSummaryRecord struct {
+	Pk             string `dynamodbav:"pk"`
+	Sk             string `dynamodbav:"sk"`
+	Ppk            string `dynamodbav:"ppk"`
+	Type           string `dynamodbav:"type"`
+	Count          int    `dynamodbav:"count"`
+	Hidden         bool   `dynamodbav:"hidden"`
+	LastReportedAt string `dynamodbav:"lastReported"`
+}
+
+func reportHideThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("REPORT_HIDE_THRESHOLD"))
+	if err != nil || threshold < 1 {
+		threshold = REPORT_HIDE_THRESHOLD
+	}
+	return threshold
+}
+
+func reportSummaryKey(pk string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(pk),
+		},
+		"sk": {
+			S: aws.String("reports"),
+		},
+	}
+}
+
+func reportKey(pk string, reporter string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(pk),
+		},
+		"sk": {
+			S: aws.String("report#" + reporter),
+		},
+	}
+}
+
+// HasReported reports whether `reporter` has already reported the item at
+// `pk`.
+func (store DynamoMeditationStore) HasReported(pk string, reporter string) (bool, error) {
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName:            aws.String(store.tableName),
+		Key:                  reportKey(pk, reporter),
+		ProjectionExpression: aws.String("#pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(resp.Item) > 0, nil
+}
+
+// ReportItem records `reporter`'s report on the item at `pk`, hiding the
+// item once enough people have reported it. A reporter reporting the same
+// item again gets ErrAlreadyReported.
+func (store DynamoMeditationStore) ReportItem(pk string, reporter string, report Report) error {
+	report.ReportedAt = report.ReportedAt.UTC()
+	item, err := dynamodbattribute.MarshalMap(&ReportRecord{
+		Pk:     pk,
+		Sk:     "report#" + reporter,
+		Type:   "report",
+		Report: report,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = store.svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(store.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrAlreadyReported
+	}
+	if err != nil {
+		return err
+	}
+
+	// count it, taking the report back should that fail so that reporting
+	// again counts it
+	resp, err := store.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(store.tableName),
+		Key:              reportSummaryKey(pk),
+		UpdateExpression: aws.String("SET #ppk = :reported, #type = :type, #lastReported = :at ADD #count :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ppk":          aws.String("ppk"),
+			"#type":         aws.String("type"),
+			"#lastReported": aws.String("lastReported"),
+			"#count":        aws.String("count"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":reported": {
+				S: aws.String(reportedPpk),
+			},
+			":type": {
+				S: aws.String("reports"),
+			},
+			":at": {
+				S: aws.String(report.ReportedAt.Format(time.RFC3339)),
+			},
+			":one": {
+				N: aws.String("1"),
+			},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(store.tableName),
+			Key:       reportKey(pk, reporter),
+		})
+		return err
+	}
+	summary := ReportSummaryRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Attributes, &summary)
+	if err != nil {
+		return err
+	}
+
+	// hide the item once it has enough reports
+	if summary.Hidden || summary.Count < reportHideThreshold() {
+		return nil
+	}
+	hidden, err := store.hideReported(pk)
+	if err != nil || !hidden {
+		return err
+	}
+	_, err = store.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(store.tableName),
+		Key:                 reportSummaryKey(pk),
+		UpdateExpression:    aws.String("SET #hidden = :true"),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk":     aws.String("pk"),
+			"#hidden": aws.String("hidden"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":true": {
+				BOOL: aws.Bool(true),
+			},
+		},
+	})
+	// a moderator has dealt with it meanwhile
+	if isConditionalCheckFailure(err) {
+		return nil
+	}
+	return err
+}
+
+// hideReported hides the item at `pk`, if it is still public, retrying
+// should its author edit it at the same time.
+func (store DynamoMeditationStore) hideReported(pk string) (bool, error) {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if strings.HasPrefix(pk, "med#") {
+			var m Meditation
+			m, err = store.GetMeditation(strings.TrimPrefix(pk, "med#"))
+			if err != nil || m.ID == "" || !m.hide() {
+				return false, err
+			}
+			err = store.UpdateMeditation(m)
+		} else {
+			var s Sequence
+			s, err = store.GetSequenceById(strings.TrimPrefix(pk, "seq#"))
+			if errors.Is(err, ErrSequenceNotFound) {
+				return false, nil
+			}
+			if err != nil || !s.hide() {
+				return false, err
+			}
+			err = store.UpdateSequence(s)
+		}
+		if !errors.Is(err, ErrVersionMismatch) {
+			return err == nil, err
+		}
+	}
+	return false, err
+}
+
+// ListReportedItems lists the meditations and sequences with outstanding
+// reports, most reported first.
+func (store DynamoMeditationStore) ListReportedItems() ([]ReportedItem, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		IndexName:              aws.String("gs2"),
+		KeyConditionExpression: aws.String("#ppk = :reported"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ppk": aws.String("ppk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":reported": {
+				S: aws.String(reportedPpk),
+			},
+		},
+	}
+	summaries := []ReportSummaryRecord{}
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []ReportSummaryRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		summaries = append(summaries, records...)
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil || len(summaries) == 0 {
+		return []ReportedItem{}, err
+	}
+
+	// the reported items themselves
+	keys := make([]map[string]*dynamodb.AttributeValue, len(summaries))
+	for i, summary := range summaries {
+		keys[i] = map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String(summary.Pk),
+			},
+			"sk": {
+				S: aws.String(summary.Pk),
+			},
+		}
+	}
+	found, err := store.batch().GetItems(keys)
+	if err != nil {
+		return []ReportedItem{}, err
+	}
+	items := make(map[string]ReportedItem)
+	for _, item := range found {
+		if item["type"] == nil {
+			continue
+		}
+		switch aws.StringValue(item["type"].S) {
+		case "med":
+			record := MeditationRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, &record)
+			m := record.toMeditation()
+			if err == nil && record.DeletedAt == "" {
+				items[record.Pk] = ReportedItem{Type: LibraryMeditation, ID: m.ID, Name: m.Name, UserId: m.UserId, Meditation: &m}
+			}
+		case "seq":
+			record := SequenceRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, &record)
+			s := record.toSequence()
+			if err == nil && record.DeletedAt == "" {
+				items[record.Pk] = ReportedItem{Type: LibrarySequence, ID: s.ID, Name: s.Name, UserId: s.UserId, Sequence: &s}
+			}
+		}
+		if err != nil {
+			return []ReportedItem{}, err
+		}
+	}
+
+	reported := []ReportedItem{}
+	for _, summary := range summaries {
+		// deleted since
+		item, ok := items[summary.Pk]
+		if !ok {
+			continue
+		}
+		item.Reports, err = store.listReports(summary.Pk)
+		if err != nil {
+			return []ReportedItem{}, err
+		}
+		item.Count = summary.Count
+		item.Hidden = summary.Hidden
+		item.LastReportedAt, _ = time.Parse(time.RFC3339, summary.LastReportedAt)
+		item.Reasons = make(map[string]int)
+		for _, report := range item.Reports {
+			item.Reasons[report.Reason]++
+		}
+		reported = append(reported, item)
+	}
+	sort.SliceStable(reported, func(i, j int) bool {
+		if reported[i].Count != reported[j].Count {
+			return reported[i].Count > reported[j].Count
+		}
+		return reported[i].LastReportedAt.After(reported[j].LastReportedAt)
+	})
+	return reported, nil
+}
+
+// listReports returns the reports on the item at `pk`, newest first.
+func (store DynamoMeditationStore) listReports(pk string) ([]Report, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :report)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(pk),
+			},
+			":report": {
+				S: aws.String("report#"),
+			},
+		},
+	}
+	reports := []Report{}
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []ReportRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		for _, r := range records {
+			reports = append(reports, r.Report)
+		}
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return []Report{}, err
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].ReportedAt.After(reports[j].ReportedAt)
+	})
+	return reports, nil
+}
+
+// ClearReports deletes the reports on the item at `pk` and its summary,
+// failing with ErrNoReports if there were none.
+func (store DynamoMeditationStore) ClearReports(pk string) error {
+	requests := []*dynamodb.WriteRequest{}
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :report)"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(pk),
+			},
+			// both report#<reporter> and the summary, "reports"
+			":report": {
+				S: aws.String("report"),
+			},
+		},
+	}
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, key := range page.Items {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{
+					Key: key,
+				},
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if len(requests) == 0 {
+		return ErrNoReports
+	}
+	return store.batch().WriteItems(requests)
+}
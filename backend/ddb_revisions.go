@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Every update to a meditation or sequence snapshots what it replaces as a
+// revision record, in the same transaction as the update, so a careless edit
+// can be looked at and undone. A revision shares its item's pk, under sk
+// "rev#<version>", and is never written again. It has no ppk or pppk, which
+// keeps it out of the user and public indexes.
+
+// MAX_REVISIONS is how many of an item's most recent revisions are listed.
+const MAX_REVISIONS = 100
+
+var ErrRevisionNotFound = errors.New("no such revision")
+
+type RevisionRecord struct {
+	Pk        string `dynamodbav:"pk"`
+	Sk        string `dynamodbav:"sk"`
+	Type      string `dynamodbav:"type"`
+	UpdatedAt string `dynamodbav:"lastUpdated"` // when the update replaced it
+	Version   int64  `dynamodbav:"version"`
+
+	// one or the other
+	Meditation *Meditation  `dynamodbav:"meditation,omitempty"`
+	Sequence   *SequenceDAO `dynamodbav:"seqDAO,omitempty"`
+}
+
+func (r RevisionRecord) toRevision() Revision {
+	revision := Revision{Version: r.Version}
+	revision.ReplacedAt, _ = time.Parse(time.RFC3339, r.UpdatedAt)
+	if r.Meditation != nil {
+		m := MeditationRecord{Version: r.Version, Meditation: *r.Meditation}.toMeditation()
+		revision.SavedAt = m.UpdatedAt
+		revision.Meditation = &m
+	}
+	if r.Sequence != nil {
+		s := SequenceRecord{Version: r.Version, Sequence: *r.Sequence}.toSequence()
+		revision.SavedAt = s.UpdatedAt
+		revision.Sequence = &s
+	}
+	return revision
+}
+
+// revisionSk pads the version so revisions sort in order.
+func revisionSk(version int64) string {
+	return fmt.Sprintf("rev#%010d", version)
+}
+
+// revisionPut writes `record`, failing rather than overwriting an existing
+// revision.
+func (store DynamoMeditationStore) revisionPut(record RevisionRecord) (*dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:           aws.String(store.tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		},
+	}, nil
+}
+
+// meditationRevisionPut snapshots `m` as it was before an update.
+func (store DynamoMeditationStore) meditationRevisionPut(m Meditation, replacedAt string) (*dynamodb.TransactWriteItem, error) {
+	return store.revisionPut(RevisionRecord{
+		Pk:         "med#" + m.ID,
+		Sk:         revisionSk(m.Version),
+		Type:       "rev",
+		UpdatedAt:  replacedAt,
+		Version:    m.Version,
+		Meditation: &m,
+	})
+}
+
+// sequenceRevisionPut snapshots `record` as it was before an update.
+func (store DynamoMeditationStore) sequenceRevisionPut(record SequenceRecord, replacedAt string) (*dynamodb.TransactWriteItem, error) {
+	dao := record.Sequence
+	return store.revisionPut(RevisionRecord{
+		Pk:        record.Pk,
+		Sk:        revisionSk(record.Version),
+		Type:      "rev",
+		UpdatedAt: replacedAt,
+		Version:   record.Version,
+		Sequence:  &dao,
+	})
+}
+
+// listRevisions returns the revisions under `pk`, newest first.
+func (store DynamoMeditationStore) listRevisions(pk string) ([]Revision, error) {
+	resp, err := store.svc.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :rev)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(pk),
+			},
+			":rev": {
+				S: aws.String("rev#"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(MAX_REVISIONS),
+	})
+	if err != nil {
+		return []Revision{}, err
+	}
+	records := []RevisionRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(resp.Items, &records)
+	if err != nil {
+		return []Revision{}, err
+	}
+	revisions := make([]Revision, len(records))
+	for i, record := range records {
+		revisions[i] = record.toRevision()
+	}
+	return revisions, nil
+}
+
+func (store DynamoMeditationStore) getRevision(pk string, version int64) (Revision, error) {
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(store.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String(pk),
+			},
+			"sk": {
+				S: aws.String(revisionSk(version)),
+			},
+		},
+	})
+	if err != nil {
+		return Revision{}, err
+	}
+	if resp.Item == nil {
+		return Revision{}, ErrRevisionNotFound
+	}
+	record := RevisionRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &record)
+	if err != nil {
+		return Revision{}, err
+	}
+	return record.toRevision(), nil
+}
+
+// ListMeditationRevisions returns the meditation's earlier versions, newest
+// first.
+func (store DynamoMeditationStore) ListMeditationRevisions(id string) ([]Revision, error) {
+	return store.listRevisions("med#" + id)
+}
+
+// GetMeditationRevision returns the meditation as it was at `version`.
+func (store DynamoMeditationStore) GetMeditationRevision(id string, version int64) (Revision, error) {
+	return store.getRevision("med#"+id, version)
+}
+
+// ListSequenceRevisions returns the sequence's earlier versions, newest
+// first. Their steps name meditations, which aren't filled in.
+func (store DynamoMeditationStore) ListSequenceRevisions(id string) ([]Revision, error) {
+	return store.listRevisions("seq#" + id)
+}
+
+// GetSequenceRevision returns the sequence as it was at `version`.
+func (store DynamoMeditationStore) GetSequenceRevision(id string, version int64) (Revision, error) {
+	return store.getRevision("seq#"+id, version)
+}
+
+// purgeRevisions deletes every revision of the item at `pk`, once TTL has
+// deleted the item itself.
+func (store DynamoMeditationStore) purgeRevisions(pk string) error {
+	requests := []*dynamodb.WriteRequest{}
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :rev)"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(pk),
+			},
+			":rev": {
+				S: aws.String("rev#"),
+			},
+		},
+	}
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, key := range page.Items {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{
+					Key: key,
+				},
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return store.batch().WriteItems(requests)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// The full-text index lives in the table: each term of a public item gets a
+// posting record, pk `term#<stem>` and sk `med#<id>` or `seq#<id>`, holding
+// the word positions (for phrases) and what BM25 needs to rank the item. The
+// document count and total length of the index are kept under
+// pk `searchstats`. An indexed item also has a `searchdoc` record in its own
+// partition with the length it was counted at, written in the same
+// transaction as the statistics, so they can always be brought back in step.
+
+const (
+	SEARCH_STATS_PK = "searchstats"
+	SEARCH_STATS_SK = "corpus"
+	SEARCH_DOC_SK   = "searchdoc"
+)
+
+type SearchPostingRecord struct {
+	Pk        string `dynamodbav:"pk"`
+	Sk        string `dynamodbav:"sk"`
+	Type      string `dynamodbav:"type"`
+	Positions []int  `dynamodbav:"positions"`
+	NameHits  int    `dynamodbav:"nameHits"`
+	Length    int    `dynamodbav:"length"`
+}
+
+func (r SearchPostingRecord) posting() SearchPosting {
+	return SearchPosting{Positions: r.Positions, NameHits: r.NameHits, Length: r.Length}
+}
+
+type SearchStatsRecord struct {
+	Pk        string `dynamodbav:"pk"`
+	Sk        string `dynamodbav:"sk"`
+	Documents int    `dynamodbav:"documents"`
+	Words     int    `dynamodbav:"words"`
+}
+
+type SearchDocumentRecord struct {
+	Pk     string `dynamodbav:"pk"`
+	Sk     string `dynamodbav:"sk"`
+	Type   string `dynamodbav:"type"`
+	Length int    `dynamodbav:"length"`
+}
+
+type SearchHit struct {
+	Sk    string // med#<id> or seq#<id>
+	Score float64
+}
+
+// indexedPostings returns the postings actually stored for `itemSk`, by term.
+func (store DynamoMeditationStore) indexedPostings(itemSk string) (map[string]SearchPosting, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		IndexName:              aws.String("gs1"),
+		KeyConditionExpression: aws.String("#sk = :sk"),
+		FilterExpression:       aws.String("#type = :type"),
+		ExpressionAttributeNames: map[string]*string{
+			"#sk":   aws.String("sk"),
+			"#type": aws.String("type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sk": {
+				S: aws.String(itemSk),
+			},
+			":type": {
+				S: aws.String("posting"),
+			},
+		},
+	}
+	postings := make(map[string]SearchPosting)
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []SearchPostingRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		for _, r := range records {
+			postings[strings.TrimPrefix(r.Pk, "term#")] = r.posting()
+		}
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	return postings, err
+}
+
+func searchDocumentKey(itemSk string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(itemSk),
+		},
+		"sk": {
+			S: aws.String(SEARCH_DOC_SK),
+		},
+	}
+}
+
+// syncSearchIndex indexes `itemSk` as document `after`. Postings are
+// reconciled against those stored, along with `before`'s, and the corpus
+// statistics against the item's searchdoc record, so anything a failed sync
+// left behind is put right.
+func (store DynamoMeditationStore) syncSearchIndex(itemSk string, before SearchDocument, after SearchDocument) error {
+	stored, err := store.indexedPostings(itemSk)
+	if err != nil {
+		return err
+	}
+	beforePostings, _ := indexDocument(before)
+	afterPostings, afterLength := indexDocument(after)
+
+	// 1) the postings
+	requests := []*dynamodb.WriteRequest{}
+	for term, p := range afterPostings {
+		if old, ok := stored[term]; ok && old.equal(p) {
+			continue
+		}
+		item, err := dynamodbattribute.MarshalMap(SearchPostingRecord{
+			Pk:        "term#" + term,
+			Sk:        itemSk,
+			Type:      "posting",
+			Positions: p.Positions,
+			NameHits:  p.NameHits,
+			Length:    p.Length,
+		})
+		if err != nil {
+			return err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: item},
+		})
+	}
+	stale := make(map[string]bool)
+	for term := range stored {
+		stale[term] = true
+	}
+	for term := range beforePostings {
+		stale[term] = true
+	}
+	for term := range stale {
+		if _, ok := afterPostings[term]; ok {
+			continue
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"pk": {
+						S: aws.String("term#" + term),
+					},
+					"sk": {
+						S: aws.String(itemSk),
+					},
+				},
+			},
+		})
+	}
+	err = store.batch().WriteItems(requests)
+	if err != nil {
+		return err
+	}
+
+	// 2) the corpus statistics, counting the item as its searchdoc record
+	// says it was counted
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(store.tableName),
+		Key:            searchDocumentKey(itemSk),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	counted := SearchDocumentRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &counted)
+	if err != nil {
+		return err
+	}
+	wasIndexed, indexed := resp.Item != nil, !after.empty()
+	if wasIndexed == indexed && counted.Length == afterLength {
+		return nil
+	}
+
+	documents := 0
+	if wasIndexed {
+		documents--
+	}
+	if indexed {
+		documents++
+	}
+	words := afterLength - counted.Length
+
+	// the searchdoc record must still be as we read it
+	condition := aws.String("attribute_not_exists(#pk)")
+	names := map[string]*string{
+		"#pk": aws.String("pk"),
+	}
+	var values map[string]*dynamodb.AttributeValue
+	if wasIndexed {
+		condition = aws.String("#length = :counted")
+		names = map[string]*string{
+			"#length": aws.String("length"),
+		}
+		values = map[string]*dynamodb.AttributeValue{
+			":counted": {
+				N: aws.String(strconv.Itoa(counted.Length)),
+			},
+		}
+	}
+	actions := []*dynamodb.TransactWriteItem{}
+	if indexed {
+		item, err := dynamodbattribute.MarshalMap(SearchDocumentRecord{Pk: itemSk, Sk: SEARCH_DOC_SK, Type: "searchdoc", Length: afterLength})
+		if err != nil {
+			return err
+		}
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:                 aws.String(store.tableName),
+				Item:                      item,
+				ConditionExpression:       condition,
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		})
+	} else {
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName:                 aws.String(store.tableName),
+				Key:                       searchDocumentKey(itemSk),
+				ConditionExpression:       condition,
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		})
+	}
+	if documents != 0 || words != 0 {
+		actions = append(actions, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(store.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"pk": {
+						S: aws.String(SEARCH_STATS_PK),
+					},
+					"sk": {
+						S: aws.String(SEARCH_STATS_SK),
+					},
+				},
+				UpdateExpression: aws.String("ADD #documents :documents, #words :words"),
+				ExpressionAttributeNames: map[string]*string{
+					"#documents": aws.String("documents"),
+					"#words":     aws.String("words"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":documents": {
+						N: aws.String(strconv.Itoa(documents)),
+					},
+					":words": {
+						N: aws.String(strconv.Itoa(words)),
+					},
+				},
+			},
+		})
+	}
+	return store.transactWithRetry(store.batch(), actions)
+}
+
+// reindexSearch is syncSearchIndex for callers that have just written an
+// item; like reindexTaxonomy, a failure is only logged and made up the next
+// time the item is saved.
+func (store DynamoMeditationStore) reindexSearch(itemSk string, before SearchDocument, after SearchDocument) {
+	err := store.syncSearchIndex(itemSk, before, after)
+	if err != nil {
+		log.Printf("could not update the search index for %s: %v", itemSk, err)
+	}
+}
+
+func (store DynamoMeditationStore) getSearchStats() (SearchStatsRecord, error) {
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(store.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String(SEARCH_STATS_PK),
+			},
+			"sk": {
+				S: aws.String(SEARCH_STATS_SK),
+			},
+		},
+	})
+	stats := SearchStatsRecord{}
+	if err != nil {
+		return stats, err
+	}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &stats)
+	return stats, err
+}
+
+// getPostings returns every posting of `term`, keyed by item.
+func (store DynamoMeditationStore) getPostings(term string) (map[string]SearchPosting, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String("term#" + term),
+			},
+		},
+	}
+	postings := make(map[string]SearchPosting)
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []SearchPostingRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		for _, r := range records {
+			postings[r.Sk] = r.posting()
+		}
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	return postings, err
+}
+
+// SearchPublic runs `query` against the public meditations and sequences and
+// returns the best `limit` matches, best first. `prefix` (med# or seq#)
+// narrows the search to one kind, "" searches both.
+func (store DynamoMeditationStore) SearchPublic(query SearchQuery, prefix string, limit int) ([]SearchHit, error) {
+	if query.empty() {
+		return []SearchHit{}, nil
+	}
+	stats, err := store.getSearchStats()
+	if err != nil {
+		return []SearchHit{}, err
+	}
+
+	// 1) gather the postings of each term, by item
+	documentFrequency := make(map[string]int)
+	byItem := make(map[string]map[string]SearchPosting)
+	for i, term := range query.terms() {
+		postings, err := store.getPostings(term)
+		if err != nil {
+			return []SearchHit{}, err
+		}
+		documentFrequency[term] = len(postings)
+		for sk, p := range postings {
+			if !strings.HasPrefix(sk, prefix) {
+				continue
+			}
+			// every clause must match, so only items with the first
+			// term can be hits
+			if i == 0 {
+				byItem[sk] = make(map[string]SearchPosting)
+			}
+			if itemPostings, ok := byItem[sk]; ok {
+				itemPostings[term] = p
+			}
+		}
+	}
+
+	// 2) keep the matches and rank them
+	averageLength := 0.0
+	if stats.Documents > 0 {
+		averageLength = float64(stats.Words) / float64(stats.Documents)
+	}
+	hits := []SearchHit{}
+	for sk, postings := range byItem {
+		if query.matches(postings) {
+			hits = append(hits, SearchHit{
+				Sk:    sk,
+				Score: bm25(postings, documentFrequency, stats.Documents, averageLength),
+			})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Sk > hits[j].Sk
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
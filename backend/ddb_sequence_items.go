@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrPositionOutOfRange = errors.New("position is out of range")
+
+// editSequenceItems applies `edit` to the sequence's steps, then writes only
+// the relation records for meditations that joined or left the sequence
+// rather than rewriting all of them. With `resubmit`, an approved sequence
+// whose steps change goes back for review, see moderation.go.
+func (store DynamoMeditationStore) editSequenceItems(sequenceId string, version int64, resubmit bool, edit func([]SequenceStep) ([]SequenceStep, error)) error {
+	record, err := store.getSequenceRecord(sequenceId)
+	if err != nil {
+		return err
+	}
+	if version != AnyVersion && record.Version != version {
+		return ErrVersionMismatch
+	}
+	readVersion := record.Version
+	previous := record
+
+	oldIds := record.Sequence.MeditationIDs
+	newSteps, err := edit(append([]SequenceStep{}, record.steps()...))
+	if err != nil {
+		return err
+	}
+	err = validateSteps(newSteps)
+	if err != nil {
+		return err
+	}
+	newIds := stepMeditationIDs(newSteps)
+
+	before := make(map[string]bool)
+	for _, id := range oldIds {
+		before[id] = true
+	}
+	after := make(map[string]bool)
+	for _, id := range newIds {
+		after[id] = true
+	}
+	added := []string{}
+	for _, id := range dedupIds(newIds) {
+		if !before[id] {
+			added = append(added, id)
+		}
+	}
+	removed := []string{}
+	for _, id := range dedupIds(oldIds) {
+		if !after[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	// 1) reference new meditations first, so we fail before the sequence
+	// points at one that has been deleted
+	err = store.adjustMeditationReferences(sequenceId, record.Sequence.Sequence.UserId, added, []string{})
+	if err != nil {
+		return err
+	}
+
+	// 2) save the new order
+	now := time.Now()
+	record.Sequence.MeditationIDs = newIds
+	record.Sequence.Steps = nil
+	if !isPlainSteps(newSteps) {
+		record.Sequence.Steps = newSteps
+	}
+	record.Sequence.Sequence.UpdatedAt = now
+	record.UpdatedAt = now.UTC().Format(time.RFC3339)
+	record.Version = readVersion + 1
+	resubmitted := false
+	if resubmit && !sameSteps(previous.steps(), newSteps) {
+		s := record.toSequence()
+		if resubmitted = s.resubmit(); resubmitted {
+			record.Sequence.Sequence = s
+			record.Pppk = mapSequenceToSequenceRecord(s).Pppk
+		}
+	}
+	err = store.putSequenceRecordIfVersion(&record, previous, readVersion)
+	if err != nil {
+		store.adjustMeditationReferences(sequenceId, record.Sequence.Sequence.UserId, []string{}, added)
+		return err
+	}
+	if resubmitted {
+		store.reindexTaxonomy("seq#"+sequenceId, sequenceIndexKeys(previous.toSequence()), sequenceIndexKeys(record.toSequence()), nil)
+		store.reindexSearch("seq#"+sequenceId, sequenceSearchDocument(previous.toSequence()), sequenceSearchDocument(record.toSequence()))
+	}
+
+	// 3) drop the references that are no longer needed
+	return store.adjustMeditationReferences(sequenceId, record.Sequence.Sequence.UserId, []string{}, removed)
+}
+
+// InsertSequenceItem inserts a step at `position` (0 is the front, the
+// current length appends).
+func (store DynamoMeditationStore) InsertSequenceItem(sequenceId string, version int64, resubmit bool, step SequenceStep, position int) error {
+	return store.editSequenceItems(sequenceId, version, resubmit, func(steps []SequenceStep) ([]SequenceStep, error) {
+		if position < 0 || position > len(steps) {
+			return nil, ErrPositionOutOfRange
+		}
+		steps = append(steps, SequenceStep{})
+		copy(steps[position+1:], steps[position:])
+		steps[position] = step
+		return steps, nil
+	})
+}
+
+// RemoveSequenceItem removes the step at `position`.
+func (store DynamoMeditationStore) RemoveSequenceItem(sequenceId string, version int64, resubmit bool, position int) error {
+	return store.editSequenceItems(sequenceId, version, resubmit, func(steps []SequenceStep) ([]SequenceStep, error) {
+		if position < 0 || position >= len(steps) {
+			return nil, ErrPositionOutOfRange
+		}
+		return append(steps[:position], steps[position+1:]...), nil
+	})
+}
+
+// MoveSequenceItem moves the step at `from` so that it ends up at `to`.
+func (store DynamoMeditationStore) MoveSequenceItem(sequenceId string, version int64, resubmit bool, from int, to int) error {
+	return store.editSequenceItems(sequenceId, version, resubmit, func(steps []SequenceStep) ([]SequenceStep, error) {
+		if from < 0 || from >= len(steps) || to < 0 || to >= len(steps) {
+			return nil, ErrPositionOutOfRange
+		}
+		moved := steps[from]
+		steps = append(steps[:from], steps[from+1:]...)
+		steps = append(steps, SequenceStep{})
+		copy(steps[to+1:], steps[to:])
+		steps[to] = moved
+		return steps, nil
+	})
+}
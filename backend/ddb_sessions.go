@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/segmentio/ksuid"
+)
+
+// Practice sessions live in the user's own partition, `user#<userId>`, under
+// `session#<id>`. The id is a ksuid stamped with the session's start time, so
+// the sort key orders a user's sessions by when they were sat and a date
+// range is a single key condition.
+
+var ErrSessionNotFound = errors.New("session not found")
+
+var ksuidMinTime = time.Unix(ksuid.Nil.Time().Unix(), 0)
+var ksuidMaxTime = ksuid.Max.Time()
+
+type SessionRecord struct {
+	Pk        string          `dynamodbav:"pk"`
+	Sk        string          `dynamodbav:"sk"`
+	Type      string          `dynamodbav:"type"`
+	UpdatedAt string          `dynamodbav:"lastUpdated"`
+	Session   PracticeSession `dynamodbav:"session"`
+}
+
+func userKey(userId string) string {
+	return "user#" + userId
+}
+
+func sessionKey(userId string, sessionId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(userKey(userId)),
+		},
+		"sk": {
+			S: aws.String("session#" + sessionId),
+		},
+	}
+}
+
+// newSessionId returns a ksuid for a session that started at `startedAt`.
+func newSessionId(startedAt time.Time) (string, error) {
+	id, err := ksuid.NewRandomWithTime(startedAt)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+func mapSessionToSessionRecord(s PracticeSession) *SessionRecord {
+	return &SessionRecord{
+		Pk:        userKey(s.UserId),
+		Sk:        "session#" + s.ID,
+		Type:      "session",
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		Session:   s,
+	}
+}
+
+func (store DynamoMeditationStore) SaveSession(s PracticeSession) error {
+	item, err := dynamodbattribute.MarshalMap(mapSessionToSessionRecord(s))
+	if err != nil {
+		return err
+	}
+	_, err = store.svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(store.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+func (store DynamoMeditationStore) GetSession(userId string, sessionId string) (PracticeSession, error) {
+	resp, err := store.svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(store.tableName),
+		Key:       sessionKey(userId, sessionId),
+	})
+	if err != nil {
+		return PracticeSession{}, err
+	}
+	if resp.Item == nil {
+		return PracticeSession{}, ErrSessionNotFound
+	}
+	record := SessionRecord{}
+	err = dynamodbattribute.UnmarshalMap(resp.Item, &record)
+	if err != nil {
+		return PracticeSession{}, err
+	}
+	return record.Session, nil
+}
+
+// UpdateSession overwrites an existing session.
+func (store DynamoMeditationStore) UpdateSession(s PracticeSession) error {
+	item, err := dynamodbattribute.MarshalMap(mapSessionToSessionRecord(s))
+	if err != nil {
+		return err
+	}
+	_, err = store.svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(store.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func (store DynamoMeditationStore) DeleteSession(userId string, sessionId string) error {
+	_, err := store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(store.tableName),
+		Key:                 sessionKey(userId, sessionId),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// ListSessions returns the user's sessions that started in [from, to), newest
+// first.
+func (store DynamoMeditationStore) ListSessions(userId string, from time.Time, to time.Time) ([]PracticeSession, error) {
+	// ksuid timestamps only cover a fixed window of time
+	if from.Before(ksuidMinTime) {
+		from = ksuidMinTime
+	}
+	if to.After(ksuidMaxTime) {
+		to = ksuidMaxTime
+	}
+	lower, err := ksuid.FromParts(from, make([]byte, 16))
+	if err != nil {
+		return []PracticeSession{}, err
+	}
+	// the smallest id from `to` onwards, so the range excludes `to`
+	upper, err := ksuid.FromParts(to, make([]byte, 16))
+	if err != nil {
+		return []PracticeSession{}, err
+	}
+	upperSk := "session#" + upper.String()
+
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and #sk between :from and :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(userKey(userId)),
+			},
+			":from": {
+				S: aws.String("session#" + lower.String()),
+			},
+			":to": {
+				S: aws.String(upperSk),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	sessions := []PracticeSession{}
+	var unmarshalErr error
+	err = store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []SessionRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		if unmarshalErr != nil {
+			return false
+		}
+		for _, r := range records {
+			if r.Sk != upperSk {
+				sessions = append(sessions, r.Session)
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return []PracticeSession{}, err
+	}
+	return sessions, nil
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"errors"
 	"fmt"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 type MeditationRecord struct {
@@ -19,7 +21,21 @@ type MeditationRecord struct {
 	Pppk       string     `dynamodbav:"pppk,omitempty"`
 	Type       string     `dynamodbav:"type"`
 	UpdatedAt  string     `dynamodbav:"lastUpdated"`
+	RefCount   int        `dynamodbav:"refCount,omitempty"`
+	Version    int64      `dynamodbav:"version"`
 	Meditation Meditation `dynamodbav:"meditation"`
+
+	// set while the meditation is in the trash, see ddb_trash.go
+	DeletedAt string `dynamodbav:"deletedAt,omitempty"`
+	ExpiresAt int64  `dynamodbav:"expiresAt,omitempty"`
+}
+
+func (r MeditationRecord) toMeditation() Meditation {
+	m := r.Meditation
+	m.Version = r.Version
+	m.Status = publicationStatus(m.Status, m.Public)
+	m.Moderation = moderationState(m.Moderation, m.Public)
+	return m
 }
 
 type SequenceRecord struct {
@@ -29,7 +45,32 @@ type SequenceRecord struct {
 	Pppk      string      `dynamodbav:"pppk,omitempty"`
 	Type      string      `dynamodbav:"type"`
 	UpdatedAt string      `dynamodbav:"lastUpdated"`
+	Version   int64       `dynamodbav:"version"`
+	ForkCount int         `dynamodbav:"forkCount,omitempty"`
 	Sequence  SequenceDAO `dynamodbav:"seqDAO"`
+
+	// set while the sequence is in the trash, see ddb_trash.go
+	DeletedAt string `dynamodbav:"deletedAt,omitempty"`
+	ExpiresAt int64  `dynamodbav:"expiresAt,omitempty"`
+}
+
+func (r SequenceRecord) toSequence() Sequence {
+	s := r.Sequence.Sequence
+	s.Version = r.Version
+	s.Status = publicationStatus(s.Status, s.Public)
+	s.Moderation = moderationState(s.Moderation, s.Public)
+	s.ForkCount = r.ForkCount
+	s.Steps = r.steps()
+	return s
+}
+
+// steps returns the stored steps, or one meditation step per id for
+// sequences saved before steps existed.
+func (r SequenceRecord) steps() []SequenceStep {
+	if len(r.Sequence.Steps) > 0 {
+		return r.Sequence.Steps
+	}
+	return stepsFromMeditationIDs(r.Sequence.MeditationIDs)
 }
 
 type MeditationSequenceRelationRecord struct {
@@ -39,13 +80,17 @@ type MeditationSequenceRelationRecord struct {
 	// Pppk      string `dynamodbav:"pppk"`
 	Type      string `dynamodbav:"type"`
 	UpdatedAt string `dynamodbav:"lastUpdated"`
+	Borrowed  bool   `dynamodbav:"borrowed,omitempty"` // another author's meditation, not counted in its refCount
 }
 
 type SequenceDAO struct {
-	Sequence      Sequence `dynamodbav:"sequence"`
-	MeditationIDs []string `dynamodbav:"meditationIds"`
+	Sequence      Sequence       `dynamodbav:"sequence"`
+	MeditationIDs []string       `dynamodbav:"meditationIds"`
+	Steps         []SequenceStep `dynamodbav:"steps,omitempty"`
 }
 
+var ErrSequenceNotFound = errors.New("no sequence found")
+
 type MeditationStore interface {
 	SaveMeditation(m Meditation) error
 	ListMeditations(userId string) ([]Meditation, error)
@@ -59,7 +104,7 @@ type MeditationStore interface {
 
 type DynamoMeditationStore struct {
 	sess      *session.Session
-	svc       *dynamodb.DynamoDB
+	svc       dynamodbiface.DynamoDBAPI
 	tableName string
 }
 
@@ -73,6 +118,10 @@ func NewDynamoMeditationStore(tableName string, config *aws.Config) DynamoMedita
 	return dynamoStore
 }
 
+func (store DynamoMeditationStore) batch() BatchExecutor {
+	return NewBatchExecutor(store.svc, store.tableName)
+}
+
 func ternary(condition bool, ifTrue string, ifFalse string) string {
 	if condition {
 		return ifTrue
@@ -85,6 +134,9 @@ func mapMeditationToMeditationRecord(m Meditation) *MeditationRecord {
 	sk := pk
 	ppk := m.UserId
 	pppk := ternary(m.Public, "public", "private")
+	if waiting := waitingPppk(m.publication().Status, moderationState(m.Moderation, m.Public)); waiting != "" {
+		pppk = waiting
+	}
 	updatedAt := time.Now().UTC().Format(time.RFC3339)
 
 	return &MeditationRecord{
@@ -94,6 +146,7 @@ func mapMeditationToMeditationRecord(m Meditation) *MeditationRecord {
 		Pppk:       pppk,
 		Type:       "med",
 		UpdatedAt:  updatedAt,
+		Version:    m.Version,
 		Meditation: m,
 	}
 }
@@ -103,6 +156,9 @@ func mapSequenceToSequenceRecord(s Sequence) *SequenceRecord {
 	sk := pk
 	ppk := s.UserId
 	pppk := ternary(s.Public, "public-seq", "")
+	if waiting := waitingPppk(s.publication().Status, moderationState(s.Moderation, s.Public)); waiting != "" {
+		pppk = waiting
+	}
 
 	updatedAt := time.Now().UTC().Format(time.RFC3339)
 
@@ -110,6 +166,13 @@ func mapSequenceToSequenceRecord(s Sequence) *SequenceRecord {
 	for i, m := range s.Meditations {
 		meditationIDs[i] = m.ID
 	}
+	// steps other than plain meditations are the source of truth for the
+	// meditations; plain ones are the same as the list of meditations
+	var steps []SequenceStep
+	if !isPlainSteps(s.Steps) {
+		steps = s.Steps
+		meditationIDs = stepMeditationIDs(steps)
+	}
 
 	return &SequenceRecord{
 		Pk:        pk,
@@ -118,9 +181,11 @@ func mapSequenceToSequenceRecord(s Sequence) *SequenceRecord {
 		Pppk:      pppk,
 		Type:      "seq",
 		UpdatedAt: updatedAt,
+		Version:   s.Version,
 		Sequence: SequenceDAO{
 			Sequence:      s,
 			MeditationIDs: meditationIDs,
+			Steps:         steps,
 		},
 	}
 }
@@ -159,6 +224,8 @@ func (store DynamoMeditationStore) SaveMeditation(meditation Meditation) error {
 		fmt.Println(err)
 		return err
 	}
+	store.reindexTaxonomy("med#"+meditation.ID, []string{}, meditationIndexKeys(meditation), meditationIndexLabels(meditation))
+	store.reindexSearch("med#"+meditation.ID, SearchDocument{}, meditationSearchDocument(meditation))
 	return nil
 }
 
@@ -196,7 +263,7 @@ func (store DynamoMeditationStore) ListMeditations(userId string) ([]Meditation,
 
 	meditations := make([]Meditation, len(meditationRecords))
 	for i, m := range meditationRecords {
-		meditations[i] = m.Meditation
+		meditations[i] = m.toMeditation()
 	}
 
 	return meditations, nil
@@ -236,13 +303,27 @@ func (store DynamoMeditationStore) ListPublicMeditations() ([]Meditation, error)
 
 	meditations := make([]Meditation, len(meditationRecords))
 	for i, m := range meditationRecords {
-		meditations[i] = m.Meditation
+		meditations[i] = m.toMeditation()
 	}
 
 	return meditations, nil
 }
 
+// GetMeditation returns the meditation, or an empty one if there is no such
+// meditation or it is in the trash.
 func (store DynamoMeditationStore) GetMeditation(id string) (Meditation, error) {
+	record, err := store.getMeditationRecord(id)
+	if err != nil {
+		return Meditation{}, err
+	}
+	if record.DeletedAt != "" {
+		return Meditation{}, nil
+	}
+	return record.toMeditation(), nil
+}
+
+// getMeditationRecord reads the meditation's record, trashed or not.
+func (store DynamoMeditationStore) getMeditationRecord(id string) (MeditationRecord, error) {
 	m := Meditation{
 		ID: id,
 	}
@@ -261,94 +342,113 @@ func (store DynamoMeditationStore) GetMeditation(id string) (Meditation, error)
 
 	resp, err := store.svc.GetItem(params)
 	if err != nil {
-		return Meditation{}, errors.New("error with call to dynamodb on getMeditation")
+		return MeditationRecord{}, errors.New("error with call to dynamodb on getMeditation")
 	}
 	var meditationRecord MeditationRecord
 	dynamodbattribute.UnmarshalMap(resp.Item, &meditationRecord)
 
-	return meditationRecord.Meditation, nil
+	return meditationRecord, nil
 }
 
-func (store DynamoMeditationStore) DeleteMeditation(id string) error {
-	// 1) check if this is part of a sequence. if it is, return an error
-	sequenceIds, err := store.GetSequenceIdsByMeditationId(id)
+func (store DynamoMeditationStore) UpdateMeditation(m Meditation) error {
+	oldMeditation, err := store.GetMeditation(m.ID)
 	if err != nil {
 		return err
 	}
-	if len(sequenceIds) > 0 {
-		return fmt.Errorf("cannot delete meditation while it is still part of %d sequence(s)", len(sequenceIds))
-	}
 
-	// 2) proceed with the deletion
-	meditation, err := store.GetMeditation(id)
-	if err != nil {
-		return err
-	}
-	record := mapMeditationToMeditationRecord(meditation)
-	params := &dynamodb.DeleteItemInput{
-		TableName: aws.String(store.tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"pk": {
-				S: &record.Pk,
-			},
-			"sk": {
-				S: &record.Sk,
-			},
-		},
+	if oldMeditation.ID == "" {
+		return errors.New("No meditation with " + m.ID + " found.")
 	}
-	_, err = store.svc.DeleteItem(params)
 	if err != nil {
 		return err
 	}
-	return nil
-}
+	// the revision snapshots what we read, so it must be what we replace
+	if oldMeditation.Version != m.Version {
+		return ErrVersionMismatch
+	}
 
-func (store DynamoMeditationStore) UpdateMeditation(m Meditation) error {
-	oldMeditation, err := store.GetMeditation(m.ID)
+	// update in place rather than putting the whole record, so the
+	// meditation's refCount is left alone. The write only succeeds if the
+	// stored version is still the one the caller read, and it goes together
+	// with a revision of the old one (see ddb_revisions.go).
+	record := mapMeditationToMeditationRecord(m)
+	meditationAV, err := dynamodbattribute.Marshal(record.Meditation)
 	if err != nil {
 		return err
 	}
-
-	if (Meditation{}) == oldMeditation {
-		return errors.New("No meditation with " + m.ID + " found.")
-	}
+	revision, err := store.meditationRevisionPut(oldMeditation, record.UpdatedAt)
 	if err != nil {
 		return err
 	}
-
-	record := mapMeditationToMeditationRecord(m)
-	meditationAVmap, _ := dynamodbattribute.MarshalMap(record)
-	params := &dynamodb.PutItemInput{
-		TableName:           aws.String(store.tableName),
-		Item:                meditationAVmap,
-		ConditionExpression: aws.String("#lastUpdated <= :lastUpdated"),
-		ExpressionAttributeNames: map[string]*string{
-			// "#pk":          aws.String("pk"),
-			"#lastUpdated": aws.String("lastUpdated"),
+	names := map[string]*string{
+		"#pk":          aws.String("pk"),
+		"#meditation":  aws.String("meditation"),
+		"#ppk":         aws.String("ppk"),
+		"#pppk":        aws.String("pppk"),
+		"#lastUpdated": aws.String("lastUpdated"),
+		"#version":     aws.String("version"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":meditation": meditationAV,
+		":ppk": {
+			S: &record.Ppk,
 		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":lastUpdated": {
-				S: &record.UpdatedAt,
-			},
+		":pppk": {
+			S: &record.Pppk,
 		},
+		":lastUpdated": {
+			S: &record.UpdatedAt,
+		},
+		":nextVersion": {
+			N: aws.String(strconv.FormatInt(m.Version+1, 10)),
+		},
+	}
+	update := &dynamodb.Update{
+		TableName:                 aws.String(store.tableName),
+		Key:                       meditationKey(m.ID),
+		UpdateExpression:          aws.String("SET #meditation = :meditation, #ppk = :ppk, #pppk = :pppk, #lastUpdated = :lastUpdated, #version = :nextVersion"),
+		ConditionExpression:       aws.String("attribute_exists(#pk) AND " + versionCondition(m.Version, values)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
 	}
 
-	_, err = store.svc.PutItem(params)
+	err = store.transactWithRetry(store.batch(), []*dynamodb.TransactWriteItem{
+		{Update: update},
+		revision,
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrVersionMismatch
+	}
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
+	store.reindexTaxonomy("med#"+m.ID, meditationIndexKeys(oldMeditation), meditationIndexKeys(m), meditationIndexLabels(m))
+	store.reindexSearch("med#"+m.ID, meditationSearchDocument(oldMeditation), meditationSearchDocument(m))
 	return nil
 }
 
 func (store DynamoMeditationStore) SaveSequence(s Sequence) error {
-	// 1) save the sequence record
+	return store.saveSequence(s)
+}
+
+// saveSequence saves a new sequence, along with `also` in the same
+// transaction, if any.
+func (store DynamoMeditationStore) saveSequence(s Sequence, also ...*dynamodb.TransactWriteItem) error {
+	// 1) save the relation records first, so we fail before the sequence
+	// exists if any of its meditations have been deleted
 	sequenceRecord := mapSequenceToSequenceRecord(s)
+	added, removed, err := store.updateSequenceMeditationRelationRecords(sequenceRecord)
+	if err != nil {
+		return err
+	}
+
+	// 2) save the sequence record
 	sequenceItem, err := dynamodbattribute.MarshalMap(sequenceRecord)
 	if err != nil {
 		return err
 	}
-	params := &dynamodb.PutItemInput{
+	put := &dynamodb.Put{
 		TableName:           &store.tableName,
 		Item:                sequenceItem,
 		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
@@ -356,16 +456,22 @@ func (store DynamoMeditationStore) SaveSequence(s Sequence) error {
 			"#pk": aws.String("pk"),
 		},
 	}
-	_, err = store.svc.PutItem(params)
-	if err != nil {
-		return err
+	if len(also) == 0 {
+		_, err = store.svc.PutItem(&dynamodb.PutItemInput{
+			TableName:                put.TableName,
+			Item:                     put.Item,
+			ConditionExpression:      put.ConditionExpression,
+			ExpressionAttributeNames: put.ExpressionAttributeNames,
+		})
+	} else {
+		err = store.transactWithRetry(store.batch(), append([]*dynamodb.TransactWriteItem{{Put: put}}, also...))
 	}
-
-	// 2) save the relation records
-	err = store.updateSequenceMeditationRelationRecords(sequenceRecord)
 	if err != nil {
+		store.adjustMeditationReferences(s.ID, s.UserId, removed, added)
 		return err
 	}
+	store.reindexTaxonomy("seq#"+s.ID, []string{}, sequenceIndexKeys(s), nil)
+	store.reindexSearch("seq#"+s.ID, SearchDocument{}, sequenceSearchDocument(s))
 	return nil
 }
 
@@ -383,8 +489,11 @@ func dedupIds(ids []string) []string {
 	return deduppedIds
 }
 
-func (store DynamoMeditationStore) updateSequenceMeditationRelationRecords(seqRec *SequenceRecord) error {
-	// 1) delete all existing relation records
+// updateSequenceMeditationRelationRecords brings the relation records in line
+// with the sequence's meditations, returning the meditation ids it added and
+// removed.
+func (store DynamoMeditationStore) updateSequenceMeditationRelationRecords(seqRec *SequenceRecord) ([]string, []string, error) {
+	// 1) find the existing relation records
 	existingRelationRecordsQuery := &dynamodb.QueryInput{
 		TableName:              &store.tableName,
 		IndexName:              aws.String("gs1"),
@@ -406,88 +515,32 @@ func (store DynamoMeditationStore) updateSequenceMeditationRelationRecords(seqRe
 	//we don't need paging b/c 200 meditations shouldn't amount to 1mb of data
 	resp, err := store.svc.Query(existingRelationRecordsQuery)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	// TODO: batch this
-	wg0 := sync.WaitGroup{}
-	deleteErrors := make([]error, len(resp.Items))
-	for idx, item := range resp.Items {
-		deleteRequest := &dynamodb.DeleteItemInput{
-			TableName: &store.tableName,
-			Key: map[string]*dynamodb.AttributeValue{
-				"pk": item["pk"],
-				"sk": item["sk"],
-			},
-			ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
-		}
-		wg0.Add(1)
-		go func(deleteReq *dynamodb.DeleteItemInput, errIdx int, wg *sync.WaitGroup) {
-			defer wg.Done()
-			_, err := store.svc.DeleteItem(deleteReq)
-			if err != nil {
-				deleteErrors[errIdx] = err
-				return
-			}
-			deleteErrors[errIdx] = nil
-		}(deleteRequest, idx, &wg0)
-	}
-	wg0.Wait()
-	for _, err := range deleteErrors {
-		if err != nil {
-			return err
-		}
+	existing := make(map[string]bool)
+	for _, item := range resp.Items {
+		existing[strings.TrimPrefix(*item["pk"].S, "med#")] = true
 	}
 
-	// 2) create the writeRequests for the new relation records
-	MAX_BATCH_WRITE_ITEM_SIZE := 25
-	dedupped := dedupIds(seqRec.Sequence.MeditationIDs)
-	batchedMeditationIds := chunkMeditationIDs(dedupped, MAX_BATCH_WRITE_ITEM_SIZE)
-	batchWriteItemParamSlice := make([]*dynamodb.BatchWriteItemInput, len(batchedMeditationIds))
-	for batchIdx, meditationIds := range batchedMeditationIds {
-		items := make([]*dynamodb.WriteRequest, len(meditationIds))
-		for j, mID := range meditationIds {
-			m := Meditation{
-				ID: mID,
-			}
-			relationRecord := mapMeditationAndSequenceToMeditationAndSequenceRelationRecord(m, seqRec.Sequence.Sequence)
-			item, _ := dynamodbattribute.MarshalMap(relationRecord)
-			items[j] = &dynamodb.WriteRequest{
-				PutRequest: &dynamodb.PutRequest{
-					Item: item,
-				},
-			}
-		}
-
-		batchWriteItemParamSlice[batchIdx] = &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]*dynamodb.WriteRequest{
-				store.tableName: items,
-			},
+	// 2) diff them against the meditations the sequence now holds
+	wanted := make(map[string]bool)
+	added := []string{}
+	for _, id := range dedupIds(seqRec.Sequence.MeditationIDs) {
+		wanted[id] = true
+		if !existing[id] {
+			added = append(added, id)
 		}
 	}
-
-	// 3) write the relation records in parallel
-	wg1 := sync.WaitGroup{}
-	errs := make([]error, len(batchedMeditationIds))
-	for batchIdx, params := range batchWriteItemParamSlice {
-		wg1.Add(1)
-		go func(params *dynamodb.BatchWriteItemInput, idx int, wg *sync.WaitGroup) {
-			defer wg.Done()
-			_, err := store.svc.BatchWriteItem(params)
-			if err != nil {
-				errs[idx] = err
-				return
-			}
-			errs[idx] = nil
-		}(params, batchIdx, &wg1)
-	}
-	wg1.Wait()
-	for _, err := range errs {
-		if err != nil {
-			return err
+	removed := []string{}
+	for id := range existing {
+		if !wanted[id] {
+			removed = append(removed, id)
 		}
 	}
-	return nil
 
+	// 3) write the relation records alongside the meditation reference counts
+	err = store.adjustMeditationReferences(seqRec.Sequence.Sequence.ID, seqRec.Sequence.Sequence.UserId, added, removed)
+	return added, removed, err
 }
 
 func (store DynamoMeditationStore) GetSequenceIdsByMeditationId(meditationId string) ([]string, error) {
@@ -521,70 +574,106 @@ func (store DynamoMeditationStore) GetSequenceIdsByMeditationId(meditationId str
 	if *resp.Count == 0 {
 		return []string{}, nil
 	}
-	sequenceIds := make([]string, *resp.Count)
+	sequenceIds := make([]string, len(resp.Items))
 	for i, item := range resp.Items {
-		sequenceRecord := SequenceRecord{}
-		dynamodbattribute.UnmarshalMap(item, &sequenceRecord)
-		sequenceIds[i] = sequenceRecord.Sequence.Sequence.ID
+		sequenceIds[i] = strings.TrimPrefix(*item["sk"].S, "seq#")
 	}
 	return sequenceIds, nil
 }
 
 func (store DynamoMeditationStore) UpdateSequence(s Sequence) error {
-	sequenceRecord := mapSequenceToSequenceRecord(s)
-	sequenceItem, err := dynamodbattribute.MarshalMap(sequenceRecord)
+	// 1) make sure we're updating the version the caller read before
+	// touching any relation records
+	existing, err := store.getSequenceRecord(s.ID)
 	if err != nil {
 		return err
 	}
-	params := &dynamodb.PutItemInput{
-		TableName:           &store.tableName,
-		Item:                sequenceItem,
-		ConditionExpression: aws.String("attribute_exists(#pk) AND #lastUpdated <= :lastUpdated"),
-		ExpressionAttributeNames: map[string]*string{
-			"#pk":          aws.String("pk"),
-			"#lastUpdated": aws.String("lastUpdated"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":lastUpdated": {
-				S: &sequenceRecord.UpdatedAt,
-			},
-		},
+	if existing.Version != s.Version {
+		return ErrVersionMismatch
 	}
-	_, err = store.svc.PutItem(params)
+
+	sequenceRecord := mapSequenceToSequenceRecord(s)
+	sequenceRecord.Version = s.Version + 1
+	added, removed, err := store.updateSequenceMeditationRelationRecords(sequenceRecord)
 	if err != nil {
 		return err
 	}
 
-	store.updateSequenceMeditationRelationRecords(sequenceRecord)
+	// 2) save the sequence, provided nobody beat us to it
+	err = store.putSequenceRecordIfVersion(sequenceRecord, existing, s.Version)
+	if err != nil {
+		store.adjustMeditationReferences(s.ID, s.UserId, removed, added)
+		return err
+	}
+	store.reindexTaxonomy("seq#"+s.ID, sequenceIndexKeys(existing.toSequence()), sequenceIndexKeys(s), nil)
+	store.reindexSearch("seq#"+s.ID, sequenceSearchDocument(existing.toSequence()), sequenceSearchDocument(s))
 	return nil
 }
 
-func (store DynamoMeditationStore) DeleteSequenceById(sequenceId string) error {
-
-	sequenceRecord := mapSequenceToSequenceRecord(Sequence{
-		ID:          sequenceId,
-		Meditations: []Meditation{},
-	})
-
-	store.updateSequenceMeditationRelationRecords(sequenceRecord)
-
-	params := &dynamodb.DeleteItemInput{
-		TableName: &store.tableName,
-		Key: map[string]*dynamodb.AttributeValue{
-			"pk": {
-				S: &sequenceRecord.Pk,
-			},
-			"sk": {
-				S: &sequenceRecord.Sk,
-			},
-		},
+// putSequenceRecordIfVersion saves the record over `previous`, provided it is
+// still at `expectedVersion`, and keeps `previous` as a revision. It updates
+// in place rather than putting the whole record, so counters kept on the
+// record (e.g. forkCount) are left alone.
+func (store DynamoMeditationStore) putSequenceRecordIfVersion(sequenceRecord *SequenceRecord, previous SequenceRecord, expectedVersion int64) error {
+	daoAV, err := dynamodbattribute.Marshal(sequenceRecord.Sequence)
+	if err != nil {
+		return err
 	}
-	_, err := store.svc.DeleteItem(params)
-
+	revision, err := store.sequenceRevisionPut(previous, sequenceRecord.UpdatedAt)
 	if err != nil {
 		return err
 	}
-	return nil
+	names := map[string]*string{
+		"#pk":          aws.String("pk"),
+		"#seqDAO":      aws.String("seqDAO"),
+		"#ppk":         aws.String("ppk"),
+		"#pppk":        aws.String("pppk"),
+		"#lastUpdated": aws.String("lastUpdated"),
+		"#version":     aws.String("version"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":seqDAO": daoAV,
+		":ppk": {
+			S: aws.String(sequenceRecord.Ppk),
+		},
+		":lastUpdated": {
+			S: aws.String(sequenceRecord.UpdatedAt),
+		},
+		":nextVersion": {
+			N: aws.String(strconv.FormatInt(sequenceRecord.Version, 10)),
+		},
+	}
+	update := "SET #seqDAO = :seqDAO, #ppk = :ppk, #lastUpdated = :lastUpdated, #version = :nextVersion"
+	// private sequences have no pppk, which keeps them out of gs3
+	if sequenceRecord.Pppk != "" {
+		values[":pppk"] = &dynamodb.AttributeValue{
+			S: aws.String(sequenceRecord.Pppk),
+		}
+		update += ", #pppk = :pppk"
+	} else {
+		update += " REMOVE #pppk"
+	}
+
+	params := &dynamodb.Update{
+		TableName:                 &store.tableName,
+		Key:                       sequenceKey(sequenceRecord.Sequence.Sequence.ID),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("attribute_exists(#pk) AND " + versionCondition(expectedVersion, values)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	err = store.transactWithRetry(store.batch(), []*dynamodb.TransactWriteItem{
+		{Update: params},
+		revision,
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+func (store DynamoMeditationStore) DeleteSequenceById(sequenceId string) error {
+	return store.DeleteSequenceByIdIfVersion(sequenceId, AnyVersion)
 }
 
 func (store DynamoMeditationStore) ListSequencesByUserId(userId string) ([]Sequence, error) {
@@ -618,7 +707,7 @@ func (store DynamoMeditationStore) ListSequencesByUserId(userId string) ([]Seque
 	seqs := make([]Sequence, len(seqRecs))
 
 	for i, r := range seqRecs {
-		seqs[i] = r.Sequence.Sequence
+		seqs[i] = r.toSequence()
 	}
 
 	return seqs, nil
@@ -651,7 +740,7 @@ func (store DynamoMeditationStore) ListPublicSequences() ([]Sequence, error) {
 	seqs := make([]Sequence, len(seqRecs))
 
 	for i, r := range seqRecs {
-		seqs[i] = r.Sequence.Sequence
+		seqs[i] = r.toSequence()
 	}
 
 	return seqs, nil
@@ -683,74 +772,42 @@ func chunkMeditationIDs(meditationIDs []string, chunkSize int) [][]string {
 }
 
 func (store DynamoMeditationStore) GetMeditationsByIds(mIDs []string) ([]Meditation, error) {
-	// 1) split the meditationIDs into chunks of 100
-	MAX_BATCH_GET_ITEMS_SIZE := 100
+	// 1) build the keys for every distinct id
 	deduppedIds := dedupIds(mIDs)
-	batchedMeditations := chunkMeditationIDs(deduppedIds, MAX_BATCH_GET_ITEMS_SIZE)
-
-	// 2) build and collect the batchGetItem requests into a slice
-	batchCount := len(batchedMeditations)
-	params := make([]*dynamodb.BatchGetItemInput, batchCount)
-	for batchIdx, meditationIDs := range batchedMeditations {
-		keys := make([]map[string]*dynamodb.AttributeValue, len(meditationIDs))
-		for i, id := range meditationIDs {
-			m := Meditation{
-				ID: id,
-			}
-			r := mapMeditationToMeditationRecord(m)
-
-			keys[i] = map[string]*dynamodb.AttributeValue{
-				"pk": {
-					S: &r.Pk,
-				},
-				"sk": {
-					S: &r.Sk,
-				},
-			}
-		}
-
-		params[batchIdx] = &dynamodb.BatchGetItemInput{
-			RequestItems: map[string]*dynamodb.KeysAndAttributes{
-				store.tableName: {
-					Keys: keys,
-				},
+	keys := make([]map[string]*dynamodb.AttributeValue, len(deduppedIds))
+	for i, id := range deduppedIds {
+		r := mapMeditationToMeditationRecord(Meditation{ID: id})
+		keys[i] = map[string]*dynamodb.AttributeValue{
+			"pk": {
+				S: aws.String(r.Pk),
+			},
+			"sk": {
+				S: aws.String(r.Sk),
 			},
 		}
 	}
 
-	// 3) issue the BatchGetItem requests in parallel
-	results := make([][]map[string]*dynamodb.AttributeValue, batchCount)
-	wg := sync.WaitGroup{}
-	for batchIdx, getItemParams := range params {
-		wg.Add(1)
-		go func(params *dynamodb.BatchGetItemInput, batchIdx int, wg *sync.WaitGroup) {
-			resp, err := store.svc.BatchGetItem(params)
-			if err != nil {
-				results[batchIdx] = []map[string]*dynamodb.AttributeValue{}
-			}
-			r := resp.Responses[store.tableName]
-			results[batchIdx] = r
-			wg.Done()
-		}(getItemParams, batchIdx, &wg)
-	}
-	wg.Wait()
-
-	// 4) unmarshal the records into sequences
+	// 2) fetch them in batches, retrying unprocessed keys
+	items, err := store.batch().GetItems(keys)
+	if err != nil {
+		return []Meditation{}, err
+	}
+
+	// 3) unmarshal the records into meditations
+	var meditationRecords []MeditationRecord
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &meditationRecords)
+	if err != nil {
+		return []Meditation{}, err
+	}
 	meditations := []Meditation{}
-	for _, responseBatch := range results {
-		var meditationRecords []MeditationRecord
-		err := dynamodbattribute.UnmarshalListOfMaps(responseBatch, &meditationRecords)
-		if err != nil {
-			return []Meditation{}, err
+	for _, r := range meditationRecords {
+		// trashed meditations read as missing
+		if r.DeletedAt == "" {
+			meditations = append(meditations, r.toMeditation())
 		}
-		batchedMeditations := make([]Meditation, len(responseBatch))
-		for i, r := range meditationRecords {
-			batchedMeditations[i] = r.Meditation
-		}
-		meditations = append(meditations, batchedMeditations...)
 	}
 
-	// 5) reorder
+	// 4) reorder
 	idToMedMap := make(map[string]Meditation)
 	for _, m := range meditations {
 		idToMedMap[m.ID] = m
@@ -764,8 +821,47 @@ func (store DynamoMeditationStore) GetMeditationsByIds(mIDs []string) ([]Meditat
 	return reorderedMeditations, nil
 }
 
-func (store DynamoMeditationStore) GetSequenceById(sequenceId string) (Sequence, error) {
-	// 1) get the sequence
+// GetSequencesByIds returns the sequences with the given ids that exist and
+// aren't in the trash, in no particular order.
+func (store DynamoMeditationStore) GetSequencesByIds(sIDs []string) ([]Sequence, error) {
+	ids := dedupIds(sIDs)
+	keys := make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, id := range ids {
+		keys[i] = sequenceKey(id)
+	}
+	items, err := store.batch().GetItems(keys)
+	if err != nil {
+		return []Sequence{}, err
+	}
+	records := []SequenceRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return []Sequence{}, err
+	}
+	sequences := []Sequence{}
+	for _, r := range records {
+		if r.DeletedAt == "" {
+			sequences = append(sequences, r.toSequence())
+		}
+	}
+	return sequences, nil
+}
+
+// getSequenceRecord reads the sequence's record, failing with
+// ErrSequenceNotFound if there is none or it is in the trash.
+func (store DynamoMeditationStore) getSequenceRecord(sequenceId string) (SequenceRecord, error) {
+	record, err := store.getAnySequenceRecord(sequenceId)
+	if err != nil {
+		return SequenceRecord{}, err
+	}
+	if record.DeletedAt != "" {
+		return SequenceRecord{}, fmt.Errorf("%w for id %s", ErrSequenceNotFound, sequenceId)
+	}
+	return record, nil
+}
+
+// getAnySequenceRecord is getSequenceRecord, trashed or not.
+func (store DynamoMeditationStore) getAnySequenceRecord(sequenceId string) (SequenceRecord, error) {
 	s := Sequence{
 		ID: sequenceId,
 	}
@@ -783,26 +879,35 @@ func (store DynamoMeditationStore) GetSequenceById(sequenceId string) (Sequence,
 	}
 	seqResp, err := store.svc.GetItem(params)
 	if err != nil {
-		return Sequence{}, err
+		return SequenceRecord{}, err
 	}
 	if seqResp.Item == nil {
-		return Sequence{}, errors.New("no sequence found for id " + sequenceId)
+		return SequenceRecord{}, fmt.Errorf("%w for id %s", ErrSequenceNotFound, sequenceId)
 	}
 
-	// 2) unmarshal the response
 	var sequenceRecord SequenceRecord
 	err = dynamodbattribute.UnmarshalMap(seqResp.Item, &sequenceRecord)
+	if err != nil {
+		return SequenceRecord{}, err
+	}
+	return sequenceRecord, nil
+}
+
+func (store DynamoMeditationStore) GetSequenceById(sequenceId string) (Sequence, error) {
+	// 1) get the sequence
+	sequenceRecord, err := store.getSequenceRecord(sequenceId)
 	if err != nil {
 		return Sequence{}, err
 	}
 
-	// 3) inflate the list of meditations
+	// 2) inflate the list of meditations
 	meditationIDs := sequenceRecord.Sequence.MeditationIDs
 	meditations, err := store.GetMeditationsByIds(meditationIDs)
 	if err != nil {
 		return Sequence{}, err
 	}
-	fetchedSequence := sequenceRecord.Sequence.Sequence
-	fetchedSequence.Meditations = meditations
+	fetchedSequence := sequenceRecord.toSequence()
+	fetchedSequence.Meditations = redactUnavailable(meditationIDs, meditations, fetchedSequence.UserId)
+	fetchedSequence.Steps, fetchedSequence.TotalDuration = timeSteps(fetchedSequence.Steps, fetchedSequence.Meditations)
 	return fetchedSequence, nil
 }
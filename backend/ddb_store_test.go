@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"sync"
 	"testing"
@@ -76,13 +78,12 @@ func createLocalDynamoTable(tableName string) {
 }
 
 func contains(meditations []Meditation, meditationToFind Meditation) bool {
-	var match Meditation
 	for _, m := range meditations {
-		if m == meditationToFind {
-			match = m
+		if deep.Equal(m, meditationToFind) == nil {
+			return true
 		}
 	}
-	return match != Meditation{}
+	return false
 }
 
 func initializeTestingStore(tableName string) *DynamoMeditationStore {
@@ -130,7 +131,7 @@ func TestDynamoMeditationStore(t *testing.T) {
 		if err != nil {
 			t.Error("SaveMeditation and GetMeditation failed")
 		}
-		if m2 != m {
+		if diff := deep.Equal(m, m2); diff != nil {
 			t.Errorf("Expected \n%+v \n\nGot\n %+v", m, m2)
 		}
 	})
@@ -180,6 +181,7 @@ func TestDynamoMeditationStore(t *testing.T) {
 		}
 		m.Name = "Changed"
 		store.UpdateMeditation(m)
+		m.Version++ // every update moves the record on a version
 
 		meditations, err := store.ListMeditations(userId)
 		if err != nil {
@@ -232,245 +234,1412 @@ func TestDynamoMeditationStore(t *testing.T) {
 		}
 	})
 
+	t.Run("Stale meditation updates are rejected", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		store.SaveMeditation(Meditation{
+			UserId: "alex",
+			Name:   "Meditation",
+			ID:     "0",
+		})
+		first, _ := store.GetMeditation("0")
+		second := first
+
+		first.Name = "First edit"
+		err := store.UpdateMeditation(first)
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		second.Name = "Second edit"
+		err = store.UpdateMeditation(second)
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch, got %v", err)
+		}
+
+		stored, _ := store.GetMeditation("0")
+		if stored.Name != "First edit" || stored.Version != 1 {
+			t.Errorf("Expected the first edit at version 1, got %+v", stored)
+		}
+	})
+
+	t.Run("Updates keep what they replace as a revision", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		store.SaveMeditation(Meditation{
+			UserId: "alex",
+			Name:   "Meditation",
+			Text:   "If you wish to pray",
+			ID:     "0",
+		})
+		m, _ := store.GetMeditation("0")
+		for _, text := range []string{"If you wish to pray truly", "Pray"} {
+			m.Text = text
+			err := store.UpdateMeditation(m)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			m.Version++
+		}
+
+		revisions, err := store.ListMeditationRevisions("0")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(revisions) != 2 || revisions[0].Version != 1 || revisions[1].Version != 0 {
+			t.Fatalf("Expected versions 1 and 0 newest first, got %+v", revisions)
+		}
+		if revisions[1].Meditation.Text != "If you wish to pray" || revisions[1].Meditation.Version != 0 {
+			t.Errorf("Unexpected revision %+v", revisions[1].Meditation)
+		}
+		revision, err := store.GetMeditationRevision("0", 1)
+		if err != nil || revision.Meditation.Text != "If you wish to pray truly" {
+			t.Errorf("Unexpected revision %+v %v", revision, err)
+		}
+		_, err = store.GetMeditationRevision("0", 2)
+		if !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+		}
+
+		// revisions aren't meditations
+		meditations, _ := store.ListMeditations("alex")
+		if len(meditations) != 1 {
+			t.Errorf("Expected 1 meditation, got %d", len(meditations))
+		}
+
+		// a stale update leaves no revision behind
+		m.Version = 0
+		err = store.UpdateMeditation(m)
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch, got %v", err)
+		}
+		revisions, _ = store.ListMeditationRevisions("0")
+		if len(revisions) != 2 {
+			t.Errorf("Expected 2 revisions, got %d", len(revisions))
+		}
+	})
+
+	t.Run("Scheduled items are published once due", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		publishAt := now.Add(time.Hour)
+
+		meditations := createMeditations(2, "alex", store)
+		// approved ahead of time
+		meditations[0].setPublication(Publication{Status: StatusScheduled, PublishAt: &publishAt})
+		meditations[0].moderate(Moderation{Approved: true, At: now})
+		err := store.UpdateMeditation(meditations[0])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sequenceId := ksuid.New().String()
+		sequence := Sequence{
+			ID:          sequenceId,
+			Name:        "Lent",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations[1:],
+		}
+		sequence.setPublication(Publication{Status: StatusScheduled, PublishAt: &publishAt})
+		sequence.moderate(Moderation{Approved: true, At: now})
+		err = store.SaveSequence(sequence)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// nothing is public before its time
+		published, err := store.PublishDue(now)
+		if err != nil || len(published) != 0 {
+			t.Errorf("Expected nothing due yet, got %v %v", published, err)
+		}
+		public, _ := store.ListPublicMeditations()
+		publicSequences, _ := store.ListPublicSequences()
+		if len(public) != 0 || len(publicSequences) != 0 {
+			t.Errorf("Expected no public items, got %d and %d", len(public), len(publicSequences))
+		}
+
+		published, err = store.PublishDue(publishAt)
+		if err != nil || len(published) != 2 {
+			t.Errorf("Expected both items published, got %v %v", published, err)
+		}
+		m, _ := store.GetMeditation(meditations[0].ID)
+		if m.Status != StatusPublished || !m.Public || m.PublishAt != nil {
+			t.Errorf("Unexpected meditation %+v", m)
+		}
+		public, _ = store.ListPublicMeditations()
+		publicSequences, _ = store.ListPublicSequences()
+		if len(public) != 1 || len(publicSequences) != 1 {
+			t.Errorf("Expected 1 public meditation and sequence, got %d and %d", len(public), len(publicSequences))
+		}
+
+		// and only once
+		published, _ = store.PublishDue(publishAt)
+		if len(published) != 0 {
+			t.Errorf("Expected nothing left to publish, got %v", published)
+		}
+	})
+
+	t.Run("Enough reports hide an item", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		os.Setenv("REPORT_HIDE_THRESHOLD", "2")
+		defer os.Unsetenv("REPORT_HIDE_THRESHOLD")
+		now := time.Now()
+
+		meditations := createMeditations(1, "alex", store)
+		m := meditations[0]
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: now})
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pk := "med#" + m.ID
+
+		err = store.ReportItem(pk, "a", Report{Reason: "spam", ReportedAt: now})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err = store.ReportItem(pk, "a", Report{Reason: "spam", ReportedAt: now}); !errors.Is(err, ErrAlreadyReported) {
+			t.Errorf("Expected ErrAlreadyReported, got %v", err)
+		}
+		if reported, err := store.HasReported(pk, "a"); err != nil || !reported {
+			t.Errorf("Expected a's report found, got %v %v", reported, err)
+		}
+		if reported, _ := store.HasReported(pk, "b"); reported {
+			t.Error("Expected b not to have reported")
+		}
+		if reported, _ := store.GetMeditation(m.ID); !reported.Public {
+			t.Error("Expected one report to leave it public")
+		}
+
+		err = store.ReportItem(pk, "b", Report{Reason: "abuse", Details: "insults", ReportedAt: now.Add(time.Second)})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		hidden, _ := store.GetMeditation(m.ID)
+		if hidden.Public || !awaitingReview(hidden.Status, hidden.Moderation) {
+			t.Errorf("Expected it hidden for review, got %+v", hidden)
+		}
+		items, err := store.ListReportedItems()
+		if err != nil || len(items) != 1 {
+			t.Fatalf("Expected 1 reported item, got %+v %v", items, err)
+		}
+		if items[0].Count != 2 || !items[0].Hidden || items[0].Reasons["abuse"] != 1 || items[0].Reports[0].Details != "insults" {
+			t.Errorf("Unexpected reported item %+v", items[0])
+		}
+
+		err = store.ClearReports(pk)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if err = store.ClearReports(pk); !errors.Is(err, ErrNoReports) {
+			t.Errorf("Expected ErrNoReports, got %v", err)
+		}
+		if items, _ = store.ListReportedItems(); len(items) != 0 {
+			t.Errorf("Expected no reported items, got %+v", items)
+		}
+	})
+
+	t.Run("Rate limits count requests per window", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+
+		for i := 0; i < 2; i++ {
+			if err := store.takeRateLimit("report#a", 2, time.Hour, now); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if err := store.takeRateLimit("report#a", 2, time.Hour, now); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+		if err := store.takeRateLimit("report#b", 2, time.Hour, now); err != nil {
+			t.Errorf("Expected another key to have its own limit, got %v", err)
+		}
+		if err := store.takeRateLimit("report#a", 2, time.Hour, now.Add(time.Hour)); err != nil {
+			t.Errorf("Expected a new window to start afresh, got %v", err)
+		}
+	})
+
+	t.Run("Published items wait in the moderation queue", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+
+		meditations := createMeditations(2, "alex", store)
+		for i := range meditations {
+			meditations[i].setPublication(Publication{Status: StatusPublished})
+			err := store.UpdateMeditation(meditations[i])
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			meditations[i].Version++
+		}
+		queue, err := store.ListModerationQueue()
+		if err != nil || len(queue) != 2 || queue[0].Type != LibraryMeditation {
+			t.Fatalf("Expected both meditations queued, got %+v %v", queue, err)
+		}
+		public, _ := store.ListPublicMeditations()
+		if len(public) != 0 {
+			t.Errorf("Expected nothing public before review, got %d", len(public))
+		}
+
+		meditations[0].moderate(Moderation{Approved: true, At: now})
+		meditations[1].moderate(Moderation{Reason: "no audio", At: now})
+		for _, m := range meditations {
+			err = store.UpdateMeditation(m)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		queue, _ = store.ListModerationQueue()
+		public, _ = store.ListPublicMeditations()
+		if len(queue) != 0 || len(public) != 1 || public[0].ID != meditations[0].ID {
+			t.Errorf("Expected only the approved meditation public, got %+v and %+v", queue, public)
+		}
+		rejected, _ := store.GetMeditation(meditations[1].ID)
+		if rejected.Status != StatusUnpublished || rejected.ModerationNote != "no audio" {
+			t.Errorf("Unexpected rejected meditation %+v", rejected)
+		}
+
+		// notifications
+		first, err := store.SaveNotification(Notification{UserId: "alex", CreatedAt: now, Type: NotificationModeration, ItemID: meditations[0].ID, Result: ModerationApproved})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, err = store.SaveNotification(Notification{UserId: "alex", CreatedAt: now.Add(time.Second), Type: NotificationModeration, ItemID: meditations[1].ID, Result: ModerationRejected})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		notifications, err := store.ListNotifications("alex")
+		if err != nil || len(notifications) != 2 || notifications[0].ItemID != meditations[1].ID {
+			t.Errorf("Expected 2 notifications newest first, got %+v %v", notifications, err)
+		}
+		err = store.DeleteNotification("alex", first.ID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if err = store.DeleteNotification("alex", first.ID); !errors.Is(err, ErrNotificationNotFound) {
+			t.Errorf("Expected ErrNotificationNotFound, got %v", err)
+		}
+		if notifications, _ = store.ListNotifications("alex"); len(notifications) != 1 {
+			t.Errorf("Expected 1 notification left, got %d", len(notifications))
+		}
+	})
+
+	t.Run("Edited approved items leave the public indexes until reviewed", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		member := Principal{UserId: "alex", Roles: []string{RoleMember}}
+
+		m := createMeditations(1, "alex", store)[0]
+		m.Text = "Pray without ceasing."
+		m.Tags = []string{"evagrius"}
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: now})
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m.Version++
+		hits, _ := store.SearchPublic(parseSearchQuery("ceasing"), "", 10)
+		tagged, _ := store.ListPublicMeditationsByIndex("tag#evagrius")
+		if len(hits) != 1 || len(tagged) != 1 {
+			t.Fatalf("Expected the approved meditation indexed, got %+v and %+v", hits, tagged)
+		}
+
+		before := m
+		m.Text = "Pray without ceasing, and buy my book."
+		m.reviewEdits(member, before)
+		err = store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery("ceasing"), "", 10)
+		tagged, _ = store.ListPublicMeditationsByIndex("tag#evagrius")
+		public, _ := store.ListPublicMeditations()
+		if len(hits) != 0 || len(tagged) != 0 || len(public) != 0 {
+			t.Errorf("Expected the edit out of the public indexes, got %+v, %+v and %+v", hits, tagged, public)
+		}
+		queue, _ := store.ListModerationQueue()
+		if len(queue) != 1 || queue[0].ID != m.ID {
+			t.Errorf("Expected the edit in the moderation queue, got %+v", queue)
+		}
+
+		// as are sequences whose items change
+		s := Sequence{
+			ID:          ksuid.New().String(),
+			Name:        "Sequence",
+			Description: "Rest in stillness",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		s.setPublication(Publication{Status: StatusPublished})
+		s.moderate(Moderation{Approved: true, At: now})
+		err = store.SaveSequence(s)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = store.InsertSequenceItem(s.ID, 0, true, SequenceStep{Type: StepText, Text: "Amen"}, 0)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery("stillness"), "", 10)
+		sequences, _ := store.ListPublicSequences()
+		if len(hits) != 0 || len(sequences) != 0 {
+			t.Errorf("Expected the sequence out of the public indexes, got %+v and %+v", hits, sequences)
+		}
+	})
+
+	t.Run("Tags are indexed while public", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		meditations := createMeditations(3, "alex", store)
+		for i := range meditations {
+			meditations[i].Public = true
+			meditations[i].Tags = []string{"evagrius"}
+			meditations[i].Categories = []string{"patristic"}
+			if i == 0 {
+				meditations[i].Tags = append(meditations[i].Tags, "prayer-rope")
+			}
+			err := store.UpdateMeditation(meditations[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			meditations[i].Version++
+		}
+
+		tagged, err := store.ListPublicMeditationsByIndex("tag#evagrius")
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(tagged) != 3 {
+			t.Errorf("Expected %d tagged meditations, got %d", 3, len(tagged))
+		}
+
+		// going private or being deleted takes a meditation out of the index
+		meditations[1].Public = false
+		err = store.UpdateMeditation(meditations[1])
+		if err != nil {
+			t.Error(err.Error())
+		}
+		err = store.DeleteMeditation(meditations[2].ID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		filed, _ := store.ListPublicMeditationsByIndex("cat#patristic")
+		if len(filed) != 1 || filed[0].ID != meditations[0].ID {
+			t.Errorf("Expected only %s in patristic, got %+v", meditations[0].ID, filed)
+		}
+
+		cloud, err := store.GetTagCloud()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(cloud.Tags) != 2 || cloud.Tags[0].Count != 1 {
+			t.Errorf("Unexpected tag cloud %+v", cloud.Tags)
+		}
+		if len(cloud.Categories) != len(Categories) {
+			t.Errorf("Expected every category in the cloud, got %+v", cloud.Categories)
+		}
+	})
+
+	t.Run("A lost tag index write is made up on the next save", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		m := createMeditations(1, "alex", store)[0]
+		m.Public = true
+		m.Tags = []string{"evagrius"}
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m.Version++
+
+		// as if the index write had failed after the meditation was saved
+		err = store.syncTaxonomyIndex("med#"+m.ID, meditationIndexKeys(m), []string{}, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tagged, _ := store.ListPublicMeditationsByIndex("tag#evagrius")
+		if len(tagged) != 0 {
+			t.Fatalf("Expected the index record gone, got %+v", tagged)
+		}
+
+		m.Name = "Renamed"
+		err = store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m.Version++
+		tagged, _ = store.ListPublicMeditationsByIndex("tag#evagrius")
+		if len(tagged) != 1 {
+			t.Errorf("Expected the index record rewritten, got %+v", tagged)
+		}
+		cloud, _ := store.GetTagCloud()
+		if len(cloud.Tags) != 1 || cloud.Tags[0].Count != 1 {
+			t.Errorf("Unexpected tag cloud %+v", cloud.Tags)
+		}
+
+		// a stray index record is cleared out too
+		err = store.syncTaxonomyIndex("med#"+m.ID, []string{}, []string{"tag#stray"}, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		stray, _ := store.ListPublicMeditationsByIndex("tag#stray")
+		if len(stray) != 0 {
+			t.Errorf("Expected the stray index record gone, got %+v", stray)
+		}
+	})
+
+	t.Run("Public meditations can be browsed by author and work", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		meditations := createMeditations(3, "alex", store)
+		citations := []Citation{
+			{Author: "Evagrius Ponticus", Work: "On Prayer", Section: "153"},
+			{Author: "Evagrius Ponticus", Work: "Praktikos"},
+			{Work: "Philippians", Section: "4:4"},
+		}
+		for i := range meditations {
+			meditations[i].Public = true
+			meditations[i].Citation = &citations[i]
+			err := store.UpdateMeditation(meditations[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			meditations[i].Version++
+		}
+
+		authors, err := store.ListCitationIndex("author#")
+		if err != nil {
+			t.Error(err.Error())
+		}
+		expected := []CitationCount{{Key: "evagrius-ponticus", Name: "Evagrius Ponticus", Count: 2}}
+		if diff := deep.Equal(authors, expected); diff != nil {
+			t.Error(diff)
+		}
+		works, _ := store.ListCitationIndex("work#")
+		if len(works) != 3 || works[0].Name != "On Prayer" {
+			t.Errorf("Unexpected works %+v", works)
+		}
+		cited, _ := store.ListPublicMeditationsByIndex("work#philippians")
+		if len(cited) != 1 || cited[0].ID != meditations[2].ID {
+			t.Errorf("Expected only %s to cite Philippians, got %+v", meditations[2].ID, cited)
+		}
+	})
+
+	t.Run("Public meditations are searchable", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		meditations := createMeditations(3, "alex", store)
+		texts := []string{
+			"Lord Jesus Christ, have mercy on me. The prayer of the heart.",
+			"The heart that prays is the heart at rest.",
+			"Pray without ceasing.",
+		}
+		for i := range meditations {
+			meditations[i].Public = true
+			meditations[i].Text = texts[i]
+			err := store.UpdateMeditation(meditations[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			meditations[i].Version++
+		}
+
+		hits, err := store.SearchPublic(parseSearchQuery("praying"), "", 10)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(hits) != 3 {
+			t.Errorf("Expected %d hits, got %+v", 3, hits)
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery(`"prayer of the heart"`), "", 10)
+		if len(hits) != 1 || hits[0].Sk != "med#"+meditations[0].ID {
+			t.Errorf("Expected only %s to match the phrase, got %+v", meditations[0].ID, hits)
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery("heart"), "", 10)
+		if len(hits) != 2 || hits[0].Sk != "med#"+meditations[1].ID {
+			t.Errorf("Expected %s to rank first, got %+v", meditations[1].ID, hits)
+		}
+
+		// editing, going private or being deleted updates the index
+		meditations[0].Text = "Be still."
+		err = store.UpdateMeditation(meditations[0])
+		if err != nil {
+			t.Error(err.Error())
+		}
+		meditations[1].Public = false
+		err = store.UpdateMeditation(meditations[1])
+		if err != nil {
+			t.Error(err.Error())
+		}
+		err = store.DeleteMeditation(meditations[2].ID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery("pray"), "", 10)
+		if len(hits) != 0 {
+			t.Errorf("Expected no hits, got %+v", hits)
+		}
+		hits, _ = store.SearchPublic(parseSearchQuery("still"), "seq#", 10)
+		if len(hits) != 0 {
+			t.Errorf("Expected no sequence hits, got %+v", hits)
+		}
+		stats, _ := store.getSearchStats()
+		if stats.Documents != 1 {
+			t.Errorf("Expected %d indexed document, got %d", 1, stats.Documents)
+		}
+	})
+
+	t.Run("A failed search index sync is made up on the next save", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		m := createMeditations(1, "alex", store)[0]
+		m.Public = true
+		m.Text = "Pray without ceasing."
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m.Version++
+
+		// as if the postings had been written but neither the statistics
+		// nor one of the postings were
+		_, err = store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key:       searchDocumentKey("med#" + m.ID),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, err = store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"pk": {S: aws.String("term#" + stem("ceasing"))},
+				"sk": {S: aws.String("med#" + m.ID)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, err = store.svc.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"pk": {S: aws.String(SEARCH_STATS_PK)},
+				"sk": {S: aws.String(SEARCH_STATS_SK)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		m.Name = "Renamed"
+		err = store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m.Version++
+		stats, _ := store.getSearchStats()
+		_, length := indexDocument(meditationSearchDocument(m))
+		if stats.Documents != 1 || stats.Words != length {
+			t.Errorf("Expected 1 document of %d words, got %+v", length, stats)
+		}
+		hits, _ := store.SearchPublic(parseSearchQuery("ceasing"), "", 10)
+		if len(hits) != 1 {
+			t.Errorf("Expected the posting rewritten, got %+v", hits)
+		}
+
+		// and counted once however often it's saved
+		err = store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		stats, _ = store.getSearchStats()
+		if stats.Documents != 1 || stats.Words != length {
+			t.Errorf("Expected 1 document of %d words, got %+v", length, stats)
+		}
+	})
+
 	t.Run("Test Public Meditations", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
 
-		users := []string{"gregory", "alexandria", "maximus"}
+		users := []string{"gregory", "alexandria", "maximus"}
+
+		numMeditations := 3
+		for _, user := range users {
+			for i := 0; i < numMeditations; i++ {
+				store.SaveMeditation(Meditation{
+					UserId: user,
+					Name:   "Meditation Private " + strconv.Itoa(i),
+					ID:     uuid.NewV4().String(),
+					Public: false,
+				})
+				store.SaveMeditation(Meditation{
+					UserId: user,
+					Name:   "Meditation Public " + strconv.Itoa(i),
+					ID:     uuid.NewV4().String(),
+					Public: true,
+				})
+			}
+		}
+
+		expectedPublicMeditations := len(users) * numMeditations
+		publicMeditations, err := store.ListPublicMeditations()
+
+		if err != nil {
+			t.Error("Problem listing public meditations")
+			t.Error(err.Error())
+		}
+		if len(publicMeditations) != expectedPublicMeditations {
+			t.Errorf("Expected %d meditations, got %d meditations", expectedPublicMeditations, len(publicMeditations))
+		}
+
+	})
+}
+
+func TestChunker(t *testing.T) {
+	strCount := 1000
+	strs := make([]string, strCount)
+	for i := 0; i < strCount; i++ {
+		strs[i] = strconv.Itoa(i)
+	}
+	batches := chunkMeditationIDs(strs, 100)
+	if len(batches) != 10 {
+		t.Errorf("Expected %d batches got %d", 10, len(batches))
+	}
+	if len(batches[2]) >= 101 {
+		t.Errorf("Batcher failed, expected len(batches[0]) <= 100, but got %d", len(batches[0]))
+	}
+}
+
+func createMeditations(count int, userId string, store *DynamoMeditationStore) []Meditation {
+	now := time.Now()
+
+	meditations := make([]Meditation, count)
+	ids := make([]string, count)
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		id := "seqMed" + strconv.Itoa(i)
+		ids[i] = id
+		m := Meditation{
+			ID:        id,
+			Name:      fmt.Sprintf("Meditation %d", i),
+			Text:      "Meditation Text",
+			URL:       "http://mp3.com/1.mp3",
+			Public:    false,
+			UserId:    userId,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		meditations[i] = m
+
+	}
+
+	for _, m := range meditations {
+		wg.Add(1)
+		go func(m Meditation, wg *sync.WaitGroup) {
+			store.SaveMeditation(m)
+			wg.Done()
+		}(m, &wg)
+	}
+	wg.Wait()
+	return meditations
+}
+
+// plainSteps is what a sequence saved with only meditations reads back as.
+func plainSteps(meditations []Meditation) []SequenceStep {
+	steps := make([]SequenceStep, len(meditations))
+	for i, m := range meditations {
+		steps[i] = SequenceStep{Type: StepMeditation, MeditationID: m.ID}
+	}
+	return steps
+}
+
+func TestSequences(t *testing.T) {
+
+	t.Run("Create a sequence, get a sequence, update a sequence, delete a sequence", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		userId := "alex"
+
+		meditations := createMeditations(10, "alex", store)
+		mCount := len(meditations)
+
+		sequenceId := ksuid.New().String()
+		sequence := Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence 1",
+			Description: "A Testing Sequence",
+			ImageURL:    "https://image.url/",
+			Public:      false,
+			UserId:      userId,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations,
+		}
+		err := store.SaveSequence(sequence)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		sequence.Steps = plainSteps(sequence.Meditations)
+
+		fetchedSequence, err := store.GetSequenceById(sequenceId)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
+			t.Error(diff)
+			return
+		}
+		expectedId := sequence.Meditations[0].ID
+		actualId := fetchedSequence.Meditations[0].ID
+		if expectedId != actualId {
+			t.Errorf("First meditations don't match.")
+			t.Errorf("expected %s got %s", expectedId, actualId)
+			t.Errorf("%+v", len(fetchedSequence.Meditations))
+		}
+		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
+			t.Error("Expected fetchedSequence to match original sequence")
+			t.Error(diff)
+		}
+
+		// update the sequence
+		updatedSequence := sequence
+		updatedSequence.Meditations = meditations[0 : mCount/2]
+		err = store.UpdateSequence(updatedSequence)
+		if err != nil {
+			t.Error(err)
+		}
+
+		updatedFetchedSequence, _ := store.GetSequenceById(sequenceId)
+		if len(updatedFetchedSequence.Meditations) != mCount/2 {
+			t.Errorf("did not find 100 updated meditations: %d", len(updatedFetchedSequence.Meditations))
+		}
+
+		// delete the sequence
+		err = store.DeleteSequenceById(sequenceId)
+		if err != nil {
+			t.Error("deletion failed")
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("Create a sequence with a duplicate med ID succeeds", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		userId := "alex"
+
+		meditations := createMeditations(2, "alex", store)
 
-		numMeditations := 3
-		for _, user := range users {
-			for i := 0; i < numMeditations; i++ {
-				store.SaveMeditation(Meditation{
-					UserId: user,
-					Name:   "Meditation Private " + strconv.Itoa(i),
-					ID:     uuid.NewV4().String(),
-					Public: false,
-				})
-				store.SaveMeditation(Meditation{
-					UserId: user,
-					Name:   "Meditation Public " + strconv.Itoa(i),
-					ID:     uuid.NewV4().String(),
-					Public: true,
-				})
+		sequenceId := ksuid.New().String()
+		sequence := Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence 1",
+			Description: "A Testing Sequence",
+			ImageURL:    "https://image.url/",
+			Public:      false,
+			UserId:      userId,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: append(meditations, meditations[0]),
+		}
+		err := store.SaveSequence(sequence)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		sequence.Steps = plainSteps(sequence.Meditations)
+		fetchedSequence, err := store.GetSequenceById(sequenceId)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
+			t.Error(diff)
+		}
+
+	})
+
+	t.Run("Get a nonexistent sequence fails", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		_, err := store.GetSequenceById("DOES_NOT_EXIST")
+		if err == nil {
+			t.Error("expected an error for non-existent get, but got nil")
+		}
+	})
+
+	t.Run("Disallow deletion of meditation if it's in a sequence", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		userId := "alex"
+
+		meditations := createMeditations(3, "alex", store)
+		sequenceId := ksuid.New().String()
+		sequence := Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence 1",
+			Description: "A Testing Sequence",
+			ImageURL:    "https://image.url/",
+			Public:      false,
+			UserId:      userId,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations,
+		}
+		err := store.SaveSequence(sequence)
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		m := meditations[0]
+		err = store.DeleteMeditation(m.ID)
+		if err == nil {
+			t.Error("should not have allowed meditation to be deleted because it is part of a sequence!")
+		}
+		updatedSequence := sequence
+		updatedSequence.Meditations = meditations[1:]
+		err = store.UpdateSequence(updatedSequence)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		err = store.DeleteMeditation(m.ID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+	})
+
+	t.Run("Cascade deletion removes the meditation from its sequences", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		userId := "alex"
+
+		meditations := createMeditations(3, userId, store)
+		sequenceIds := []string{ksuid.New().String(), ksuid.New().String()}
+		for _, sequenceId := range sequenceIds {
+			err := store.SaveSequence(Sequence{
+				ID:          sequenceId,
+				Name:        "Sequence",
+				Description: "A Testing Sequence",
+				UserId:      userId,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+				Meditations: meditations,
+			})
+			if err != nil {
+				t.Error(err.Error())
 			}
 		}
 
-		expectedPublicMeditations := len(users) * numMeditations
-		publicMeditations, err := store.ListPublicMeditations()
+		m := meditations[1]
+		err := store.DeleteMeditation(m.ID)
+		if !errors.Is(err, ErrMeditationInUse) {
+			t.Errorf("Expected ErrMeditationInUse, got %v", err)
+		}
+		err = store.DeleteMeditationCascade(m.ID, AnyVersion)
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		for _, sequenceId := range sequenceIds {
+			s, err := store.GetSequenceById(sequenceId)
+			if err != nil {
+				t.Error(err.Error())
+				continue
+			}
+			if len(s.Meditations) != 2 {
+				t.Errorf("Expected %d meditations, got %d", 2, len(s.Meditations))
+			}
+			for _, remaining := range s.Meditations {
+				if remaining.ID == m.ID {
+					t.Error("Found deleted meditation still in sequence " + sequenceId)
+				}
+			}
+		}
+	})
+
+	t.Run("Saving a sequence with a deleted meditation fails", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
 
+		meditations := createMeditations(2, "alex", store)
+		err := store.DeleteMeditation(meditations[0].ID)
 		if err != nil {
-			t.Error("Problem listing public meditations")
 			t.Error(err.Error())
 		}
-		if len(publicMeditations) != expectedPublicMeditations {
-			t.Errorf("Expected %d meditations, got %d meditations", expectedPublicMeditations, len(publicMeditations))
+
+		sequenceId := ksuid.New().String()
+		err = store.SaveSequence(Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence",
+			Description: "A Testing Sequence",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations,
+		})
+		if !errors.Is(err, ErrMeditationMissing) {
+			t.Errorf("Expected ErrMeditationMissing, got %v", err)
+		}
+		_, err = store.GetSequenceById(sequenceId)
+		if err == nil {
+			t.Error("Expected the sequence not to have been saved")
+		}
+	})
+
+	t.Run("Sequence edits keep revisions", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+
+		meditations := createMeditations(2, "alex", store)
+		sequenceId := ksuid.New().String()
+		err := store.SaveSequence(Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations[:1],
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sequence, _ := store.GetSequenceById(sequenceId)
+		sequence.Name = "Renamed"
+		err = store.UpdateSequence(sequence)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = store.InsertSequenceItem(sequenceId, 1, true, SequenceStep{Type: StepMeditation, MeditationID: meditations[1].ID}, 1)
+		if err != nil {
+			t.Fatal(err.Error())
 		}
 
+		revisions, err := store.ListSequenceRevisions(sequenceId)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(revisions) != 2 {
+			t.Fatalf("Expected 2 revisions, got %+v", revisions)
+		}
+		if revisions[1].Sequence.Name != "Sequence" || len(revisions[0].Sequence.Steps) != 1 || revisions[0].Sequence.Name != "Renamed" {
+			t.Errorf("Unexpected revisions %+v %+v", revisions[0].Sequence, revisions[1].Sequence)
+		}
+
+		// relation records under the meditations are left alone
+		ids, _ := store.GetSequenceIdsByMeditationId(meditations[0].ID)
+		if len(ids) != 1 || ids[0] != sequenceId {
+			t.Errorf("Unexpected sequence ids %v", ids)
+		}
 	})
-}
 
-func TestChunker(t *testing.T) {
-	strCount := 1000
-	strs := make([]string, strCount)
-	for i := 0; i < strCount; i++ {
-		strs[i] = strconv.Itoa(i)
-	}
-	batches := chunkMeditationIDs(strs, 100)
-	if len(batches) != 10 {
-		t.Errorf("Expected %d batches got %d", 10, len(batches))
-	}
-	if len(batches[2]) >= 101 {
-		t.Errorf("Batcher failed, expected len(batches[0]) <= 100, but got %d", len(batches[0]))
-	}
-}
+	t.Run("Deleted items wait in the trash until restored", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+		userId := "alex"
 
-func createMeditations(count int, userId string, store *DynamoMeditationStore) []Meditation {
-	now := time.Now()
+		meditations := createMeditations(2, userId, store)
+		sequenceId := ksuid.New().String()
+		err := store.SaveSequence(Sequence{
+			ID:          sequenceId,
+			Name:        "Sequence",
+			Description: "A Testing Sequence",
+			UserId:      userId,
+			Public:      true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: meditations,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
 
-	meditations := make([]Meditation, count)
-	ids := make([]string, count)
-	wg := sync.WaitGroup{}
-	for i := 0; i < count; i++ {
-		id := "seqMed" + strconv.Itoa(i)
-		ids[i] = id
-		m := Meditation{
-			ID:        id,
-			Name:      fmt.Sprintf("Meditation %d", i),
-			Text:      "Meditation Text",
-			URL:       "http://mp3.com/1.mp3",
-			Public:    false,
-			UserId:    userId,
-			CreatedAt: now,
-			UpdatedAt: now,
+		err = store.DeleteSequenceById(sequenceId)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, err = store.GetSequenceById(sequenceId)
+		if !errors.Is(err, ErrSequenceNotFound) {
+			t.Errorf("Expected ErrSequenceNotFound, got %v", err)
+		}
+		sequences, _ := store.ListSequencesByUserId(userId)
+		public, _ := store.ListPublicSequences()
+		if len(sequences) != 0 || len(public) != 0 {
+			t.Errorf("Expected the trashed sequence to be hidden, got %d and %d", len(sequences), len(public))
 		}
-		meditations[i] = m
 
-	}
+		// the trashed sequence still holds on to its meditations
+		err = store.DeleteMeditation(meditations[0].ID)
+		if !errors.Is(err, ErrMeditationInUse) {
+			t.Errorf("Expected ErrMeditationInUse, got %v", err)
+		}
+		err = store.DeleteMeditationCascade(meditations[0].ID, AnyVersion)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		trash, err := store.ListTrash(userId, time.Now())
+		if err != nil || len(trash) != 2 {
+			t.Errorf("Expected 2 items in the trash, got %+v %v", trash, err)
+		}
 
-	for _, m := range meditations {
-		wg.Add(1)
-		go func(m Meditation, wg *sync.WaitGroup) {
-			store.SaveMeditation(m)
-			wg.Done()
-		}(m, &wg)
-	}
-	wg.Wait()
-	return meditations
-}
+		_, err = store.RestoreSequence(sequenceId, "someone else", time.Now())
+		if !errors.Is(err, ErrNotInTrash) {
+			t.Errorf("Expected ErrNotInTrash, got %v", err)
+		}
+		s, err := store.RestoreSequence(sequenceId, userId, time.Now())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(s.Meditations) != 1 || s.Meditations[0].ID != meditations[1].ID {
+			t.Errorf("Expected only the meditation still around, got %+v", s.Meditations)
+		}
+		public, _ = store.ListPublicSequences()
+		if len(public) != 1 {
+			t.Errorf("Expected the restored sequence to be public again, got %d", len(public))
+		}
 
-func TestSequences(t *testing.T) {
+		// once past its time it is as good as purged
+		_, err = store.RestoreMeditation(meditations[0].ID, userId, time.Now().Add(trashRetention()+time.Hour))
+		if !errors.Is(err, ErrNotInTrash) {
+			t.Errorf("Expected ErrNotInTrash, got %v", err)
+		}
+		_, err = store.RestoreMeditation(meditations[0].ID, userId, time.Now())
+		if err != nil {
+			t.Error(err.Error())
+		}
+		restored, _ := store.ListMeditations(userId)
+		trash, _ = store.ListTrash(userId, time.Now())
+		if len(restored) != 2 || len(trash) != 0 {
+			t.Errorf("Expected everything restored, got %d meditations and %d in the trash", len(restored), len(trash))
+		}
+	})
 
-	t.Run("Create a sequence, get a sequence, update a sequence, delete a sequence", func(t *testing.T) {
+	t.Run("Insert, move and remove sequence items", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
 		now := time.Now()
-		userId := "alex"
-
-		meditations := createMeditations(10, "alex", store)
-		mCount := len(meditations)
 
+		meditations := createMeditations(3, "alex", store)
 		sequenceId := ksuid.New().String()
-		sequence := Sequence{
+		err := store.SaveSequence(Sequence{
 			ID:          sequenceId,
-			Name:        "Sequence 1",
+			Name:        "Sequence",
 			Description: "A Testing Sequence",
-			ImageURL:    "https://image.url/",
-			Public:      false,
-			UserId:      userId,
+			UserId:      "alex",
 			CreatedAt:   now,
 			UpdatedAt:   now,
-			Meditations: meditations,
-		}
-		err := store.SaveSequence(sequence)
+			Meditations: meditations[:2],
+		})
 		if err != nil {
 			t.Error(err.Error())
 		}
 
-		fetchedSequence, err := store.GetSequenceById(sequenceId)
+		err = store.InsertSequenceItem(sequenceId, 0, true, SequenceStep{Type: StepMeditation, MeditationID: meditations[2].ID}, 0)
 		if err != nil {
 			t.Error(err.Error())
 		}
-		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
-			t.Error(diff)
-			return
+		err = store.MoveSequenceItem(sequenceId, 1, true, 0, 2)
+		if err != nil {
+			t.Error(err.Error())
 		}
-		expectedId := sequence.Meditations[0].ID
-		actualId := fetchedSequence.Meditations[0].ID
-		if expectedId != actualId {
-			t.Errorf("First meditations don't match.")
-			t.Errorf("expected %s got %s", expectedId, actualId)
-			t.Errorf("%+v", len(fetchedSequence.Meditations))
+		err = store.RemoveSequenceItem(sequenceId, 2, true, 0)
+		if err != nil {
+			t.Error(err.Error())
 		}
-		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
-			t.Error("Expected fetchedSequence to match original sequence")
-			t.Error(diff)
+		err = store.RemoveSequenceItem(sequenceId, 2, true, 0)
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch, got %v", err)
+		}
+		err = store.RemoveSequenceItem(sequenceId, 3, true, 5)
+		if !errors.Is(err, ErrPositionOutOfRange) {
+			t.Errorf("Expected ErrPositionOutOfRange, got %v", err)
 		}
 
-		// update the sequence
-		updatedSequence := sequence
-		updatedSequence.Meditations = meditations[0 : mCount/2]
-		err = store.UpdateSequence(updatedSequence)
+		s, err := store.GetSequenceById(sequenceId)
 		if err != nil {
-			t.Error(err)
+			t.Error(err.Error())
 		}
-
-		updatedFetchedSequence, _ := store.GetSequenceById(sequenceId)
-		if len(updatedFetchedSequence.Meditations) != mCount/2 {
-			t.Errorf("did not find 100 updated meditations: %d", len(updatedFetchedSequence.Meditations))
+		if len(s.Meditations) != 2 || s.Meditations[0].ID != meditations[1].ID || s.Meditations[1].ID != meditations[2].ID {
+			t.Errorf("Unexpected meditations after edits: %v", s.Meditations)
 		}
 
-		// delete the sequence
-		err = store.DeleteSequenceById(sequenceId)
+		// the removed meditation is no longer referenced
+		err = store.DeleteMeditation(meditations[0].ID)
 		if err != nil {
-			t.Error("deletion failed")
 			t.Error(err.Error())
 		}
+		err = store.DeleteMeditation(meditations[2].ID)
+		if !errors.Is(err, ErrMeditationInUse) {
+			t.Errorf("Expected ErrMeditationInUse, got %v", err)
+		}
 	})
 
-	t.Run("Create a sequence with a duplicate med ID succeeds", func(t *testing.T) {
+	t.Run("Steps are saved in order and timed", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
 		now := time.Now()
-		userId := "alex"
 
 		meditations := createMeditations(2, "alex", store)
+		for i := range meditations {
+			meditations[i].Duration = 30
+			err := store.UpdateMeditation(meditations[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+		}
+		steps := []SequenceStep{
+			{Type: StepBell},
+			{Type: StepMeditation, MeditationID: meditations[0].ID},
+			{Type: StepSilence, Seconds: 600},
+			{Type: StepRepeat, MeditationID: meditations[1].ID, Times: 3},
+			{Type: StepBell},
+		}
+		sequenceId := ksuid.New().String()
+		err := store.SaveSequence(Sequence{
+			ID:          sequenceId,
+			Name:        "Centering Prayer",
+			Description: "A Testing Sequence",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Steps:       steps,
+		})
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		s, err := store.GetSequenceById(sequenceId)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(s.Steps) != len(steps) || s.Steps[3].Type != StepRepeat || s.Steps[3].Duration != 90 {
+			t.Errorf("Unexpected steps: %+v", s.Steps)
+		}
+		if s.TotalDuration != 2*BELL_SECONDS+30+600+90 {
+			t.Errorf("Expected a total of %d seconds, got %d", 2*BELL_SECONDS+30+600+90, s.TotalDuration)
+		}
+		if len(s.Meditations) != 2 {
+			t.Errorf("Expected %d meditations, got %d", 2, len(s.Meditations))
+		}
+		err = store.DeleteMeditation(meditations[1].ID)
+		if !errors.Is(err, ErrMeditationInUse) {
+			t.Errorf("Expected ErrMeditationInUse, got %v", err)
+		}
+	})
+
+	t.Run("Other authors' meditations are redacted once they go private", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		now := time.Now()
+
+		theirs := createMeditations(2, "evagrius", store)
+		for i := range theirs {
+			theirs[i].Public = true
+			theirs[i].AuthorName = "Evagrius"
+			err := store.UpdateMeditation(theirs[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			theirs[i].Version++
+		}
+		if !store.CanUseMeditations("alex", theirs) {
+			t.Error("Expected public meditations to be usable")
+		}
 
 		sequenceId := ksuid.New().String()
-		sequence := Sequence{
+		err := store.SaveSequence(Sequence{
 			ID:          sequenceId,
-			Name:        "Sequence 1",
+			Name:        "Borrowed",
 			Description: "A Testing Sequence",
-			ImageURL:    "https://image.url/",
-			Public:      false,
-			UserId:      userId,
+			UserId:      "alex",
 			CreatedAt:   now,
 			UpdatedAt:   now,
-			Meditations: append(meditations, meditations[0]),
+			Meditations: theirs,
+		})
+		if err != nil {
+			t.Error(err.Error())
 		}
-		err := store.SaveSequence(sequence)
+
+		theirs[0].Public = false
+		err = store.UpdateMeditation(theirs[0])
 		if err != nil {
 			t.Error(err.Error())
 		}
-		fetchedSequence, err := store.GetSequenceById(sequenceId)
+		s, err := store.GetSequenceById(sequenceId)
 		if err != nil {
 			t.Error(err.Error())
 		}
-		if diff := deep.Equal(sequence, fetchedSequence); diff != nil {
-			t.Error(diff)
+		if len(s.Meditations) != 2 {
+			t.Errorf("Expected %d meditations, got %d", 2, len(s.Meditations))
+			return
+		}
+		gone := s.Meditations[0]
+		if !gone.Unavailable || gone.ID != theirs[0].ID || gone.URL != "" || gone.AuthorName != "Evagrius" {
+			t.Errorf("Expected the private meditation to be redacted, got %+v", gone)
+		}
+		if s.Meditations[1].Unavailable || s.Meditations[1].URL == "" {
+			t.Errorf("Expected the public meditation to be intact, got %+v", s.Meditations[1])
 		}
-
 	})
 
-	t.Run("Get a nonexistent sequence fails", func(t *testing.T) {
+	t.Run("Other users' sequences don't keep a meditation from being deleted", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
-		_, err := store.GetSequenceById("DOES_NOT_EXIST")
-		if err == nil {
-			t.Error("expected an error for non-existent get, but got nil")
+		now := time.Now()
+
+		theirs := createMeditations(2, "evagrius", store)
+		for i := range theirs {
+			theirs[i].Public = true
+			err := store.UpdateMeditation(theirs[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			theirs[i].Version++
+		}
+		borrowed := Sequence{
+			ID:          ksuid.New().String(),
+			Name:        "Borrowed",
+			Description: "A Testing Sequence",
+			UserId:      "alex",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Meditations: theirs,
+		}
+		own := borrowed
+		own.ID = ksuid.New().String()
+		own.Name = "Own"
+		own.UserId = "evagrius"
+		own.Meditations = theirs[1:]
+		for _, s := range []Sequence{borrowed, own} {
+			err := store.SaveSequence(s)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		err := store.DeleteMeditation(theirs[0].ID)
+		if err != nil {
+			t.Errorf("Expected a borrowed meditation to be deletable, got %v", err)
+		}
+		err = store.DeleteMeditation(theirs[1].ID)
+		if !errors.Is(err, ErrMeditationInUse) {
+			t.Errorf("Expected ErrMeditationInUse, got %v", err)
+		}
+		err = store.DeleteMeditationCascade(theirs[1].ID, AnyVersion)
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		// the borrowing sequence is left as it was, its meditations unavailable
+		s, err := store.GetSequenceById(borrowed.ID)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Version != 0 || len(s.Meditations) != 2 || !s.Meditations[0].Unavailable || !s.Meditations[1].Unavailable {
+			t.Errorf("Expected both meditations kept but unavailable, got version %d %+v", s.Version, s.Meditations)
+		}
+		s, _ = store.GetSequenceById(own.ID)
+		if len(s.Meditations) != 0 {
+			t.Errorf("Expected the author's own sequence emptied, got %+v", s.Meditations)
+		}
+
+		// and can still let go of them
+		s, _ = store.GetSequenceById(borrowed.ID)
+		s.Meditations = []Meditation{}
+		s.Steps = []SequenceStep{}
+		err = store.UpdateSequence(s)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		ids, _ := store.GetSequenceIdsByMeditationId(theirs[0].ID)
+		if len(ids) != 0 {
+			t.Errorf("Expected the relation records gone, got %v", ids)
 		}
 	})
 
-	t.Run("Disallow deletion of meditation if it's in a sequence", func(t *testing.T) {
+	t.Run("Forks are counted without changing the original's version", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
 		now := time.Now()
-		userId := "alex"
 
-		meditations := createMeditations(3, "alex", store)
-		sequenceId := ksuid.New().String()
-		sequence := Sequence{
-			ID:          sequenceId,
-			Name:        "Sequence 1",
+		meditations := createMeditations(2, "alex", store)
+		original := Sequence{
+			ID:          ksuid.New().String(),
+			Name:        "Original",
 			Description: "A Testing Sequence",
-			ImageURL:    "https://image.url/",
-			Public:      false,
-			UserId:      userId,
+			UserId:      "alex",
+			Public:      true,
 			CreatedAt:   now,
 			UpdatedAt:   now,
 			Meditations: meditations,
 		}
-		err := store.SaveSequence(sequence)
+		err := store.SaveSequence(original)
 		if err != nil {
 			t.Error(err.Error())
 		}
 
-		m := meditations[0]
-		err = store.DeleteMeditation(m.ID)
-		if err == nil {
-			t.Error("should not have allowed meditation to be deleted because it is part of a sequence!")
-		}
-		updatedSequence := sequence
-		updatedSequence.Meditations = meditations[1:]
-		err = store.UpdateSequence(updatedSequence)
+		fork := original
+		fork.ID = ksuid.New().String()
+		fork.UserId = "jordan"
+		fork.Public = false
+		err = store.ForkSequence(original.ID, fork)
 		if err != nil {
 			t.Error(err.Error())
 		}
-		err = store.DeleteMeditation(m.ID)
+
+		// the author can still update with the version they read
+		err = store.UpdateSequence(original)
 		if err != nil {
 			t.Error(err.Error())
 		}
+		fetched, _ := store.GetSequenceById(original.ID)
+		if fetched.ForkCount != 1 || fetched.Version != 1 {
+			t.Errorf("Expected forkCount 1 and version 1, got %d and %d", fetched.ForkCount, fetched.Version)
+		}
+		fetchedFork, _ := store.GetSequenceById(fork.ID)
+		if fetchedFork.ForkedFrom != original.ID || fetchedFork.UserId != "jordan" {
+			t.Errorf("Unexpected fork %+v", fetchedFork)
+		}
+
+		// a fork that fails to save isn't counted
+		if err = store.ForkSequence(original.ID, fork); err == nil {
+			t.Error("Expected saving the same fork twice to fail")
+		}
+		if fetched, _ = store.GetSequenceById(original.ID); fetched.ForkCount != 1 {
+			t.Errorf("Expected forkCount 1, got %d", fetched.ForkCount)
+		}
 
+		// nor is there anything to count on an original that's gone
+		orphan := fork
+		orphan.ID = ksuid.New().String()
+		if err = store.ForkSequence("no-such-sequence", orphan); err != nil {
+			t.Errorf("Expected the fork to stand on its own, got %v", err)
+		}
 	})
 
 	t.Run("List meditations happy path", func(t *testing.T) {
@@ -510,6 +1679,178 @@ func TestSequences(t *testing.T) {
 
 }
 
+func TestSessions(t *testing.T) {
+	t.Run("Sessions are private and listed newest first within a range", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		start := time.Date(2021, 3, 1, 6, 0, 0, 0, time.UTC)
+
+		ids := []string{}
+		for day := 0; day < 5; day++ {
+			startedAt := start.AddDate(0, 0, day)
+			id, err := newSessionId(startedAt)
+			if err != nil {
+				t.Error(err.Error())
+			}
+			err = store.SaveSession(PracticeSession{
+				ID:           id,
+				UserId:       "alex",
+				MeditationID: "m1",
+				StartedAt:    startedAt,
+				Minutes:      20,
+				Status:       SessionCompleted,
+			})
+			if err != nil {
+				t.Error(err.Error())
+			}
+			ids = append(ids, id)
+		}
+
+		sessions, err := store.ListSessions("alex", start.AddDate(0, 0, 1), start.AddDate(0, 0, 4))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(sessions) != 3 {
+			t.Errorf("Expected %d sessions, got %d", 3, len(sessions))
+		} else if sessions[0].ID != ids[3] || sessions[2].ID != ids[1] {
+			t.Error("Expected sessions newest first")
+		}
+
+		_, err = store.GetSession("someone-else", ids[0])
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Expected ErrSessionNotFound, got %v", err)
+		}
+
+		s, err := store.GetSession("alex", ids[0])
+		if err != nil {
+			t.Error(err.Error())
+		}
+		s.Note = "Distracted, but returned to the word."
+		err = store.UpdateSession(s)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		s, _ = store.GetSession("alex", ids[0])
+		if s.Note != "Distracted, but returned to the word." {
+			t.Errorf("Expected the note to be saved, got %q", s.Note)
+		}
+
+		err = store.DeleteSession("alex", ids[0])
+		if err != nil {
+			t.Error(err.Error())
+		}
+		err = store.DeleteSession("alex", ids[0])
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Expected ErrSessionNotFound, got %v", err)
+		}
+	})
+}
+
+func TestLibrary(t *testing.T) {
+	t.Run("Library pages through saved meditations", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		theirs := createMeditations(5, "evagrius", store)
+		for i := range theirs {
+			theirs[i].Public = i != 4
+			err := store.UpdateMeditation(theirs[i])
+			if err != nil {
+				t.Error(err.Error())
+			}
+			theirs[i].Version++
+		}
+		for _, m := range theirs[:3] {
+			err := store.AddToLibrary("alex", LibraryMeditation, m.ID)
+			if err != nil {
+				t.Error(err.Error())
+			}
+		}
+
+		seen := 0
+		token := ""
+		for pages := 0; pages < 5; pages++ {
+			records, next, err := store.ListLibrary("alex", LibraryMeditation, 2, token)
+			if err != nil {
+				t.Error(err.Error())
+				break
+			}
+			seen += len(records)
+			if next == "" {
+				break
+			}
+			token = next
+		}
+		if seen != 3 {
+			t.Errorf("Expected %d library entries, got %d", 3, seen)
+		}
+		_, _, err := store.ListLibrary("alex", LibraryMeditation, 2, "not-a-token")
+		if !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("Expected ErrInvalidPageToken, got %v", err)
+		}
+
+		err = store.RemoveFromLibrary("alex", LibraryMeditation, theirs[0].ID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		records, _, _ := store.ListLibrary("alex", "", 10, "")
+		if len(records) != 2 {
+			t.Errorf("Expected %d library entries, got %d", 2, len(records))
+		}
+	})
+
+	t.Run("Library lists the most recently saved first", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+
+		saved := []struct {
+			itemType string
+			id       string
+		}{
+			{LibraryMeditation, "b"},
+			{LibrarySequence, "c"},
+			{LibraryMeditation, "a"},
+		}
+		for _, item := range saved {
+			err := store.AddToLibrary("alex", item.itemType, item.id)
+			if err != nil {
+				t.Error(err.Error())
+			}
+		}
+		// saving again keeps its place
+		err := store.AddToLibrary("alex", LibraryMeditation, "b")
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		records, _, _ := store.ListLibrary("alex", "", 10, "")
+		if len(records) != 3 || records[0].ItemID != "a" || records[1].ItemID != "c" || records[2].ItemID != "b" {
+			t.Errorf("Expected a, c then b, got %v", records)
+		}
+		records, next, _ := store.ListLibrary("alex", LibraryMeditation, 1, "")
+		if len(records) != 1 || records[0].ItemID != "a" || next == "" {
+			t.Errorf("Expected a and a next page, got %v", records)
+		}
+		records, _, _ = store.ListLibrary("alex", LibraryMeditation, 1, next)
+		if len(records) != 1 || records[0].ItemID != "b" {
+			t.Errorf("Expected b past the sequence, got %v", records)
+		}
+
+		err = store.RemoveFromLibrary("alex", LibraryMeditation, "b")
+		if err != nil {
+			t.Error(err.Error())
+		}
+		err = store.AddToLibrary("alex", LibraryMeditation, "b")
+		if err != nil {
+			t.Error(err.Error())
+		}
+		records, _, _ = store.ListLibrary("alex", "", 10, "")
+		if len(records) != 3 || records[0].ItemID != "b" {
+			t.Errorf("Expected b saved again to come first, got %v", records)
+		}
+	})
+}
+
 func BenchmarkGetMeditationsByIds(b *testing.B) {
 	tableName := uuid.NewV4().String()
 	store := initializeTestingStore(tableName)
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DynamoDB can't index a list attribute, so every public item gets an index
+// record per tag and category: pk `tag#<tag>` or `cat#<category>`, sk
+// `med#<id>` or `seq#<id>`. Filtering by a tag is then a query on its
+// partition. The number of items under each is kept alongside, under pk
+// `tagcount`, for the tag cloud. Meditation citations are indexed the same
+// way, under `author#<author>` and `work#<work>`.
+
+const TAG_COUNT_PK = "tagcount"
+
+type TaxonomyIndexRecord struct {
+	Pk   string `dynamodbav:"pk"`
+	Sk   string `dynamodbav:"sk"`
+	Type string `dynamodbav:"type"`
+}
+
+type TaxonomyCountRecord struct {
+	Pk    string `dynamodbav:"pk"`
+	Sk    string `dynamodbav:"sk"`
+	Count int    `dynamodbav:"count"`
+	Label string `dynamodbav:"label,omitempty"` // the author or work as written
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// CitationCount is an author or work and the number of public meditations
+// citing it. Key is what to filter the public meditations by.
+type CitationCount struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type TagCloud struct {
+	Tags       []TagCount `json:"tags"`
+	Categories []TagCount `json:"categories"`
+}
+
+func (store DynamoMeditationStore) taxonomyCountUpdate(indexKey string, delta int, label string) *dynamodb.TransactWriteItem {
+	update := &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(store.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"pk": {
+					S: aws.String(TAG_COUNT_PK),
+				},
+				"sk": {
+					S: aws.String(indexKey),
+				},
+			},
+			UpdateExpression: aws.String("ADD #count :delta"),
+			ExpressionAttributeNames: map[string]*string{
+				"#count": aws.String("count"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":delta": {
+					N: aws.String(strconv.Itoa(delta)),
+				},
+			},
+		},
+	}
+	if label != "" {
+		update.Update.UpdateExpression = aws.String("ADD #count :delta SET #label = :label")
+		update.Update.ExpressionAttributeNames["#label"] = aws.String("label")
+		update.Update.ExpressionAttributeValues[":label"] = &dynamodb.AttributeValue{
+			S: aws.String(label),
+		}
+	}
+	return update
+}
+
+// indexedKeys lists the index partitions `itemSk` actually has an index
+// record in.
+func (store DynamoMeditationStore) indexedKeys(itemSk string) ([]string, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		IndexName:              aws.String("gs1"),
+		KeyConditionExpression: aws.String("#sk = :sk"),
+		FilterExpression:       aws.String("#type = :type"),
+		ExpressionAttributeNames: map[string]*string{
+			"#sk":   aws.String("sk"),
+			"#type": aws.String("type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sk": {
+				S: aws.String(itemSk),
+			},
+			":type": {
+				S: aws.String("tagidx"),
+			},
+		},
+	}
+	keys := []string{}
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			keys = append(keys, *item["pk"].S)
+		}
+		return true
+	})
+	return keys, err
+}
+
+// syncTaxonomyIndex moves the item `itemSk` (e.g. med#<id>) into the index
+// partitions in `after` and out of any others, keeping the counts in step.
+// The partitions it leaves are those in `before` and those it is found in,
+// so an index write lost earlier is made up here. `labels` names the
+// partitions the item is added to, where they have a name to show.
+func (store DynamoMeditationStore) syncTaxonomyIndex(itemSk string, before []string, after []string, labels map[string]string) error {
+	stored, err := store.indexedKeys(itemSk)
+	if err != nil {
+		return err
+	}
+	isStored := make(map[string]bool)
+	for _, k := range stored {
+		isStored[k] = true
+	}
+	inAfter := make(map[string]bool)
+	for _, k := range after {
+		inAfter[k] = true
+	}
+
+	// each put or delete is conditional on the index record being missing
+	// or there, and goes with its count in a transaction of its own, so the
+	// count only moves when the record does
+	transactions := [][]*dynamodb.TransactWriteItem{}
+	for _, k := range dedupIds(after) {
+		if isStored[k] {
+			continue
+		}
+		item, err := dynamodbattribute.MarshalMap(TaxonomyIndexRecord{Pk: k, Sk: itemSk, Type: "tagidx"})
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(store.tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#pk": aws.String("pk"),
+					},
+				},
+			},
+			store.taxonomyCountUpdate(k, 1, labels[k]),
+		})
+	}
+	for _, k := range dedupIds(append(append([]string{}, before...), stored...)) {
+		if inAfter[k] {
+			continue
+		}
+		transactions = append(transactions, []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(store.tableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"pk": {
+							S: aws.String(k),
+						},
+						"sk": {
+							S: aws.String(itemSk),
+						},
+					},
+					ConditionExpression: aws.String("attribute_exists(#pk)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#pk": aws.String("pk"),
+					},
+				},
+			},
+			store.taxonomyCountUpdate(k, -1, ""),
+		})
+	}
+
+	e := store.batch()
+	return e.runChunks(len(transactions), func(idx int) error {
+		err := store.transactWithRetry(e, transactions[idx])
+		// already indexed, or already gone
+		if isConditionalCheckFailure(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// reindexTaxonomy is syncTaxonomyIndex for callers that have just written an
+// item: the write has already happened, so a failure here is only logged.
+// The next save of the item reconciles the index with what is stored.
+func (store DynamoMeditationStore) reindexTaxonomy(itemSk string, before []string, after []string, labels map[string]string) {
+	err := store.syncTaxonomyIndex(itemSk, before, after, labels)
+	if err != nil {
+		log.Printf("could not update the tag index for %s: %v", itemSk, err)
+	}
+}
+
+func meditationIndexKeys(m Meditation) []string {
+	keys := taxonomyIndexKeys(m.Public, m.Tags, m.Categories)
+	citationKeys := []string{}
+	for k := range meditationIndexLabels(m) {
+		citationKeys = append(citationKeys, k)
+	}
+	sort.Strings(citationKeys)
+	return append(keys, citationKeys...)
+}
+
+func meditationIndexLabels(m Meditation) map[string]string {
+	return citationIndexKeys(m.Public, m.Citation)
+}
+
+func sequenceIndexKeys(s Sequence) []string {
+	return taxonomyIndexKeys(s.Public, s.Tags, s.Categories)
+}
+
+// listIndexedIds returns the ids of the items of kind `prefix` (med# or seq#)
+// under the index partition `indexKey`.
+func (store DynamoMeditationStore) listIndexedIds(indexKey string, prefix string) ([]string, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(indexKey),
+			},
+			":prefix": {
+				S: aws.String(prefix),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	ids := []string{}
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			ids = append(ids, strings.TrimPrefix(*item["sk"].S, prefix))
+		}
+		return true
+	})
+	return ids, err
+}
+
+// ListPublicMeditationsByIndex lists the public meditations with the given
+// tag (`tag#<tag>`) or category (`cat#<category>`).
+func (store DynamoMeditationStore) ListPublicMeditationsByIndex(indexKey string) ([]Meditation, error) {
+	ids, err := store.listIndexedIds(indexKey, "med#")
+	if err != nil {
+		return []Meditation{}, err
+	}
+	meditations, err := store.GetMeditationsByIds(ids)
+	if err != nil {
+		return []Meditation{}, err
+	}
+	public := []Meditation{}
+	for _, m := range meditations {
+		if m.Public {
+			public = append(public, m)
+		}
+	}
+	return public, nil
+}
+
+// ListPublicSequencesByIndex lists the public sequences with the given tag
+// (`tag#<tag>`) or category (`cat#<category>`).
+func (store DynamoMeditationStore) ListPublicSequencesByIndex(indexKey string) ([]Sequence, error) {
+	ids, err := store.listIndexedIds(indexKey, "seq#")
+	if err != nil {
+		return []Sequence{}, err
+	}
+	keys := make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, id := range ids {
+		keys[i] = sequenceKey(id)
+	}
+	items, err := store.batch().GetItems(keys)
+	if err != nil {
+		return []Sequence{}, err
+	}
+	records := []SequenceRecord{}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return []Sequence{}, err
+	}
+	sequences := []Sequence{}
+	for _, r := range records {
+		s := r.toSequence()
+		if s.Public {
+			sequences = append(sequences, s)
+		}
+	}
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i].ID > sequences[j].ID
+	})
+	return sequences, nil
+}
+
+// GetTagCloud returns every tag in use with the number of public items
+// carrying it, most used first, and every category with its count.
+func (store DynamoMeditationStore) GetTagCloud() (TagCloud, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(TAG_COUNT_PK),
+			},
+		},
+	}
+	records := []TaxonomyCountRecord{}
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		pageRecords := []TaxonomyCountRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageRecords)
+		records = append(records, pageRecords...)
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return TagCloud{}, err
+	}
+
+	cloud := TagCloud{
+		Tags:       []TagCount{},
+		Categories: []TagCount{},
+	}
+	categoryCounts := make(map[string]int)
+	for _, r := range records {
+		if strings.HasPrefix(r.Sk, "tag#") && r.Count > 0 {
+			cloud.Tags = append(cloud.Tags, TagCount{Name: strings.TrimPrefix(r.Sk, "tag#"), Count: r.Count})
+		}
+		if strings.HasPrefix(r.Sk, "cat#") {
+			categoryCounts[strings.TrimPrefix(r.Sk, "cat#")] = r.Count
+		}
+	}
+	sort.Slice(cloud.Tags, func(i, j int) bool {
+		if cloud.Tags[i].Count != cloud.Tags[j].Count {
+			return cloud.Tags[i].Count > cloud.Tags[j].Count
+		}
+		return cloud.Tags[i].Name < cloud.Tags[j].Name
+	})
+	for _, c := range Categories {
+		cloud.Categories = append(cloud.Categories, TagCount{Name: c, Count: categoryCounts[c]})
+	}
+	return cloud, nil
+}
+
+// ListCitationIndex returns the authors (prefix `author#`) or works
+// (`work#`) of the public meditations, with how many cite each, by name.
+func (store DynamoMeditationStore) ListCitationIndex(prefix string) ([]CitationCount, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk and begins_with(#sk, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String("pk"),
+			"#sk": aws.String("sk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(TAG_COUNT_PK),
+			},
+			":prefix": {
+				S: aws.String(prefix),
+			},
+		},
+	}
+	counts := []CitationCount{}
+	var unmarshalErr error
+	err := store.svc.QueryPages(params, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		records := []TaxonomyCountRecord{}
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &records)
+		for _, r := range records {
+			if r.Count > 0 {
+				counts = append(counts, CitationCount{
+					Key:   strings.TrimPrefix(r.Sk, prefix),
+					Name:  r.Label,
+					Count: r.Count,
+				})
+			}
+		}
+		return unmarshalErr == nil
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return []CitationCount{}, err
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return strings.ToLower(counts[i].Name) < strings.ToLower(counts[j].Name)
+	})
+	return counts, nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Deleting a meditation or sequence moves it to its owner's trash. A trashed
+// record keeps its key, and a trashed sequence its relation records, so
+// restoring it puts everything back as it was. It only leaves the user (gs2)
+// and public (gs3) indexes, for ppk "trash#<userId>", and the tag and search
+// indexes. Once `expiresAt` passes DynamoDB's TTL deletes it for good, and
+// PurgeTrashHandler cleans up after it.
+
+// TRASH_RETENTION_DAYS is how long the trash keeps items unless
+// $TRASH_RETENTION_DAYS says otherwise.
+const TRASH_RETENTION_DAYS = 30
+
+var ErrNotInTrash = errors.New("no such item in the trash")
+
+type TrashItem struct {
+	Type       string      `json:"type"` // meditation or sequence
+	ID         string      `json:"_id"`
+	Name       string      `json:"name"`
+	DeletedAt  time.Time   `json:"deletedAt"`
+	PurgeAt    time.Time   `json:"purgeAt"` // when it goes for good
+	Meditation *Meditation `json:"meditation,omitempty"`
+	Sequence   *Sequence   `json:"sequence,omitempty"`
+}
+
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = TRASH_RETENTION_DAYS
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func trashKey(userId string) string {
+	return "trash#" + userId
+}
+
+// inTrash reports whether a trashed record can still be restored. TTL can
+// take a day or two to get round to deleting a record, so one past its
+// expiry already counts as gone.
+func inTrash(deletedAt string, expiresAt int64, now time.Time) bool {
+	return deletedAt != "" && expiresAt > now.Unix()
+}
+
+// trashUpdate moves the record at `key` into its owner's trash, provided it
+// is at `version` and `condition`, if any, holds. `names` and `values` hold
+// the placeholders `condition` uses.
+func (store DynamoMeditationStore) trashUpdate(key map[string]*dynamodb.AttributeValue, ownerId string, version int64, condition string, names map[string]*string, values map[string]*dynamodb.AttributeValue, now time.Time) *dynamodb.UpdateItemInput {
+	names["#pk"] = aws.String("pk")
+	names["#ppk"] = aws.String("ppk")
+	names["#pppk"] = aws.String("pppk")
+	names["#deletedAt"] = aws.String("deletedAt")
+	names["#expiresAt"] = aws.String("expiresAt")
+	names["#version"] = aws.String("version")
+	values[":owner"] = &dynamodb.AttributeValue{S: aws.String(ownerId)}
+	values[":trash"] = &dynamodb.AttributeValue{S: aws.String(trashKey(ownerId))}
+	values[":deletedAt"] = &dynamodb.AttributeValue{S: aws.String(now.UTC().Format(time.RFC3339))}
+	values[":expiresAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(trashRetention()).Unix(), 10))}
+	values[":noVersion"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+
+	fullCondition := "attribute_exists(#pk) AND #ppk = :owner AND attribute_not_exists(#deletedAt)"
+	if version != AnyVersion {
+		fullCondition += " AND " + versionCondition(version, values)
+	}
+	if condition != "" {
+		fullCondition += " AND " + condition
+	}
+	return &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(store.tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET #ppk = :trash, #deletedAt = :deletedAt, #expiresAt = :expiresAt, #version = if_not_exists(#version, :noVersion) + :one REMOVE #pppk"),
+		ConditionExpression:       aws.String(fullCondition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+	}
+}
+
+// untrash takes the record at `key`, still at `version`, back out of the
+// trash, returning it to the owner's and, with `pppk`, the public index.
+func (store DynamoMeditationStore) untrash(key map[string]*dynamodb.AttributeValue, ownerId string, pppk string, version int64) error {
+	names := map[string]*string{
+		"#ppk":       aws.String("ppk"),
+		"#pppk":      aws.String("pppk"),
+		"#deletedAt": aws.String("deletedAt"),
+		"#expiresAt": aws.String("expiresAt"),
+		"#version":   aws.String("version"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":owner": {
+			S: aws.String(ownerId),
+		},
+		":trash": {
+			S: aws.String(trashKey(ownerId)),
+		},
+		":nextVersion": {
+			N: aws.String(strconv.FormatInt(version+1, 10)),
+		},
+	}
+	update := "SET #ppk = :owner, #version = :nextVersion"
+	remove := " REMOVE #deletedAt, #expiresAt"
+	// private sequences have no pppk
+	if pppk != "" {
+		values[":pppk"] = &dynamodb.AttributeValue{
+			S: aws.String(pppk),
+		}
+		update += ", #pppk = :pppk"
+	} else {
+		remove += ", #pppk"
+	}
+	_, err := store.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(store.tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String(update + remove),
+		ConditionExpression:       aws.String("#ppk = :trash AND " + versionCondition(version, values)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if isConditionalCheckFailure(err) {
+		return ErrNotInTrash
+	}
+	return err
+}
+
+// ListTrash lists what the user deleted that can still be restored, most
+// recently deleted first.
+func (store DynamoMeditationStore) ListTrash(userId string, now time.Time) ([]TrashItem, error) {
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(store.tableName),
+		IndexName:              aws.String("gs2"),
+		KeyConditionExpression: aws.String("#ppk = :trash"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ppk": aws.String("ppk"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":trash": {
+				S: aws.String(trashKey(userId)),
+			},
+		},
+	}
+	resp, err := store.svc.Query(params)
+	if err != nil {
+		return []TrashItem{}, err
+	}
+
+	items := []TrashItem{}
+	for _, item := range resp.Items {
+		if item["type"] == nil {
+			continue
+		}
+		var deletedAt string
+		var expiresAt int64
+		trashed := TrashItem{}
+		switch aws.StringValue(item["type"].S) {
+		case "med":
+			record := MeditationRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, &record)
+			m := record.toMeditation()
+			deletedAt, expiresAt = record.DeletedAt, record.ExpiresAt
+			trashed = TrashItem{Type: LibraryMeditation, ID: m.ID, Name: m.Name, Meditation: &m}
+		case "seq":
+			record := SequenceRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, &record)
+			s := record.toSequence()
+			deletedAt, expiresAt = record.DeletedAt, record.ExpiresAt
+			trashed = TrashItem{Type: LibrarySequence, ID: s.ID, Name: s.Name, Sequence: &s}
+		default:
+			continue
+		}
+		if err != nil {
+			return []TrashItem{}, err
+		}
+		if !inTrash(deletedAt, expiresAt, now) {
+			continue
+		}
+		trashed.DeletedAt, _ = time.Parse(time.RFC3339, deletedAt)
+		trashed.PurgeAt = time.Unix(expiresAt, 0).UTC()
+		items = append(items, trashed)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// RestoreMeditation takes one of the user's meditations back out of the
+// trash.
+func (store DynamoMeditationStore) RestoreMeditation(id string, userId string, now time.Time) (Meditation, error) {
+	record, err := store.getMeditationRecord(id)
+	if err != nil {
+		return Meditation{}, err
+	}
+	if record.Pk == "" || record.Ppk != trashKey(userId) || !inTrash(record.DeletedAt, record.ExpiresAt, now) {
+		return Meditation{}, ErrNotInTrash
+	}
+
+	m := record.toMeditation()
+	err = store.untrash(meditationKey(id), userId, mapMeditationToMeditationRecord(m).Pppk, record.Version)
+	if err != nil {
+		return Meditation{}, err
+	}
+	m.Version = record.Version + 1
+	store.reindexTaxonomy("med#"+id, []string{}, meditationIndexKeys(m), meditationIndexLabels(m))
+	store.reindexSearch("med#"+id, SearchDocument{}, meditationSearchDocument(m))
+	return m, nil
+}
+
+// RestoreSequence takes one of the user's sequences back out of the trash.
+// Meditations deleted meanwhile (see DeleteMeditationCascade) drop out of it.
+func (store DynamoMeditationStore) RestoreSequence(id string, userId string, now time.Time) (Sequence, error) {
+	record, err := store.getAnySequenceRecord(id)
+	if errors.Is(err, ErrSequenceNotFound) {
+		return Sequence{}, ErrNotInTrash
+	}
+	if err != nil {
+		return Sequence{}, err
+	}
+	if record.Ppk != trashKey(userId) || !inTrash(record.DeletedAt, record.ExpiresAt, now) {
+		return Sequence{}, ErrNotInTrash
+	}
+
+	// 1) put it back
+	s := record.toSequence()
+	err = store.untrash(sequenceKey(id), userId, mapSequenceToSequenceRecord(s).Pppk, record.Version)
+	if err != nil {
+		return Sequence{}, err
+	}
+	s.Version = record.Version + 1
+	store.reindexTaxonomy("seq#"+id, []string{}, sequenceIndexKeys(s), nil)
+	store.reindexSearch("seq#"+id, SearchDocument{}, sequenceSearchDocument(s))
+
+	// 2) drop the meditations that are gone
+	meditations, err := store.GetMeditationsByIds(record.Sequence.MeditationIDs)
+	if err != nil {
+		return Sequence{}, err
+	}
+	exists := make(map[string]bool)
+	for _, m := range meditations {
+		if m.ID != "" {
+			exists[m.ID] = true
+		}
+	}
+	steps := []SequenceStep{}
+	for _, step := range s.Steps {
+		if step.MeditationID == "" || exists[step.MeditationID] {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) != len(s.Steps) {
+		s.Steps = steps
+		s.Meditations = []Meditation{}
+		for _, mID := range stepMeditationIDs(steps) {
+			s.Meditations = append(s.Meditations, Meditation{ID: mID})
+		}
+		err = store.UpdateSequence(s)
+		if err != nil {
+			return Sequence{}, err
+		}
+	}
+	return store.GetSequenceById(id)
+}
+
+// purgeSequenceReferences removes the relation records of a sequence TTL has
+// deleted from the trash, releasing its meditations.
+func (store DynamoMeditationStore) purgeSequenceReferences(sequenceId string) error {
+	_, _, err := store.updateSequenceMeditationRelationRecords(mapSequenceToSequenceRecord(Sequence{
+		ID:          sequenceId,
+		Meditations: []Meditation{},
+	}))
+	return err
+}
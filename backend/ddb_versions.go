@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Meditation and sequence records carry a `version` that goes up by one on
+// every update. Writers pass the version they read and the write is
+// conditioned on it still being current, so a client editing a stale copy
+// gets ErrVersionMismatch instead of silently clobbering someone else's edit.
+
+// AnyVersion skips the version check.
+const AnyVersion int64 = -1
+
+var ErrVersionMismatch = errors.New("record has been modified since it was read")
+
+// versionCondition returns a condition expression matching records at
+// `version`, adding its placeholder to `values`. It expects `#version` to be
+// mapped to the version attribute. Records written before versioning existed
+// have no version attribute and count as version 0.
+func versionCondition(version int64, values map[string]*dynamodb.AttributeValue) string {
+	values[":expectedVersion"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(version, 10)),
+	}
+	if version == 0 {
+		return "(attribute_not_exists(#version) OR #version = :expectedVersion)"
+	}
+	return "#version = :expectedVersion"
+}
+
+// DeleteSequenceByIdIfVersion moves the sequence to the trash (see
+// ddb_trash.go) only if it is still at `version`. Its relation records stay,
+// so its meditations can't be deleted from under it while it may yet be
+// restored.
+func (store DynamoMeditationStore) DeleteSequenceByIdIfVersion(sequenceId string, version int64) error {
+	existing, err := store.getSequenceRecord(sequenceId)
+	if err != nil {
+		return err
+	}
+	if version != AnyVersion && existing.Version != version {
+		return ErrVersionMismatch
+	}
+
+	params := store.trashUpdate(sequenceKey(sequenceId), existing.Ppk, version, "", map[string]*string{}, map[string]*dynamodb.AttributeValue{}, time.Now())
+	_, err = store.svc.UpdateItem(params)
+	if isConditionalCheckFailure(err) {
+		return ErrVersionMismatch
+	}
+	if err != nil {
+		return err
+	}
+	store.reindexTaxonomy("seq#"+sequenceId, sequenceIndexKeys(existing.toSequence()), []string{}, nil)
+	store.reindexSearch("seq#"+sequenceId, sequenceSearchDocument(existing.toSequence()), SearchDocument{})
+	return nil
+}
+
+func sequenceKey(id string) map[string]*dynamodb.AttributeValue {
+	r := mapSequenceToSequenceRecord(Sequence{ID: id})
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {
+			S: aws.String(r.Pk),
+		},
+		"sk": {
+			S: aws.String(r.Sk),
+		},
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// An export is a zip of manifest.json, describing every meditation and
+// sequence a user created, and the audio and images they use under audio/
+// and images/.
+
+const (
+	EXPORT_FORMAT        = 1
+	EXPORT_MANIFEST_FILE = "manifest.json"
+	EXPORT_LINK_TTL      = 15 * time.Minute
+)
+
+type ExportManifest struct {
+	Format      int                  `json:"format"`
+	ExportedAt  time.Time            `json:"exportedAt"`
+	UserId      string               `json:"userId"`
+	Meditations []ExportedMeditation `json:"meditations"`
+	Sequences   []ExportedSequence   `json:"sequences"`
+}
+
+type ExportedMeditation struct {
+	Meditation
+	AudioFile string `json:"audioFile,omitempty"` // path in the archive
+}
+
+// ExportedSequence keeps the sequence's order and visibility. Its steps
+// refer to meditations by their ids in the manifest.
+type ExportedSequence struct {
+	Sequence
+	ImageFile string `json:"imageFile,omitempty"` // path in the archive
+}
+
+type ExportResponse struct {
+	DownloadURL string    `json:"downloadUrl"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Meditations int       `json:"meditationCount"`
+	Sequences   int       `json:"sequenceCount"`
+}
+
+// bucketKey is where the file behind one of our public URLs is kept.
+func bucketKey(publicURL string) string {
+	if publicURL == "" {
+		return ""
+	}
+	return "public/" + path.Base(publicURL)
+}
+
+func newExportManifest(userId string, meditations []Meditation, sequences []Sequence, now time.Time) ExportManifest {
+	manifest := ExportManifest{
+		Format:      EXPORT_FORMAT,
+		ExportedAt:  now.UTC(),
+		UserId:      userId,
+		Meditations: []ExportedMeditation{},
+		Sequences:   []ExportedSequence{},
+	}
+	for _, m := range meditations {
+		exported := ExportedMeditation{Meditation: m}
+		if m.URL != "" {
+			exported.AudioFile = "audio/" + m.ID + path.Ext(m.URL)
+		}
+		manifest.Meditations = append(manifest.Meditations, exported)
+	}
+	for _, s := range sequences {
+		s.Meditations = nil
+		exported := ExportedSequence{Sequence: s}
+		if s.ImageURL != "" {
+			exported.ImageFile = "images/" + s.ID + path.Ext(s.ImageURL)
+		}
+		manifest.Sequences = append(manifest.Sequences, exported)
+	}
+	return manifest
+}
+
+// writeExportArchive writes the manifest and its files as a zip, reading each
+// file with `open` by its bucket key.
+func writeExportArchive(w io.Writer, manifest ExportManifest, open func(key string) (io.ReadCloser, error)) error {
+	archive := zip.NewWriter(w)
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := archive.Create(EXPORT_MANIFEST_FILE)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(manifestJson)
+	if err != nil {
+		return err
+	}
+
+	copyFile := func(name string, publicURL string) error {
+		src, err := open(bucketKey(publicURL))
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		// audio and images are compressed already
+		dst, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, src)
+		return err
+	}
+	for _, m := range manifest.Meditations {
+		if m.AudioFile == "" {
+			continue
+		}
+		err := copyFile(m.AudioFile, m.URL)
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range manifest.Sequences {
+		if s.ImageFile == "" {
+			continue
+		}
+		err := copyFile(s.ImageFile, s.ImageURL)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// saveExportArchive streams the archive into the bucket at `key` and returns
+// a link to download it.
+func saveExportArchive(key string, manifest ExportManifest, config *aws.Config) (string, error) {
+	sess, _ := session.NewSession(config)
+	svc := s3.New(sess)
+	bucket := os.Getenv("AUDIO_BUCKET")
+
+	open := func(key string) (io.ReadCloser, error) {
+		resp, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return ioutil.NopCloser(&bytes.Buffer{}), err
+		}
+		return resp.Body, nil
+	}
+
+	// zip into one end of a pipe while the uploader reads the other, so the
+	// archive is never held in memory
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeExportArchive(writer, manifest, open))
+	}()
+	_, err := s3manager.NewUploader(sess).Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        reader,
+		ContentType: aws.String("application/zip"),
+	})
+	reader.CloseWithError(err)
+	if err != nil {
+		return "", err
+	}
+
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(`attachment; filename="tempora-export-` + manifest.ExportedAt.Format("2006-01-02") + `.zip"`),
+	})
+	return req.Presign(EXPORT_LINK_TTL)
+}
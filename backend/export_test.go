@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestExportArchive(t *testing.T) {
+	meditations := []Meditation{
+		{ID: "m1", Name: "On Prayer 1", URL: "https://audio.example.com/m1.mp3", Public: true},
+		{ID: "m2", Name: "On Prayer 2", URL: "https://audio.example.com/m2-1620000000.m4a"},
+	}
+	sequences := []Sequence{{
+		ID:          "s1",
+		Name:        "On Prayer",
+		ImageURL:    "https://audio.example.com/s1.png",
+		Meditations: meditations,
+		Steps:       []SequenceStep{{Type: StepMeditation, MeditationID: "m2"}, {Type: StepSilence, Seconds: 30}, {Type: StepMeditation, MeditationID: "m1"}},
+	}}
+	manifest := newExportManifest("alex", meditations, sequences, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	files := map[string]string{
+		"public/m1.mp3":            "mp3 audio",
+		"public/m2-1620000000.m4a": "m4a audio",
+		"public/s1.png":            "png image",
+	}
+	open := func(key string) (io.ReadCloser, error) {
+		content, ok := files[key]
+		if !ok {
+			return nil, errors.New("no such key " + key)
+		}
+		return ioutil.NopCloser(bytes.NewBufferString(content)), nil
+	}
+	var buf bytes.Buffer
+	err := writeExportArchive(&buf, manifest, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		b, _ := ioutil.ReadAll(r)
+		contents[f.Name] = string(b)
+	}
+	expectedFiles := map[string]string{
+		"audio/m1.mp3":  "mp3 audio",
+		"audio/m2.m4a":  "m4a audio",
+		"images/s1.png": "png image",
+	}
+	for name, content := range expectedFiles {
+		if contents[name] != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, contents[name])
+		}
+	}
+
+	read := ExportManifest{}
+	err = json.Unmarshal([]byte(contents[EXPORT_MANIFEST_FILE]), &read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Format != EXPORT_FORMAT || len(read.Meditations) != 2 || !read.Meditations[0].Public {
+		t.Errorf("Unexpected manifest %+v", read)
+	}
+	if diff := deep.Equal(read.Sequences[0].Steps, sequences[0].Steps); diff != nil {
+		t.Error(diff)
+	}
+	if read.Sequences[0].Meditations != nil || read.Sequences[0].ImageFile != "images/s1.png" {
+		t.Errorf("Unexpected sequence %+v", read.Sequences[0])
+	}
+
+	// a missing file fails the export rather than leaving it incomplete
+	delete(files, "public/s1.png")
+	err = writeExportArchive(ioutil.Discard, manifest, open)
+	if err == nil {
+		t.Error("Expected a missing image to fail the export")
+	}
+}
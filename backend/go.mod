@@ -4,15 +4,15 @@ go 1.16
 
 require (
 	github.com/abema/go-mp4 v0.6.0
-	github.com/aws/aws-lambda-go v1.23.0 // indirect
-	github.com/aws/aws-sdk-go v1.38.17 // indirect
+	github.com/aws/aws-lambda-go v1.23.0
+	github.com/aws/aws-sdk-go v1.38.17
 	github.com/go-playground/universal-translator v0.17.0 // indirect
 	github.com/go-playground/validator v9.31.0+incompatible // indirect
-	github.com/go-playground/validator/v10 v10.5.0 // indirect
+	github.com/go-playground/validator/v10 v10.5.0
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
 	github.com/go-test/deep v1.0.7
-	github.com/hajimehoshi/go-mp3 v0.3.2 // indirect
+	github.com/hajimehoshi/go-mp3 v0.3.2
 	github.com/leodido/go-urn v1.2.1 // indirect
-	github.com/satori/go.uuid v1.2.0 // indirect
-	github.com/segmentio/ksuid v1.0.3 // indirect
+	github.com/satori/go.uuid v1.2.0
+	github.com/segmentio/ksuid v1.0.3
 )
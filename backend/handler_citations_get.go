@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListAuthorsHandler handles GET /public/authors, the authors cited by public
+// meditations. Each one's key lists its meditations with
+// /public/meditations?author=<key>.
+func ListAuthorsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	return listCitationIndex("author#", store)
+}
+
+// ListWorksHandler handles GET /public/works, the works (including books of
+// the Bible) cited by public meditations. Each one's key lists its
+// meditations with /public/meditations?work=<key>.
+func ListWorksHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	return listCitationIndex("work#", store)
+}
+
+func listCitationIndex(prefix string, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	counts, err := store.ListCitationIndex(prefix)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&counts)
+	return successful(string(responseBodyBytes))
+}
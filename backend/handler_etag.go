@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// getHeader looks up a request header. API Gateway lower-cases header names,
+// but requests built by hand (e.g. in tests) may not.
+func getHeader(req events.APIGatewayV2HTTPRequest, name string) (string, bool) {
+	if value, ok := req.Headers[strings.ToLower(name)]; ok {
+		return value, true
+	}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// ifMatches reports whether an If-Match header value names `version`.
+func ifMatches(ifMatch string, version int64) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch requires an If-Match header naming the current version of the
+// resource, returning a 428 when it's missing and a 412 when it's stale.
+func checkIfMatch(req events.APIGatewayV2HTTPRequest, currentVersion int64) *events.APIGatewayV2HTTPResponse {
+	ifMatch, ok := getHeader(req, "If-Match")
+	if !ok || strings.TrimSpace(ifMatch) == "" {
+		return preconditionRequired("an If-Match header with the resource's ETag is required")
+	}
+	if !ifMatches(ifMatch, currentVersion) {
+		return preconditionFailed("the resource has been modified; fetch it again and retry")
+	}
+	return nil
+}
+
+func withETag(resp *events.APIGatewayV2HTTPResponse, version int64) *events.APIGatewayV2HTTPResponse {
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	resp.Headers["ETag"] = etag(version)
+	return resp
+}
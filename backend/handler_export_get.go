@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/segmentio/ksuid"
+)
+
+// GetExportHandler handles GET /me/export. It zips up everything the caller
+// created (see ExportManifest) and returns a link to download the archive,
+// good for EXPORT_LINK_TTL.
+func GetExportHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	meditations, err := store.ListMeditations(userId)
+	if err != nil {
+		return internalServerError("Problem listing the meditations for userId " + userId)
+	}
+	sequences, err := store.ListSequencesByUserId(userId)
+	if err != nil {
+		return internalServerError("Problem listing the sequences for userId " + userId)
+	}
+
+	now := time.Now()
+	manifest := newExportManifest(userId, meditations, sequences, now)
+	key := "exports/" + userId + "/" + ksuid.New().String() + ".zip"
+	url, err := saveExportArchive(key, manifest, awsConfig)
+	if err != nil {
+		return internalServerError("Could not build the export: " + err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&ExportResponse{
+		DownloadURL: url,
+		ExpiresAt:   now.Add(EXPORT_LINK_TTL).UTC(),
+		Meditations: len(manifest.Meditations),
+		Sequences:   len(manifest.Sequences),
+	})
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ImportArchiveHandler handles POST /me/import, recreating the contents of an
+// export archive, uploaded through /upload-url, for the caller. Items that
+// can't be imported are reported rather than failing the whole import.
+func ImportArchiveHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// parse the request body
+	input := ImportArchiveInput{}
+	err := json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	content, err := downloadUpload(input.UploadKey, MAX_IMPORT_ARCHIVE_SIZE, awsConfig)
+	if err != nil {
+		return badRequest("Could not read the upload: " + err.Error())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return badRequest("The upload is not a zip archive.")
+	}
+
+	imp := ArchiveImporter{store: store, userId: userId, dryRun: input.DryRun}
+	report, err := imp.importArchive(archive)
+	if errors.Is(err, ErrInvalidArchive) {
+		return badRequest(err.Error())
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&report)
+	return successful(string(responseBodyBytes))
+}
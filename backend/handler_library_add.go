@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// libraryItem reads which meditation or sequence a /library/... path names.
+func libraryItem(req events.APIGatewayV2HTTPRequest) (string, string, bool) {
+	if id, ok := req.PathParameters["meditationId"]; ok {
+		return LibraryMeditation, id, true
+	}
+	if id, ok := req.PathParameters["sequenceId"]; ok {
+		return LibrarySequence, id, true
+	}
+	return "", "", false
+}
+
+// AddToLibraryHandler handles PUT /library/meditations/{meditationId} and
+// PUT /library/sequences/{sequenceId}. Only public items can be saved.
+func AddToLibraryHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	itemType, itemId, ok := libraryItem(req)
+	if !ok {
+		return badRequest("no :meditationId or :sequenceId found as a path parameter")
+	}
+
+	// check the item exists and is public
+	if itemType == LibraryMeditation {
+		meditation, err := store.GetMeditation(itemId)
+		if err != nil || meditation.ID == "" || !meditation.Public {
+			return notFound("no public meditation with id " + itemId + " was found")
+		}
+	} else {
+		record, err := store.getSequenceRecord(itemId)
+		if err != nil || !record.Sequence.Sequence.Public {
+			return notFound("no public sequence with id " + itemId + " was found")
+		}
+	}
+
+	err := store.AddToLibrary(userId, itemType, itemId)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      204,
+		IsBase64Encoded: false,
+		Body:            string(""),
+		Headers:         map[string]string{},
+	}
+}
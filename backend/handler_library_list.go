@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	DEFAULT_LIBRARY_PAGE_SIZE = 25
+	MAX_LIBRARY_PAGE_SIZE     = 100
+)
+
+type LibraryItem struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	Available  bool        `json:"available"` // false once the author makes it private or deletes it
+	Meditation *Meditation `json:"meditation,omitempty"`
+	Sequence   *Sequence   `json:"sequence,omitempty"`
+}
+
+type LibraryPage struct {
+	Items     []LibraryItem `json:"items"`
+	NextToken string        `json:"nextToken,omitempty"`
+}
+
+// ListLibraryHandler handles GET /library. `type` (meditation or sequence)
+// narrows the listing, `limit` sets the page size and `nextToken` continues
+// from the previous page.
+func ListLibraryHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// parse the query
+	itemType := req.QueryStringParameters["type"]
+	if itemType != "" && itemType != LibraryMeditation && itemType != LibrarySequence {
+		return badRequest("type must be meditation or sequence")
+	}
+	limit := int64(DEFAULT_LIBRARY_PAGE_SIZE)
+	if v, ok := req.QueryStringParameters["limit"]; ok {
+		l, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || l < 1 || l > MAX_LIBRARY_PAGE_SIZE {
+			return badRequest("limit must be between 1 and " + strconv.Itoa(MAX_LIBRARY_PAGE_SIZE))
+		}
+		limit = l
+	}
+
+	records, nextToken, err := store.ListLibrary(userId, itemType, limit, req.QueryStringParameters["nextToken"])
+	if errors.Is(err, ErrInvalidPageToken) {
+		return badRequest(err.Error())
+	}
+	if err != nil {
+		return internalServerError("Problem listing the library for userId " + userId)
+	}
+
+	// inflate the entries
+	meditationIds, sequenceIds := []string{}, []string{}
+	for _, r := range records {
+		if r.ItemType == LibraryMeditation {
+			meditationIds = append(meditationIds, r.ItemID)
+		} else {
+			sequenceIds = append(sequenceIds, r.ItemID)
+		}
+	}
+	meditations, err := store.GetMeditationsByIds(meditationIds)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	idToMeditation := make(map[string]Meditation)
+	for _, m := range meditations {
+		idToMeditation[m.ID] = m
+	}
+	sequences, err := store.GetSequencesByIds(sequenceIds)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	idToSequence := make(map[string]Sequence)
+	for _, s := range sequences {
+		idToSequence[s.ID] = s
+	}
+
+	page := LibraryPage{
+		Items:     []LibraryItem{},
+		NextToken: nextToken,
+	}
+	for _, r := range records {
+		item := LibraryItem{Type: r.ItemType, ID: r.ItemID}
+		if r.ItemType == LibraryMeditation {
+			if m, ok := idToMeditation[r.ItemID]; ok && (m.Public || m.UserId == userId) {
+				item.Available = true
+				item.Meditation = &m
+			}
+		} else {
+			if s, ok := idToSequence[r.ItemID]; ok && (s.Public || s.UserId == userId) {
+				item.Available = true
+				item.Sequence = &s
+			}
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&page)
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RemoveFromLibraryHandler handles DELETE /library/meditations/{meditationId}
+// and DELETE /library/sequences/{sequenceId}.
+func RemoveFromLibraryHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	itemType, itemId, ok := libraryItem(req)
+	if !ok {
+		return badRequest("no :meditationId or :sequenceId found as a path parameter")
+	}
+
+	err := store.RemoveFromLibrary(userId, itemType, itemId)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      204,
+		IsBase64Encoded: false,
+		Body:            string(""),
+		Headers:         map[string]string{},
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 func CreateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get user id
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -37,15 +37,27 @@ func CreateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 	if err != nil {
 		return badRequest(err.Error())
 	}
+	tags, categories, err := normalizeTaxonomy(input.Tags, input.Categories)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	citation, err := normalizeCitation(input.Citation)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	now := time.Now()
+	publication, err := nextPublication(Publication{Status: StatusDraft}, input.Status, input.PublishAt, input.Public, now)
+	if err != nil {
+		return badRequest(err.Error())
+	}
 
 	// ensure the key is in s3 and that we have an mp3
-	fileExt, err := ValidateAudio(input.UploadKey, awsConfig)
+	fileExt, duration, err := ValidateAudio(input.UploadKey, awsConfig)
 	if err != nil {
 		return badRequest("Provided file is not a properly encoded mp3.")
 	}
 
 	id := ksuid.New().String()
-	now := time.Now()
 
 	// move the mp3 to public and rename
 	suffix := id + fileExt // e.g. 1235456.m4a
@@ -59,13 +71,19 @@ func CreateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 	newMeditation := Meditation{
 		ID:        id,
 		URL:       mapPathSuffixToFullURL(suffix),
+		Duration:  duration,
 		Name:      input.Name,
 		Text:      input.Text,
-		Public:    input.Public,
-		UserId:    userId,
+		UserId:    caller.UserId,
 		CreatedAt: now,
 		UpdatedAt: now,
+
+		AuthorName: authorName(req),
+		Tags:       tags,
+		Categories: categories,
+		Citation:   citation,
 	}
+	newMeditation.publishAs(caller, publication, now)
 
 	// save to DDB
 	err = store.SaveMeditation(newMeditation)
@@ -77,5 +95,16 @@ func CreateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 	responseBodyBytes, _ := json.Marshal(&newMeditation)
 	resp := entityCreated(string(responseBodyBytes))
 
-	return resp
+	return withETag(resp, newMeditation.Version)
+}
+
+// authorName is the display name the identity provider put in the token, if
+// any, used to credit the author when others reuse a meditation.
+func authorName(req events.APIGatewayV2HTTPRequest) string {
+	for _, claim := range []string{"name", "nickname"} {
+		if name, ok := req.RequestContext.Authorizer.JWT.Claims[claim]; ok && name != "" {
+			return name
+		}
+	}
+	return ""
 }
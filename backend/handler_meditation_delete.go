@@ -1,10 +1,15 @@
 package main
 
-import "github.com/aws/aws-lambda-go/events"
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
 
 func DeleteMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get the userId
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -18,14 +23,43 @@ func DeleteMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 
 	// attempt to delete the meditation
 	oldMeditation, err := store.GetMeditation(meditationId)
-	if err != nil {
+	if err != nil || oldMeditation.ID == "" {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
-	if oldMeditation.UserId != userId {
+	if !caller.canEdit(oldMeditation.UserId) {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
+	if resp := checkIfMatch(req, oldMeditation.Version); resp != nil {
+		return resp
+	}
+
+	// an admin deleting someone else's meditation takes it down, so it
+	// can't be restored straight back into public
+	version := oldMeditation.Version
+	if caller.UserId != oldMeditation.UserId {
+		oldMeditation.takeDown(time.Now())
+		err = store.UpdateMeditation(oldMeditation)
+		if errors.Is(err, ErrVersionMismatch) {
+			return preconditionFailed("the meditation has been modified; fetch it again and retry")
+		}
+		if err != nil {
+			return internalServerError(err.Error())
+		}
+		version++
+	}
 
-	err = store.DeleteMeditation(meditationId)
+	// with ?cascade=true, pull the meditation out of its author's sequences first
+	if req.QueryStringParameters["cascade"] == "true" {
+		err = store.DeleteMeditationCascade(meditationId, version)
+	} else {
+		err = store.DeleteMeditationIfVersion(meditationId, version)
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		return preconditionFailed("the meditation has been modified; fetch it again and retry")
+	}
+	if errors.Is(err, ErrMeditationInUse) {
+		return conflict("Meditation " + meditationId + " is still part of one or more of its author's sequences, counting any in the trash")
+	}
 	if err != nil {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
@@ -8,7 +8,7 @@ import (
 
 func GetMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get the user ID
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -19,10 +19,10 @@ func GetMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedit
 		return internalServerError("No {meditationId{} found in path parameters")
 	}
 	meditation, err := store.GetMeditation(meditationId)
-	if err != nil {
+	if err != nil || meditation.ID == "" {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
-	if meditation.UserId != userId {
+	if !caller.canEdit(meditation.UserId) {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
 
@@ -34,6 +34,7 @@ func GetMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedit
 		Body:            string(meditationJson),
 		Headers: map[string]string{
 			"Content-Type": "application/json",
+			"ETag":         etag(meditation.Version),
 		},
 	}
 	return &resp
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// PatchMeditationHandler applies a JSON Merge Patch or JSON Patch to the
+// updatable fields of a meditation, e.g. {"isPublic": true}.
+func PatchMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// Get the userId from headers
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// Get the meditationId from path
+	meditationId, ok := req.PathParameters["meditationId"]
+	if !ok {
+		return internalServerError("No {meditationId{} found in path parameters")
+	}
+
+	// Get the meditation from the DB
+	meditation, err := store.GetMeditation(meditationId)
+	if err != nil || meditation.ID == "" {
+		return notFound("No meditation with id " + meditationId + " was found")
+	}
+	if !caller.canEdit(meditation.UserId) {
+		return notFound("No meditation with id " + meditationId + " was found")
+	}
+	if resp := checkIfMatch(req, meditation.Version); resp != nil {
+		return resp
+	}
+
+	// Patch the fields a PUT would send
+	current, _ := json.Marshal(UpdateMeditationInput{
+		Name:       meditation.Name,
+		Text:       meditation.Text,
+		Public:     meditation.Public,
+		Status:     meditation.Status,
+		PublishAt:  meditation.PublishAt,
+		Tags:       meditation.Tags,
+		Categories: meditation.Categories,
+		Citation:   meditation.Citation,
+	})
+	contentType, _ := getHeader(req, "Content-Type")
+	patched, err := applyPatch(contentType, current, []byte(req.Body))
+	if errors.Is(err, ErrUnsupportedPatchType) {
+		return unsupportedMediaType(err.Error())
+	}
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	newMeditationInput := UpdateMeditationInput{}
+	err = strictUnmarshal(patched, &newMeditationInput)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	newMeditationInput.Status, newMeditationInput.PublishAt = patchedPublication(meditation.publication(), newMeditationInput.Status, newMeditationInput.PublishAt)
+
+	return applyMeditationUpdate(caller, meditation, newMeditationInput, store)
+}
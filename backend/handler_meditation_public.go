@@ -6,9 +6,17 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
+// ListPublicMeditationsHandler lists every public meditation, or with `tag`,
+// `category`, `author` or `work` only the ones filed under or citing it.
 func ListPublicMeditationsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// list the public meditations
-	meditations, err := store.ListPublicMeditations()
+	var meditations []Meditation
+	var err error
+	if indexKey, ok := taxonomyFilter(req); ok {
+		meditations, err = store.ListPublicMeditationsByIndex(indexKey)
+	} else {
+		meditations, err = store.ListPublicMeditations()
+	}
 	if err != nil {
 		resp := events.APIGatewayV2HTTPResponse{
 			StatusCode:      500,
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// revisionVersion reads the {version} path parameter.
+func revisionVersion(req events.APIGatewayV2HTTPRequest) (int64, *events.APIGatewayV2HTTPResponse) {
+	version, err := strconv.ParseInt(req.PathParameters["version"], 10, 64)
+	if err != nil || version < 0 {
+		return 0, badRequest("the revision's version must be a whole number")
+	}
+	return version, nil
+}
+
+// getOwnMeditation loads the meditation named in the path, provided the
+// caller may edit it.
+func getOwnMeditation(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) (Meditation, *events.APIGatewayV2HTTPResponse) {
+	// Get the userId from headers
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return Meditation{}, userIdNotFoundError()
+	}
+
+	// Get the meditationId from path
+	meditationId, ok := req.PathParameters["meditationId"]
+	if !ok {
+		return Meditation{}, internalServerError("No {meditationId{} found in path parameters")
+	}
+	meditation, err := store.GetMeditation(meditationId)
+	if err != nil || meditation.ID == "" || !caller.canEdit(meditation.UserId) {
+		return Meditation{}, notFound("No meditation with id " + meditationId + " was found")
+	}
+	return meditation, nil
+}
+
+// ListMeditationRevisionsHandler handles GET
+// /meditations/{meditationId}/revisions, listing the meditation's earlier
+// versions newest first, each with the fields the next edit changed.
+func ListMeditationRevisionsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	meditation, errResp := getOwnMeditation(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	revisions, err := store.ListMeditationRevisions(meditation.ID)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	next := meditation
+	for i := range revisions {
+		snapshot := *revisions[i].Meditation
+		revisions[i].Changed = changedFields(meditationChanges(snapshot, next))
+		revisions[i].Meditation = nil
+		next = snapshot
+	}
+
+	responseBodyBytes, _ := json.Marshal(revisions)
+	return withETag(successful(string(responseBodyBytes)), meditation.Version)
+}
+
+// GetMeditationRevisionHandler handles GET
+// /meditations/{meditationId}/revisions/{version}, returning the meditation
+// as it was and how it has changed since.
+func GetMeditationRevisionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	meditation, errResp := getOwnMeditation(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	version, errResp := revisionVersion(req)
+	if errResp != nil {
+		return errResp
+	}
+	revision, err := store.GetMeditationRevision(meditation.ID, version)
+	if errors.Is(err, ErrRevisionNotFound) {
+		return notFound("No revision " + strconv.FormatInt(version, 10) + " of meditation " + meditation.ID + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	revision.Changes = meditationChanges(*revision.Meditation, meditation)
+
+	responseBodyBytes, _ := json.Marshal(&revision)
+	return withETag(successful(string(responseBodyBytes)), meditation.Version)
+}
+
+// RestoreMeditationRevisionHandler handles POST
+// /meditations/{meditationId}/revisions/{version}/restore. The revision's
+// content is saved as a new version, so the restore can itself be undone.
+// Its publication and moderation are left as they are now.
+func RestoreMeditationRevisionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	meditation, errResp := getOwnMeditation(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	if resp := checkIfMatch(req, meditation.Version); resp != nil {
+		return resp
+	}
+	version, errResp := revisionVersion(req)
+	if errResp != nil {
+		return errResp
+	}
+	revision, err := store.GetMeditationRevision(meditation.ID, version)
+	if errors.Is(err, ErrRevisionNotFound) {
+		return notFound("No revision " + strconv.FormatInt(version, 10) + " of meditation " + meditation.ID + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// old audio files are never deleted, so the old URL still plays
+	old := revision.Meditation
+	before := meditation
+	meditation.UpdatedAt = time.Now()
+	meditation.URL = old.URL
+	meditation.Duration = old.Duration
+	meditation.Name = old.Name
+	meditation.Text = old.Text
+	meditation.Tags = old.Tags
+	meditation.Categories = old.Categories
+	meditation.Citation = old.Citation
+	caller, _ := callerPrincipal(req)
+	meditation.reviewEdits(caller, before)
+	err = store.UpdateMeditation(meditation)
+	if errors.Is(err, ErrVersionMismatch) {
+		return preconditionFailed("the meditation has been modified; fetch it again and retry")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	meditation.Version++
+
+	responseBodyBytes, _ := json.Marshal(&meditation)
+	return withETag(successful(string(responseBodyBytes)), meditation.Version)
+}
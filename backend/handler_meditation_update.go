@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 
 func UpdateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// Get the userId from headers
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -23,30 +24,53 @@ func UpdateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 
 	// Get the meditation from the DB
 	meditation, err := store.GetMeditation(meditationId)
-	if err != nil {
+	if err != nil || meditation.ID == "" {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
-	if meditation.UserId != userId {
+	if !caller.canEdit(meditation.UserId) {
 		return notFound("No meditation with id " + meditationId + " was found")
 	}
+	if resp := checkIfMatch(req, meditation.Version); resp != nil {
+		return resp
+	}
 
-	// Parse and validate the request body
+	// Parse the request body
 	newMeditationInput := UpdateMeditationInput{}
 	err = json.Unmarshal([]byte(req.Body), &newMeditationInput)
 	if err != nil {
 		// couldn't even unmarshal the json
 		return badRequest(err.Error())
 	}
-	err = validate.Struct(newMeditationInput)
+
+	return applyMeditationUpdate(caller, meditation, newMeditationInput, store)
+}
+
+// applyMeditationUpdate validates `newMeditationInput` and saves it over
+// `meditation` for `caller`. It's shared by PUT and PATCH.
+func applyMeditationUpdate(caller Principal, meditation Meditation, newMeditationInput UpdateMeditationInput, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	before := meditation
+	err := validate.Struct(newMeditationInput)
 	if err != nil {
 		// failed validation
 		return badRequest(err.Error())
 	}
+	tags, categories, err := normalizeTaxonomy(newMeditationInput.Tags, newMeditationInput.Categories)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	citation, err := normalizeCitation(newMeditationInput.Citation)
+	if err != nil {
+		return badRequest(err.Error())
+	}
 	now := time.Now()
+	publication, err := nextPublication(meditation.publication(), newMeditationInput.Status, newMeditationInput.PublishAt, newMeditationInput.Public, now)
+	if err != nil {
+		return badRequest(err.Error())
+	}
 	// if we have a non-zero upload key, that means
 	// we need to run through the validate -> copy to public prefix logic
 	if newMeditationInput.UploadKey != "" {
-		fileExt, err := ValidateAudio(newMeditationInput.UploadKey, awsConfig)
+		fileExt, duration, err := ValidateAudio(newMeditationInput.UploadKey, awsConfig)
 		if err != nil {
 			return badRequest("Provided file is not a properly encoded mp3 or m4a.")
 		}
@@ -55,6 +79,7 @@ func UpdateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 		newPath := "public/" + suffix
 
 		meditation.URL = mapPathSuffixToFullURL(suffix)
+		meditation.Duration = duration
 		err = RenameAudio(newMeditationInput.UploadKey, newPath, awsConfig)
 		if err != nil {
 			return internalServerError("Could not rename audio file")
@@ -66,8 +91,19 @@ func UpdateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 	meditation.UpdatedAt = time.Now()
 	meditation.Name = newMeditationInput.Name
 	meditation.Text = newMeditationInput.Text
-	meditation.Public = newMeditationInput.Public
-	store.UpdateMeditation(meditation)
+	meditation.publishAs(caller, publication, now)
+	meditation.Tags = tags
+	meditation.Categories = categories
+	meditation.Citation = citation
+	meditation.reviewEdits(caller, before)
+	err = store.UpdateMeditation(meditation)
+	if errors.Is(err, ErrVersionMismatch) {
+		return preconditionFailed("the meditation has been modified; fetch it again and retry")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	meditation.Version++
 
 	// build the response
 	responseJson, _ := json.Marshal(meditation)
@@ -77,6 +113,7 @@ func UpdateMeditationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMe
 		Body:            string(responseJson),
 		Headers: map[string]string{
 			"Content-Type": "application/json",
+			"ETag":         etag(meditation.Version),
 		},
 	}
 }
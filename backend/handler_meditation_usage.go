@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type SequenceReference struct {
+	ID      string `json:"_id"`
+	UserId  string `json:"_userId"`
+	Name    string `json:"name"`
+	Public  bool   `json:"isPublic"`
+	InTrash bool   `json:"inTrash,omitempty"` // still holds on to the meditation until purged
+}
+
+type MeditationUsage struct {
+	Sequences []SequenceReference `json:"sequences"`
+	// other users' private sequences are only counted
+	PrivateCount int `json:"privateSequenceCount"`
+}
+
+// GetMeditationUsageHandler handles GET /meditations/{meditationId}/usage,
+// telling a meditation's author which sequences use it.
+func GetMeditationUsageHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get the user ID
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// get the meditation
+	meditationId, ok := req.PathParameters["meditationId"]
+	if !ok {
+		return internalServerError("No {meditationId{} found in path parameters")
+	}
+	meditation, err := store.GetMeditation(meditationId)
+	if err != nil || meditation.ID == "" || !caller.canEdit(meditation.UserId) {
+		return notFound("No meditation with id " + meditationId + " was found")
+	}
+
+	// look up every sequence that references it
+	sequenceIds, err := store.GetSequenceIdsByMeditationId(meditationId)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	usage := MeditationUsage{
+		Sequences: []SequenceReference{},
+	}
+	for _, sequenceId := range sequenceIds {
+		record, err := store.getAnySequenceRecord(sequenceId)
+		if err != nil {
+			continue
+		}
+		s := record.toSequence()
+		if s.UserId != meditation.UserId && (!s.Public || record.DeletedAt != "") {
+			usage.PrivateCount++
+			continue
+		}
+		usage.Sequences = append(usage.Sequences, SequenceReference{
+			ID:      s.ID,
+			UserId:  s.UserId,
+			Name:    s.Name,
+			Public:  s.Public,
+			InTrash: record.DeletedAt != "",
+		})
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&usage)
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ModerateHandler handles POST /moderation/{itemId}/approve and
+// /moderation/{itemId}/reject, deciding on a meditation or sequence in the
+// queue. A rejection needs a reason, which is passed on to the author along
+// with the decision. An approved item can still be rejected to take it down.
+// Either decision settles any reports on the item.
+func ModerateHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+	if !caller.canModerate() {
+		return forbidden("only moderators can approve or reject items")
+	}
+
+	itemId, ok := req.PathParameters["itemId"]
+	if !ok {
+		return badRequest("no :itemId found as a path parameter")
+	}
+
+	// parse and validate the request body, which approvals can leave out
+	input := ModerationInput{}
+	if strings.TrimSpace(req.Body) != "" {
+		err := json.Unmarshal([]byte(req.Body), &input)
+		if err != nil {
+			return badRequest("Invalid request " + err.Error())
+		}
+	}
+	err := validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	decision := Moderation{
+		Approved:    strings.HasSuffix(req.RequestContext.HTTP.Path, "/approve"),
+		Reason:      strings.TrimSpace(input.Reason),
+		ModeratorId: caller.UserId,
+		At:          time.Now(),
+	}
+	if !decision.Approved && decision.Reason == "" {
+		return badRequest("a rejection needs a reason")
+	}
+
+	// meditations and sequences share the queue, so try both
+	meditation, err := store.GetMeditation(itemId)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	if meditation.ID != "" {
+		if resp := checkIfMatch(req, meditation.Version); resp != nil {
+			return resp
+		}
+		err = meditation.moderate(decision)
+		if errors.Is(err, ErrNotModerated) {
+			return conflict("meditation " + itemId + " is not awaiting moderation")
+		}
+		err = store.UpdateMeditation(meditation)
+		if errors.Is(err, ErrVersionMismatch) {
+			return preconditionFailed("the meditation has been modified; fetch it again and retry")
+		}
+		if err != nil {
+			return internalServerError(err.Error())
+		}
+		meditation.Version++
+		notifyModeration(store, LibraryMeditation, meditation.ID, meditation.Name, meditation.UserId, meditation.Moderation, decision)
+		settleReports(store, "med#"+meditation.ID)
+
+		responseBodyBytes, _ := json.Marshal(&meditation)
+		return withETag(successful(string(responseBodyBytes)), meditation.Version)
+	}
+
+	sequence, err := store.GetSequenceById(itemId)
+	if errors.Is(err, ErrSequenceNotFound) {
+		return notFound("No meditation or sequence with id " + itemId + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	if resp := checkIfMatch(req, sequence.Version); resp != nil {
+		return resp
+	}
+	err = sequence.moderate(decision)
+	if errors.Is(err, ErrNotModerated) {
+		return conflict("sequence " + itemId + " is not awaiting moderation")
+	}
+	err = store.UpdateSequence(sequence)
+	if errors.Is(err, ErrVersionMismatch) {
+		return preconditionFailed("the sequence has been modified; fetch it again and retry")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	notifyModeration(store, LibrarySequence, sequence.ID, sequence.Name, sequence.UserId, sequence.Moderation, decision)
+	settleReports(store, "seq#"+sequence.ID)
+	return sequenceItemsResponse(sequence.ID, nil, store)
+}
+
+// notifyModeration tells an item's author what the moderators decided. The
+// decision stands even if the notification can't be sent.
+func notifyModeration(store *DynamoMeditationStore, itemType string, itemId string, itemName string, authorId string, result string, decision Moderation) {
+	_, err := store.SaveNotification(Notification{
+		UserId:    authorId,
+		CreatedAt: decision.At,
+		Type:      NotificationModeration,
+		ItemType:  itemType,
+		ItemID:    itemId,
+		ItemName:  itemName,
+		Result:    result,
+		Reason:    decision.Reason,
+	})
+	if err != nil {
+		log.Printf("could not notify %s of the moderation of %s: %v", authorId, itemId, err)
+	}
+}
+
+// settleReports clears the reports on the item at `pk` once a moderator has
+// decided on it.
+func settleReports(store *DynamoMeditationStore, pk string) {
+	err := store.ClearReports(pk)
+	if err != nil && !errors.Is(err, ErrNoReports) {
+		log.Printf("could not clear the reports on %s: %v", pk, err)
+	}
+}
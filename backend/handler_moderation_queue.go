@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListModerationQueueHandler handles GET /moderation/queue, listing what
+// awaits review, longest waiting first. Only moderators may see it.
+func ListModerationQueueHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+	if !caller.canModerate() {
+		return forbidden("only moderators can see the moderation queue")
+	}
+
+	items, err := store.ListModerationQueue()
+	if err != nil {
+		return internalServerError("Problem listing the moderation queue")
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&items)
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListReportsHandler handles GET /moderation/reports, listing the reported
+// meditations and sequences, most reported first. Only moderators may see
+// it.
+func ListReportsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+	if !caller.canModerate() {
+		return forbidden("only moderators can see reports")
+	}
+
+	items, err := store.ListReportedItems()
+	if err != nil {
+		return internalServerError("Problem listing reports")
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&items)
+	return successful(string(responseBodyBytes))
+}
+
+// DismissReportsHandler handles DELETE /moderation/reports/{itemId}, clearing
+// an item's reports without a decision. An item the reports hid stays in the
+// moderation queue; approving or rejecting it clears its reports too.
+func DismissReportsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+	if !caller.canModerate() {
+		return forbidden("only moderators can dismiss reports")
+	}
+
+	itemId, ok := req.PathParameters["itemId"]
+	if !ok {
+		return badRequest("no :itemId found as a path parameter")
+	}
+
+	// meditations and sequences share the listing, so try both
+	err := store.ClearReports("med#" + itemId)
+	if errors.Is(err, ErrNoReports) {
+		err = store.ClearReports("seq#" + itemId)
+	}
+	if errors.Is(err, ErrNoReports) {
+		return notFound("No reports on an item with id " + itemId + " were found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      204,
+		IsBase64Encoded: false,
+		Body:            string(""),
+		Headers:         map[string]string{},
+	}
+}
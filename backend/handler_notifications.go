@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListNotificationsHandler handles GET /me/notifications, newest first.
+func ListNotificationsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	notifications, err := store.ListNotifications(userId)
+	if err != nil {
+		return internalServerError("Problem listing notifications for userId " + userId)
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&notifications)
+	return successful(string(responseBodyBytes))
+}
+
+// DeleteNotificationHandler handles DELETE
+// /me/notifications/{notificationId}, dismissing a notification.
+func DeleteNotificationHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	notificationId, ok := req.PathParameters["notificationId"]
+	if !ok {
+		return badRequest("no :notificationId found as a path parameter")
+	}
+
+	err := store.DeleteNotification(userId, notificationId)
+	if errors.Is(err, ErrNotificationNotFound) {
+		return notFound("No notification with id " + notificationId + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      204,
+		IsBase64Encoded: false,
+		Body:            string(""),
+		Headers:         map[string]string{},
+	}
+}
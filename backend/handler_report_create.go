@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// REPORT_RATE_LIMIT is how many reports one reporter can make an hour unless
+// $REPORT_RATE_LIMIT says otherwise.
+const REPORT_RATE_LIMIT = 10
+
+func reportRateLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("REPORT_RATE_LIMIT"))
+	if err != nil || limit < 1 {
+		limit = REPORT_RATE_LIMIT
+	}
+	return limit
+}
+
+// reporterId identifies whoever made a request without keeping their
+// address: by their verified user id if they're signed in, so they can't
+// report again from elsewhere, and otherwise by their address.
+func reporterId(req events.APIGatewayV2HTTPRequest) string {
+	source := req.RequestContext.HTTP.SourceIP
+	if auth := req.RequestContext.Authorizer; auth != nil && auth.JWT != nil {
+		if caller, ok := callerPrincipal(req); ok {
+			source = "sub#" + caller.UserId
+		}
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:16])
+}
+
+// CreateReportHandler handles POST /public/meditations/{meditationId}/reports
+// and /public/sequences/{sequenceId}/reports, reporting a public item to the
+// moderators. Anyone can report, but only so often; reporting an item twice
+// counts once.
+func CreateReportHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// parse and validate the request body
+	input := ReportInput{}
+	err := json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	// only public items can be reported
+	var pk string
+	if meditationId, ok := req.PathParameters["meditationId"]; ok {
+		meditation, err := store.GetMeditation(meditationId)
+		if err != nil {
+			return internalServerError(err.Error())
+		}
+		if !meditation.Public {
+			return notFound("No meditation with id " + meditationId + " was found")
+		}
+		pk = "med#" + meditation.ID
+	} else {
+		sequenceId, ok := req.PathParameters["sequenceId"]
+		if !ok {
+			return badRequest("no :meditationId or :sequenceId found as a path parameter")
+		}
+		sequence, err := store.GetSequenceById(sequenceId)
+		if errors.Is(err, ErrSequenceNotFound) || (err == nil && !sequence.Public) {
+			return notFound("no sequence found for id " + sequenceId)
+		}
+		if err != nil {
+			return internalServerError(err.Error())
+		}
+		pk = "seq#" + sequence.ID
+	}
+
+	// reporting an item again counts once, and not against the limit
+	now := time.Now()
+	reporter := reporterId(req)
+	reported, err := store.HasReported(pk, reporter)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	if reported {
+		return accepted()
+	}
+	err = store.takeRateLimit("report#"+reporter, reportRateLimit(), time.Hour, now)
+	if errors.Is(err, ErrRateLimited) {
+		return tooManyRequests("too many reports; try again later")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	err = store.ReportItem(pk, reporter, Report{Reason: input.Reason, Details: input.Details, ReportedAt: now})
+	if err != nil && !errors.Is(err, ErrAlreadyReported) {
+		return internalServerError(err.Error())
+	}
+
+	return accepted()
+}
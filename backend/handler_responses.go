@@ -36,6 +36,50 @@ func badRequest(msg string) *events.APIGatewayV2HTTPResponse {
 	}
 }
 
+func conflict(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      409,
+		IsBase64Encoded: false,
+		Body:            string(body),
+	}
+}
+
+func preconditionFailed(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      412,
+		IsBase64Encoded: false,
+		Body:            string(body),
+	}
+}
+
+func preconditionRequired(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      428,
+		IsBase64Encoded: false,
+		Body:            string(body),
+	}
+}
+
+func unsupportedMediaType(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      415,
+		IsBase64Encoded: false,
+		Body:            string(body),
+	}
+}
+
 func internalServerError(msg string) *events.APIGatewayV2HTTPResponse {
 	body, _ := json.Marshal(map[string]string{
 		"error": msg,
@@ -68,3 +112,51 @@ func successful(msg string) *events.APIGatewayV2HTTPResponse {
 		},
 	}
 }
+
+func forbidden(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      403,
+		IsBase64Encoded: false,
+		Body:            string(body),
+	}
+}
+
+func tooManyRequests(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      429,
+		IsBase64Encoded: false,
+		Body:            string(body),
+		Headers: map[string]string{
+			"Retry-After": "3600",
+		},
+	}
+}
+
+func unauthorized(msg string) *events.APIGatewayV2HTTPResponse {
+	body, _ := json.Marshal(map[string]string{
+		"error": msg,
+	})
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      401,
+		IsBase64Encoded: false,
+		Body:            string(body),
+		Headers: map[string]string{
+			"WWW-Authenticate": "Bearer",
+		},
+	}
+}
+
+func accepted() *events.APIGatewayV2HTTPResponse {
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      202,
+		IsBase64Encoded: false,
+		Body:            "",
+		Headers:         map[string]string{},
+	}
+}
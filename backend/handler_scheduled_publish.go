@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// PublishScheduledHandler runs every few minutes, publishing the scheduled
+// meditations and sequences that are due (see publication.go).
+func PublishScheduledHandler(event events.CloudWatchEvent) error {
+	store := NewDynamoMeditationStore(os.Getenv("DDB_TABLE"), awsConfig)
+	published, err := store.PublishDue(time.Now())
+	for _, pk := range published {
+		fmt.Println("published " + pk)
+	}
+	// failing has the next run try again anyway
+	return err
+}
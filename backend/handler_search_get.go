@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	DEFAULT_SEARCH_RESULTS = 20
+	MAX_SEARCH_RESULTS     = 50
+)
+
+type SearchResult struct {
+	Type       string      `json:"type"` // meditation or sequence
+	ID         string      `json:"id"`
+	Score      float64     `json:"score"`
+	Snippet    string      `json:"snippet"` // the text around the first match
+	Meditation *Meditation `json:"meditation,omitempty"`
+	Sequence   *Sequence   `json:"sequence,omitempty"`
+}
+
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchPublicHandler handles GET /public/search. `q` is the query: every
+// word must match (in any form, "pray" finds "praying"), and words in double
+// quotes must match as a phrase. `type` (meditation or sequence) narrows the
+// search and `limit` caps the results.
+func SearchPublicHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// parse the query
+	q := strings.TrimSpace(req.QueryStringParameters["q"])
+	if q == "" {
+		return badRequest("q is required")
+	}
+	prefix := ""
+	switch req.QueryStringParameters["type"] {
+	case "":
+	case "meditation":
+		prefix = "med#"
+	case "sequence":
+		prefix = "seq#"
+	default:
+		return badRequest("type must be meditation or sequence")
+	}
+	limit := DEFAULT_SEARCH_RESULTS
+	if v, ok := req.QueryStringParameters["limit"]; ok {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > MAX_SEARCH_RESULTS {
+			return badRequest("limit must be between 1 and " + strconv.Itoa(MAX_SEARCH_RESULTS))
+		}
+		limit = l
+	}
+
+	query := parseSearchQuery(q)
+	hits, err := store.SearchPublic(query, prefix, limit)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// inflate the hits, skipping any the index hasn't caught up with
+	meditationIds := []string{}
+	for _, h := range hits {
+		if strings.HasPrefix(h.Sk, "med#") {
+			meditationIds = append(meditationIds, strings.TrimPrefix(h.Sk, "med#"))
+		}
+	}
+	meditations, err := store.GetMeditationsByIds(meditationIds)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	idToMeditation := make(map[string]Meditation)
+	for _, m := range meditations {
+		idToMeditation[m.ID] = m
+	}
+
+	results := SearchResults{
+		Query:   q,
+		Results: []SearchResult{},
+	}
+	for _, h := range hits {
+		if id := strings.TrimPrefix(h.Sk, "med#"); id != h.Sk {
+			m, ok := idToMeditation[id]
+			if !ok || !m.Public {
+				continue
+			}
+			results.Results = append(results.Results, SearchResult{
+				Type:       LibraryMeditation,
+				ID:         id,
+				Score:      h.Score,
+				Snippet:    searchSnippet(m.Text, query.terms()),
+				Meditation: &m,
+			})
+			continue
+		}
+		id := strings.TrimPrefix(h.Sk, "seq#")
+		record, err := store.getSequenceRecord(id)
+		if err != nil {
+			continue
+		}
+		s := record.toSequence()
+		if !s.Public {
+			continue
+		}
+		results.Results = append(results.Results, SearchResult{
+			Type:     LibrarySequence,
+			ID:       id,
+			Score:    h.Score,
+			Snippet:  searchSnippet(s.Description, query.terms()),
+			Sequence: &s,
+		})
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&results)
+	return successful(string(responseBodyBytes))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -10,7 +11,7 @@ import (
 
 func CreateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get user id
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -37,6 +38,19 @@ func CreateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 	if err != nil {
 		return badRequest(err.Error())
 	}
+	steps, meditationIDs, errResp := inputSteps(input.Steps, input.MeditationIDs)
+	if errResp != nil {
+		return errResp
+	}
+	tags, categories, err := normalizeTaxonomy(input.Tags, input.Categories)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	now := time.Now()
+	publication, err := nextPublication(Publication{Status: StatusDraft}, input.Status, input.PublishAt, input.Public, now)
+	if err != nil {
+		return badRequest(err.Error())
+	}
 
 	// ensure the key is in s3
 	fileExt, err := ValidateImage(input.UploadKey, awsConfig)
@@ -45,7 +59,6 @@ func CreateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 	}
 
 	id := ksuid.New().String()
-	now := time.Now()
 
 	// move the image to public and rename
 	imageName := id + fileExt
@@ -56,16 +69,15 @@ func CreateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 		return internalServerError(err.Error())
 	}
 
-	meditations, err := store.GetMeditationsByIds(input.MeditationIDs)
-	for _, m := range meditations {
-		if m.UserId != userId {
-			return badRequest("one or more of the meditationIDs does not exist")
-		}
-	}
+	// the meditations must be the user's own or public
+	meditations, err := store.GetMeditationsByIds(meditationIDs)
 	if err != nil {
 		return internalServerError(err.Error())
 	}
-	if len(meditations) != len(input.MeditationIDs) {
+	if !store.CanUseMeditations(caller.UserId, meditations) {
+		return badRequest("one or more of the meditationIDs does not exist")
+	}
+	if len(meditations) != len(meditationIDs) {
 		return badRequest("one or more of the meditationIDs does not exit")
 	}
 
@@ -74,22 +86,29 @@ func CreateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 		ImageURL:    mapPathSuffixToFullURL(imageName),
 		Name:        input.Name,
 		Description: input.Description,
-		Public:      input.Public,
-		UserId:      userId,
+		UserId:      caller.UserId,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 		Meditations: meditations,
+		Steps:       steps,
+		Tags:        tags,
+		Categories:  categories,
 	}
+	newSequence.publishAs(caller, publication, now)
 
 	// save to DDB
 	err = store.SaveSequence(newSequence)
 	if err != nil {
+		if errors.Is(err, ErrMeditationMissing) {
+			return badRequest(err.Error())
+		}
 		return internalServerError(err.Error())
 	}
 
 	// build the response
+	newSequence.Steps, newSequence.TotalDuration = timeSteps(steps, meditations)
 	responseBodyBytes, _ := json.Marshal(&newSequence)
 	resp := entityCreated(string(responseBodyBytes))
 
-	return resp
+	return withETag(resp, newSequence.Version)
 }
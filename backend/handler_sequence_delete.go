@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
+	"time"
+
 	"github.com/aws/aws-lambda-go/events"
 )
 
 func DeleteSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get user id
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -22,12 +25,33 @@ func DeleteSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *Dynamo
 	if err != nil {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
-	if sequence.UserId != userId {
+	if !caller.canEdit(sequence.UserId) {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
+	if resp := checkIfMatch(req, sequence.Version); resp != nil {
+		return resp
+	}
+
+	// an admin deleting someone else's sequence takes it down, so it can't
+	// be restored straight back into public
+	version := sequence.Version
+	if caller.UserId != sequence.UserId {
+		sequence.takeDown(time.Now())
+		err = store.UpdateSequence(sequence)
+		if errors.Is(err, ErrVersionMismatch) {
+			return preconditionFailed("the sequence has been modified; fetch it again and retry")
+		}
+		if err != nil {
+			return internalServerError(err.Error())
+		}
+		version++
+	}
 
 	// delete the sequence
-	err = store.DeleteSequenceById(sequenceId)
+	err = store.DeleteSequenceByIdIfVersion(sequenceId, version)
+	if errors.Is(err, ErrVersionMismatch) {
+		return preconditionFailed("the sequence has been modified; fetch it again and retry")
+	}
 	if err != nil {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/segmentio/ksuid"
+)
+
+// ForkSequenceHandler handles POST /public/sequences/{sequenceId}/fork. It
+// makes a private copy of a public sequence for the caller, referencing the
+// same meditations.
+func ForkSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// get the sequence
+	sequenceId, ok := req.PathParameters["sequenceId"]
+	if !ok {
+		return badRequest("no :sequenceId found as a path parameter")
+	}
+	source, err := store.GetSequenceById(sequenceId)
+	if err != nil {
+		return notFound("no sequence with id " + sequenceId + " was found")
+	}
+	if !source.Public && source.UserId != caller.UserId {
+		return notFound("no sequence with id " + sequenceId + " was found")
+	}
+
+	// leave out anything the caller couldn't use: meditations that have
+	// gone private or been deleted since the original was put together
+	meditations := []Meditation{}
+	unavailable := make(map[string]bool)
+	for _, m := range source.Meditations {
+		if m.Unavailable || (!m.Public && m.UserId != caller.UserId) {
+			unavailable[m.ID] = true
+			continue
+		}
+		meditations = append(meditations, m)
+	}
+	steps := []SequenceStep{}
+	for _, step := range source.Steps {
+		if !unavailable[step.MeditationID] {
+			step.Duration = 0
+			steps = append(steps, step)
+		}
+	}
+
+	now := time.Now()
+	fork := Sequence{
+		ID:          ksuid.New().String(),
+		UserId:      caller.UserId,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ImageURL:    source.ImageURL,
+		Name:        source.Name,
+		Description: source.Description,
+		Status:      StatusDraft,
+		Meditations: meditations,
+		Steps:       steps,
+	}
+
+	// save to DDB
+	err = store.ForkSequence(source.ID, fork)
+	if err != nil {
+		if errors.Is(err, ErrMeditationMissing) {
+			return conflict("one of the sequence's meditations was deleted; try again")
+		}
+		return internalServerError(err.Error())
+	}
+	fork.ForkedFrom = source.ID
+
+	// build the response
+	fork.Steps, fork.TotalDuration = timeSteps(steps, meditations)
+	responseBodyBytes, _ := json.Marshal(&fork)
+	resp := entityCreated(string(responseBodyBytes))
+
+	return withETag(resp, fork.Version)
+}
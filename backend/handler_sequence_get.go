@@ -8,7 +8,7 @@ import (
 
 func GetSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get user id
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -24,7 +24,7 @@ func GetSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMed
 	if err != nil {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
-	if sequence.UserId != userId {
+	if !caller.canEdit(sequence.UserId) {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
 
@@ -32,5 +32,5 @@ func GetSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMed
 	responseBodyBytes, _ := json.Marshal(&sequence)
 	resp := string(responseBodyBytes)
 
-	return successful(resp)
+	return withETag(successful(resp), sequence.Version)
 }
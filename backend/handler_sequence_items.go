@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// getOwnedSequence loads the sequence named in the path, checking that the
+// caller may edit it and that the If-Match header is current.
+func getOwnedSequence(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) (Sequence, *events.APIGatewayV2HTTPResponse) {
+	// get user id
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return Sequence{}, userIdNotFoundError()
+	}
+
+	// get the sequence
+	sequenceId, ok := req.PathParameters["sequenceId"]
+	if !ok {
+		return Sequence{}, internalServerError("sequenceId not found as path parameter")
+	}
+	sequence, err := store.GetSequenceById(sequenceId)
+	if err != nil {
+		return Sequence{}, notFound("no sequence with id " + sequenceId + " was found")
+	}
+	if !caller.canEdit(sequence.UserId) {
+		return Sequence{}, notFound("no sequence with id " + sequenceId + " was found")
+	}
+	if resp := checkIfMatch(req, sequence.Version); resp != nil {
+		return Sequence{}, resp
+	}
+	return sequence, nil
+}
+
+// sequenceItemsResponse maps the result of an item operation to a response,
+// returning the updated sequence on success.
+func sequenceItemsResponse(sequenceId string, err error, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	if err != nil {
+		if errors.Is(err, ErrPositionOutOfRange) || errors.Is(err, ErrInvalidStep) || errors.Is(err, ErrMeditationMissing) {
+			return badRequest(err.Error())
+		}
+		if errors.Is(err, ErrVersionMismatch) {
+			return preconditionFailed("the sequence has been modified; fetch it again and retry")
+		}
+		return internalServerError(err.Error())
+	}
+
+	sequence, err := store.GetSequenceById(sequenceId)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	responseBodyBytes, _ := json.Marshal(&sequence)
+	resp := successful(string(responseBodyBytes))
+
+	return withETag(resp, sequence.Version)
+}
+
+// InsertSequenceItemHandler handles POST /sequences/{sequenceId}/items
+func InsertSequenceItemHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnedSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+
+	// parse and validate the request body
+	input := InsertSequenceItemInput{}
+	err := json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	step := SequenceStep{Type: StepMeditation, MeditationID: input.MeditationID}
+	if input.Step != nil {
+		step = *input.Step
+	}
+	position := len(sequence.Steps)
+	if input.Position != nil {
+		position = *input.Position
+	}
+
+	// validate the meditation exists and is the user's own or public
+	if step.MeditationID != "" {
+		meditation, err := store.GetMeditation(step.MeditationID)
+		if err != nil || meditation.ID == "" {
+			return badRequest("no meditation with id " + step.MeditationID + " was found")
+		}
+		if !store.CanUseMeditations(sequence.UserId, []Meditation{meditation}) {
+			return badRequest("no meditation with id " + step.MeditationID + " was found")
+		}
+	}
+
+	caller, _ := callerPrincipal(req)
+	err = store.InsertSequenceItem(sequence.ID, sequence.Version, !caller.skipsReview(), step, position)
+	return sequenceItemsResponse(sequence.ID, err, store)
+}
+
+// DeleteSequenceItemHandler handles DELETE /sequences/{sequenceId}/items/{position}
+func DeleteSequenceItemHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnedSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+
+	position, err := strconv.Atoi(req.PathParameters["position"])
+	if err != nil {
+		return badRequest(":position must be an integer")
+	}
+
+	caller, _ := callerPrincipal(req)
+	err = store.RemoveSequenceItem(sequence.ID, sequence.Version, !caller.skipsReview(), position)
+	return sequenceItemsResponse(sequence.ID, err, store)
+}
+
+// MoveSequenceItemHandler handles POST /sequences/{sequenceId}/items:move
+func MoveSequenceItemHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnedSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+
+	// parse and validate the request body
+	input := MoveSequenceItemInput{}
+	err := json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	caller, _ := callerPrincipal(req)
+	err = store.MoveSequenceItem(sequence.ID, sequence.Version, !caller.skipsReview(), *input.From, *input.To)
+	return sequenceItemsResponse(sequence.ID, err, store)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// PatchSequenceHandler applies a JSON Merge Patch or JSON Patch to the
+// updatable fields of a sequence. With JSON Patch a single meditation can be
+// moved within the sequence, e.g.
+// [{"op": "move", "from": "/meditationIds/3", "path": "/meditationIds/0"}].
+func PatchSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// get the sequence
+	sequenceId, ok := req.PathParameters["sequenceId"]
+	if !ok {
+		return internalServerError("sequenceId not found as path parameter")
+	}
+	sequence, err := store.GetSequenceById(sequenceId)
+	if err != nil {
+		return notFound("no sequence with id " + sequenceId + " was found")
+	}
+	if !caller.canEdit(sequence.UserId) {
+		return notFound("no sequence with id " + sequenceId + " was found")
+	}
+	if resp := checkIfMatch(req, sequence.Version); resp != nil {
+		return resp
+	}
+
+	// patch the fields a PUT would send
+	currentInput := UpdateSequenceInput{
+		Name:        sequence.Name,
+		Description: sequence.Description,
+		Public:      sequence.Public,
+		Status:      sequence.Status,
+		PublishAt:   sequence.PublishAt,
+		Tags:        sequence.Tags,
+		Categories:  sequence.Categories,
+	}
+	if isPlainSteps(sequence.Steps) {
+		currentInput.MeditationIDs = stepMeditationIDs(sequence.Steps)
+	} else {
+		currentInput.Steps = make([]SequenceStep, len(sequence.Steps))
+		for i, step := range sequence.Steps {
+			step.Duration = 0
+			currentInput.Steps[i] = step
+		}
+	}
+	current, _ := json.Marshal(currentInput)
+	contentType, _ := getHeader(req, "Content-Type")
+	patched, err := applyPatch(contentType, current, []byte(req.Body))
+	if errors.Is(err, ErrUnsupportedPatchType) {
+		return unsupportedMediaType(err.Error())
+	}
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	input := UpdateSequenceInput{}
+	err = strictUnmarshal(patched, &input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	input.Status, input.PublishAt = patchedPublication(sequence.publication(), input.Status, input.PublishAt)
+
+	return applySequenceUpdate(caller, sequence, input, store)
+}
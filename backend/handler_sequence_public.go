@@ -6,9 +6,17 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
+// ListPublicSequencesHandler lists every public sequence, or with `tag` or
+// `category` only the ones filed under it.
 func ListPublicSequencesHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get the sequences
-	sequences, err := store.ListPublicSequences()
+	var sequences []Sequence
+	var err error
+	if indexKey, ok := taxonomyFilter(req); ok {
+		sequences, err = store.ListPublicSequencesByIndex(indexKey)
+	} else {
+		sequences, err = store.ListPublicSequences()
+	}
 	if err != nil {
 		return internalServerError(err.Error())
 	}
@@ -39,5 +47,5 @@ func GetPublicSequenceByIdHandler(req events.APIGatewayV2HTTPRequest, store *Dyn
 	responseBodyBytes, _ := json.Marshal(&sequence)
 	resp := string(responseBodyBytes)
 
-	return successful(resp)
+	return withETag(successful(resp), sequence.Version)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// getOwnSequence is getOwnedSequence for reads, which need no If-Match.
+func getOwnSequence(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) (Sequence, *events.APIGatewayV2HTTPResponse) {
+	// get user id
+	caller, ok := callerPrincipal(req)
+	if !ok {
+		return Sequence{}, userIdNotFoundError()
+	}
+
+	// get the sequence
+	sequenceId, ok := req.PathParameters["sequenceId"]
+	if !ok {
+		return Sequence{}, internalServerError("sequenceId not found as path parameter")
+	}
+	sequence, err := store.GetSequenceById(sequenceId)
+	if err != nil || !caller.canEdit(sequence.UserId) {
+		return Sequence{}, notFound("no sequence with id " + sequenceId + " was found")
+	}
+	return sequence, nil
+}
+
+// ListSequenceRevisionsHandler handles GET /sequences/{sequenceId}/revisions,
+// listing the sequence's earlier versions newest first, each with the fields
+// the next edit changed.
+func ListSequenceRevisionsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	revisions, err := store.ListSequenceRevisions(sequence.ID)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	next := sequence
+	for i := range revisions {
+		snapshot := *revisions[i].Sequence
+		revisions[i].Changed = changedFields(sequenceChanges(snapshot, next))
+		revisions[i].Sequence = nil
+		next = snapshot
+	}
+
+	responseBodyBytes, _ := json.Marshal(revisions)
+	return withETag(successful(string(responseBodyBytes)), sequence.Version)
+}
+
+// GetSequenceRevisionHandler handles GET
+// /sequences/{sequenceId}/revisions/{version}, returning the sequence as it
+// was and how it has changed since.
+func GetSequenceRevisionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	version, errResp := revisionVersion(req)
+	if errResp != nil {
+		return errResp
+	}
+	revision, err := store.GetSequenceRevision(sequence.ID, version)
+	if errors.Is(err, ErrRevisionNotFound) {
+		return notFound("no revision " + strconv.FormatInt(version, 10) + " of sequence " + sequence.ID + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	revision.Changes = sequenceChanges(*revision.Sequence, sequence)
+
+	responseBodyBytes, _ := json.Marshal(&revision)
+	return withETag(successful(string(responseBodyBytes)), sequence.Version)
+}
+
+// RestoreSequenceRevisionHandler handles POST
+// /sequences/{sequenceId}/revisions/{version}/restore. The revision's content
+// and steps are saved as a new version, provided its meditations can still be
+// used. Its publication and moderation are left as they are now.
+func RestoreSequenceRevisionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	sequence, errResp := getOwnedSequence(req, store)
+	if errResp != nil {
+		return errResp
+	}
+	version, errResp := revisionVersion(req)
+	if errResp != nil {
+		return errResp
+	}
+	revision, err := store.GetSequenceRevision(sequence.ID, version)
+	if errors.Is(err, ErrRevisionNotFound) {
+		return notFound("no revision " + strconv.FormatInt(version, 10) + " of sequence " + sequence.ID + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// the revision's meditations the sequence doesn't have now must still
+	// exist and be the user's own or public
+	old := revision.Sequence
+	meditationIds := stepMeditationIDs(old.Steps)
+	meditations, err := store.GetMeditationsByIds(meditationIds)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	if !store.CanUseMeditations(sequence.UserId, addedMeditations(meditationIds, meditations, sequence)) {
+		return conflict("some of the revision's meditations have since been deleted or made private")
+	}
+	meditations = redactUnavailable(meditationIds, meditations, sequence.UserId)
+
+	before := sequence
+	sequence.UpdatedAt = time.Now()
+	sequence.ImageURL = old.ImageURL
+	sequence.Name = old.Name
+	sequence.Description = old.Description
+	sequence.Tags = old.Tags
+	sequence.Categories = old.Categories
+	sequence.Meditations = meditations
+	sequence.Steps = old.Steps
+	caller, _ := callerPrincipal(req)
+	sequence.reviewEdits(caller, before)
+	err = store.UpdateSequence(sequence)
+	if errors.Is(err, ErrMeditationMissing) {
+		return conflict("some of the revision's meditations have since been deleted or made private")
+	}
+	return sequenceItemsResponse(sequence.ID, err, store)
+}
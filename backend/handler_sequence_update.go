@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 
 func UpdateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
 	// get user id
-	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	caller, ok := callerPrincipal(req)
 	if !ok {
 		return userIdNotFoundError()
 	}
@@ -24,9 +25,12 @@ func UpdateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 	if err != nil {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
-	if sequence.UserId != userId {
+	if !caller.canEdit(sequence.UserId) {
 		return notFound("no sequence with id " + sequenceId + " was found")
 	}
+	if resp := checkIfMatch(req, sequence.Version); resp != nil {
+		return resp
+	}
 
 	// parse the request body
 	input := UpdateSequenceInput{}
@@ -45,26 +49,48 @@ func UpdateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 		}
 	}
 
+	return applySequenceUpdate(caller, sequence, input, store)
+}
+
+// applySequenceUpdate validates `input` and saves it over `sequence` for
+// `caller`. It's shared by PUT and PATCH.
+func applySequenceUpdate(caller Principal, sequence Sequence, input UpdateSequenceInput, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	userId := sequence.UserId
+	sequenceId := sequence.ID
+	before := sequence
+
 	// validate the request body
-	err = validate.Struct(input)
+	err := validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	steps, meditationIDs, errResp := inputSteps(input.Steps, input.MeditationIDs)
+	if errResp != nil {
+		return errResp
+	}
+	tags, categories, err := normalizeTaxonomy(input.Tags, input.Categories)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+	now := time.Now()
+	publication, err := nextPublication(sequence.publication(), input.Status, input.PublishAt, input.Public, now)
 	if err != nil {
 		return badRequest(err.Error())
 	}
 
-	// validate the meditations exist and belong to this user
-	meditations, err := store.GetMeditationsByIds(input.MeditationIDs)
+	// validate the meditations it adds exist and are the user's own or
+	// public; those it has already can stay even if they no longer are
+	meditations, err := store.GetMeditationsByIds(meditationIDs)
 	if err != nil {
 		return badRequest("one or more of the meditationIDs does not exist")
 	}
-	for _, m := range meditations {
-		if m.UserId != userId {
-			return badRequest("one or more of the meditationIDs does not exist")
-		}
+	if !store.CanUseMeditations(userId, addedMeditations(meditationIDs, meditations, before)) {
+		return badRequest("one or more of the meditationIDs does not exist")
 	}
+	meditations = redactUnavailable(meditationIDs, meditations, userId)
 
 	// if an uploadKey is passed, ensure the key is in S3 an
 	// move the image to `public/`
-	now := time.Now()
 	if input.UploadKey != "" {
 		fileExt, err := ValidateImage(input.UploadKey, awsConfig)
 		if err != nil {
@@ -85,19 +111,47 @@ func UpdateSequenceHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMedi
 	// update our values
 	sequence.Name = input.Name
 	sequence.Description = input.Description
-	sequence.Public = input.Public
+	sequence.publishAs(caller, publication, now)
+	sequence.Tags = tags
+	sequence.Categories = categories
 	sequence.UpdatedAt = now
 	sequence.Meditations = meditations
+	sequence.Steps = steps
+	sequence.reviewEdits(caller, before)
 
 	// save to DDB
 	err = store.UpdateSequence(sequence)
 	if err != nil {
+		if errors.Is(err, ErrMeditationMissing) {
+			return badRequest(err.Error())
+		}
+		if errors.Is(err, ErrVersionMismatch) {
+			return preconditionFailed("the sequence has been modified; fetch it again and retry")
+		}
 		return internalServerError(err.Error())
 	}
+	sequence.Version++
+	sequence.Steps, sequence.TotalDuration = timeSteps(steps, meditations)
 
 	// build the response
 	responseBodyBytes, _ := json.Marshal(&sequence)
 	resp := successful(string(responseBodyBytes))
 
-	return resp
+	return withETag(resp, sequence.Version)
+}
+
+// inputSteps returns the steps of a sequence being saved, either as sent or
+// built from the plain list of meditationIds, along with the meditations
+// they reference.
+func inputSteps(steps []SequenceStep, meditationIDs []string) ([]SequenceStep, []string, *events.APIGatewayV2HTTPResponse) {
+	if len(steps) == 0 {
+		steps = stepsFromMeditationIDs(meditationIDs)
+	} else if len(meditationIDs) > 0 {
+		return nil, nil, badRequest("send either meditationIds or steps, not both")
+	}
+	err := validateSteps(steps)
+	if err != nil {
+		return nil, nil, badRequest(err.Error())
+	}
+	return steps, stepMeditationIDs(steps), nil
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func CreateSessionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// parse and validate the request body
+	input := CreateSessionInput{}
+	err := json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	// the sequence or meditation sat must be the user's own or public
+	if input.SequenceID != "" {
+		record, err := store.getSequenceRecord(input.SequenceID)
+		if err != nil || (record.Sequence.Sequence.UserId != userId && !record.Sequence.Sequence.Public) {
+			return badRequest("no sequence with id " + input.SequenceID + " was found")
+		}
+	} else {
+		meditation, err := store.GetMeditation(input.MeditationID)
+		if err != nil || meditation.ID == "" || (meditation.UserId != userId && !meditation.Public) {
+			return badRequest("no meditation with id " + input.MeditationID + " was found")
+		}
+	}
+
+	id, err := newSessionId(input.StartedAt)
+	if err != nil {
+		return badRequest("startedAt is out of range")
+	}
+	now := time.Now()
+	session := PracticeSession{
+		ID:           id,
+		UserId:       userId,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		SequenceID:   input.SequenceID,
+		MeditationID: input.MeditationID,
+		StartedAt:    input.StartedAt,
+		Minutes:      input.Minutes,
+		Status:       input.Status,
+		Note:         input.Note,
+	}
+
+	// save to DDB
+	err = store.SaveSession(session)
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&session)
+	return entityCreated(string(responseBodyBytes))
+}
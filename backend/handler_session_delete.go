@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func DeleteSessionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	sessionId, ok := req.PathParameters["sessionId"]
+	if !ok {
+		return badRequest("no :sessionId found as a path parameter")
+	}
+
+	// delete the session
+	err := store.DeleteSession(userId, sessionId)
+	if errors.Is(err, ErrSessionNotFound) {
+		return notFound("no session with id " + sessionId + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	return &events.APIGatewayV2HTTPResponse{
+		StatusCode:      204,
+		IsBase64Encoded: false,
+		Body:            string(""),
+		Headers:         map[string]string{},
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func GetSessionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// get the session; it can only be found in the caller's own partition
+	sessionId, ok := req.PathParameters["sessionId"]
+	if !ok {
+		return badRequest("no :sessionId found as a path parameter")
+	}
+	session, err := store.GetSession(userId, sessionId)
+	if err != nil {
+		return notFound("no session with id " + sessionId + " was found")
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&session)
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListSessionsHandler lists the caller's practice sessions, newest first.
+// `from` and `to` (RFC 3339) narrow it to a date range, and `journal=true`
+// keeps only the sessions with a journal note.
+func ListSessionsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// parse the date range
+	from := ksuidMinTime
+	to := ksuidMaxTime
+	if v, ok := req.QueryStringParameters["from"]; ok {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return badRequest("from must be an RFC 3339 timestamp")
+		}
+		from = t
+	}
+	if v, ok := req.QueryStringParameters["to"]; ok {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return badRequest("to must be an RFC 3339 timestamp")
+		}
+		to = t
+	}
+
+	sessions, err := store.ListSessions(userId, from, to)
+	if err != nil {
+		return internalServerError("Problem listing sessions for userId " + userId)
+	}
+	if req.QueryStringParameters["journal"] == "true" {
+		withNotes := []PracticeSession{}
+		for _, s := range sessions {
+			if s.Note != "" {
+				withNotes = append(withNotes, s)
+			}
+		}
+		sessions = withNotes
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(sessions)
+	return successful(string(responseBodyBytes))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// UpdateSessionHandler corrects a session's minutes and status or edits its
+// journal note.
+func UpdateSessionHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	// get the session
+	sessionId, ok := req.PathParameters["sessionId"]
+	if !ok {
+		return badRequest("no :sessionId found as a path parameter")
+	}
+	session, err := store.GetSession(userId, sessionId)
+	if err != nil {
+		return notFound("no session with id " + sessionId + " was found")
+	}
+
+	// parse and validate the request body
+	input := UpdateSessionInput{}
+	err = json.Unmarshal([]byte(req.Body), &input)
+	if err != nil {
+		return badRequest("Invalid request " + err.Error())
+	}
+	err = validate.Struct(input)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	session.Minutes = input.Minutes
+	session.Status = input.Status
+	session.Note = input.Note
+	session.UpdatedAt = time.Now()
+
+	// save to DDB
+	err = store.UpdateSession(session)
+	if errors.Is(err, ErrSessionNotFound) {
+		return notFound("no session with id " + sessionId + " was found")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&session)
+	return successful(string(responseBodyBytes))
+}
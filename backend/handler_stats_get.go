@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+	_ "time/tzdata" // the lambda runtime has no zoneinfo
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GetStatsHandler handles GET /me/stats. The `tz` query parameter is an IANA
+// time zone (e.g. America/Chicago) used to decide what counts as a day;
+// it defaults to UTC.
+func GetStatsHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	loc := time.UTC
+	if tz, ok := req.QueryStringParameters["tz"]; ok {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return badRequest("unknown time zone " + tz)
+		}
+		loc = l
+	}
+
+	// streaks need the whole history, not just the last year
+	sessions, err := store.ListSessions(userId, ksuidMinTime, ksuidMaxTime)
+	if err != nil {
+		return internalServerError("Problem listing sessions for userId " + userId)
+	}
+	stats := computeStats(sessions, loc, time.Now())
+
+	// name the sequences the user can still see
+	for i, u := range stats.TopSequences {
+		record, err := store.getSequenceRecord(u.SequenceID)
+		if err != nil {
+			continue
+		}
+		s := record.toSequence()
+		if s.UserId == userId || s.Public {
+			stats.TopSequences[i].Name = s.Name
+		}
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&stats)
+	return successful(string(responseBodyBytes))
+}
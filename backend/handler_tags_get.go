@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// taxonomyFilter reads the `tag`, `category`, `author` or `work` query
+// parameter of a public listing as an index partition key.
+func taxonomyFilter(req events.APIGatewayV2HTTPRequest) (string, bool) {
+	if tag, ok := req.QueryStringParameters["tag"]; ok {
+		return "tag#" + normalizeTag(tag), true
+	}
+	if author, ok := req.QueryStringParameters["author"]; ok {
+		return "author#" + normalizeTag(author), true
+	}
+	if work, ok := req.QueryStringParameters["work"]; ok {
+		// "Phil" finds Philippians
+		if book, ok := scriptureBookAliases[compactBookName(work)]; ok {
+			work = book
+		}
+		return "work#" + normalizeTag(work), true
+	}
+	if category, ok := req.QueryStringParameters["category"]; ok {
+		_, categories, err := normalizeTaxonomy([]string{}, []string{category})
+		if err == nil {
+			return "cat#" + categories[0], true
+		}
+		// nothing is filed under a category that doesn't exist
+		return "cat#", true
+	}
+	return "", false
+}
+
+// GetTagCloudHandler handles GET /public/tags, the tags in use on public
+// meditations and sequences with their counts, plus the curated categories.
+func GetTagCloudHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	cloud, err := store.GetTagCloud()
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&cloud)
+	return successful(string(responseBodyBytes))
+}
@@ -140,6 +140,9 @@ func buildUpdateSequenceRequest(userId string, sequenceId string, input UpdateSe
 		PathParameters: map[string]string{
 			"sequenceId": sequenceId,
 		},
+		Headers: map[string]string{
+			"if-match": etag(0),
+		},
 		Body: string(jsonBytes),
 	}
 }
@@ -161,6 +164,12 @@ func buildGetOrDeleteRequest(userId string, meditationId string) events.APIGatew
 	}
 }
 
+// asAdmin makes `req` an admin's.
+func asAdmin(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPRequest {
+	req.RequestContext.Authorizer.JWT.Claims[claimName("ROLES_CLAIM", ROLES_CLAIM)] = RoleAdmin
+	return req
+}
+
 func buildUpdateRequest(userId string, meditationId string, input UpdateMeditationInput) events.APIGatewayV2HTTPRequest {
 	jsonBytes, _ := json.Marshal(input)
 
@@ -178,6 +187,9 @@ func buildUpdateRequest(userId string, meditationId string, input UpdateMeditati
 		PathParameters: map[string]string{
 			"meditationId": meditationId,
 		},
+		Headers: map[string]string{
+			"if-match": etag(0),
+		},
 	}
 }
 func initMeditationsForSequenceTesting() *DynamoMeditationStore {
@@ -271,8 +283,11 @@ func TestHandlers(t *testing.T) {
 			t.Errorf("%+v\n", updateResp)
 		}
 
-		// now delete
+		// now delete, using the ETag of the updated meditation
 		deleteRequest := getReq
+		deleteRequest.Headers = map[string]string{
+			"if-match": updateResp.Headers["ETag"],
+		}
 		deleteResponse := DeleteMeditationHandler(deleteRequest, &store)
 		if deleteResponse.StatusCode != 204 {
 			t.Errorf("Expected status code 204, got %d\n", deleteResponse.StatusCode)
@@ -361,6 +376,60 @@ func TestHandlers(t *testing.T) {
 
 	})
 
+	t.Run("UPDATE/DELETE: If-Match preconditions", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		createLocalDynamoTable(tableName)
+		store := NewDynamoMeditationStore(tableName, awsConfig)
+		userId := "alex"
+
+		input := CreateMeditationInput{
+			UploadKey: mp3key,
+			Name:      "Test Meditaiton",
+			Text:      "Arma virumque cano Troiae qui primus ab oris\nItaliam fato profugus...",
+			Public:    false,
+		}
+		createResp := CreateMeditationHandler(buildCreateMeditationRequest(userId, input), &store)
+		createdMeditation := Meditation{}
+		json.Unmarshal([]byte(createResp.Body), &createdMeditation)
+		id := createdMeditation.ID
+
+		updateInput := UpdateMeditationInput{
+			Name: "Test Meditation (changed)",
+			Text: "Test Text (changed)",
+		}
+
+		// no If-Match at all
+		updateRequest := buildUpdateRequest(userId, id, updateInput)
+		updateRequest.Headers = map[string]string{}
+		updateResp := UpdateMeditationHandler(updateRequest, &store)
+		if updateResp.StatusCode != 428 {
+			t.Errorf("Expected status code 428, got %d\n", updateResp.StatusCode)
+		}
+
+		// the first update moves the meditation on a version...
+		updateResp = UpdateMeditationHandler(buildUpdateRequest(userId, id, updateInput), &store)
+		if updateResp.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d\n", updateResp.StatusCode)
+		}
+		if updateResp.Headers["ETag"] != etag(1) {
+			t.Errorf("Expected ETag %s, got %s", etag(1), updateResp.Headers["ETag"])
+		}
+
+		// ...so a second edit of the same stale copy is rejected
+		updateResp = UpdateMeditationHandler(buildUpdateRequest(userId, id, updateInput), &store)
+		if updateResp.StatusCode != 412 {
+			t.Errorf("Expected status code 412, got %d\n", updateResp.StatusCode)
+		}
+		deleteRequest := buildGetOrDeleteRequest(userId, id)
+		deleteRequest.Headers = map[string]string{
+			"if-match": etag(0),
+		}
+		deleteResp := DeleteMeditationHandler(deleteRequest, &store)
+		if deleteResp.StatusCode != 412 {
+			t.Errorf("Expected status code 412, got %d\n", deleteResp.StatusCode)
+		}
+	})
+
 	t.Run("GET: 404 scenarios", func(t *testing.T) {
 		// create a database table for each test
 		tableName := uuid.NewV4().String()
@@ -401,6 +470,17 @@ func TestHandlers(t *testing.T) {
 			t.Errorf("Expected status code 404, got %d\n", getRespNonExistent.StatusCode)
 			t.Errorf("%+v\n", getRespNonExistent)
 		}
+
+		// admins can see anyone's, but still not one that doesn't exist
+		adminResp := GetMeditationHandler(asAdmin(buildGetOrDeleteRequest("maximus", id)), &store)
+		if adminResp.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d\n", adminResp.StatusCode)
+		}
+		adminResp = GetMeditationHandler(asAdmin(buildGetOrDeleteRequest("maximus", "NON_EXISTENT_ID")), &store)
+		if adminResp.StatusCode != 404 {
+			t.Errorf("Expected status code 404, got %d\n", adminResp.StatusCode)
+			t.Errorf("%+v\n", adminResp)
+		}
 	})
 
 	t.Run("UPDATE/DELETE: 404 scenarios", func(t *testing.T) {
@@ -475,6 +555,80 @@ func TestHandlers(t *testing.T) {
 			t.Errorf("Expected status code 404, got %d\n", updateResponseNonExistent.StatusCode)
 			t.Errorf("%+v\n", updateResponseNonExistent)
 		}
+
+		// nor will an admin
+		adminDelete := DeleteMeditationHandler(asAdmin(buildGetOrDeleteRequest("maximus", "THIS_ID_DOES_NOT_EXIST")), &store)
+		if adminDelete.StatusCode != 404 {
+			t.Errorf("Expected status code 404, got %d\n", adminDelete.StatusCode)
+			t.Errorf("%+v\n", adminDelete)
+		}
+		adminUpdate := UpdateMeditationHandler(asAdmin(buildUpdateRequest("maximus", "NON_EXISTENT_ID", updateInput)), &store)
+		if adminUpdate.StatusCode != 404 {
+			t.Errorf("Expected status code 404, got %d\n", adminUpdate.StatusCode)
+			t.Errorf("%+v\n", adminUpdate)
+		}
+	})
+
+	t.Run("DELETE: an admin's take down survives a restore", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		userId := "alex"
+		m := createMeditations(1, userId, store)[0]
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: time.Now()})
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		deleteReq := asAdmin(buildGetOrDeleteRequest("maximus", m.ID))
+		deleteResp := DeleteMeditationHandler(deleteReq, store)
+		if deleteResp.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d: %s", deleteResp.StatusCode, deleteResp.Body)
+		}
+
+		restoreReq := buildGetOrDeleteRequest(userId, m.ID)
+		restoreReq.PathParameters = map[string]string{"itemId": m.ID}
+		restoreResp := RestoreTrashHandler(restoreReq, store)
+		if restoreResp.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d: %s", restoreResp.StatusCode, restoreResp.Body)
+		}
+		restored, _ := store.GetMeditation(m.ID)
+		if restored.Public || restored.Moderation != ModerationRejected {
+			t.Errorf("Expected the restored meditation to stay down, got %+v", restored)
+		}
+		public, _ := store.ListPublicMeditations()
+		for _, p := range public {
+			if p.ID == m.ID {
+				t.Error("Expected the restored meditation not to be listed publicly")
+			}
+		}
+	})
+
+	t.Run("Restoring a revision leaves the publication alone", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		userId := "alex"
+		m := createMeditations(1, userId, store)[0]
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: time.Now()})
+		err := store.UpdateMeditation(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// revision 0 is the draft it was before
+		req := buildGetOrDeleteRequest(userId, m.ID)
+		req.PathParameters["version"] = "0"
+		req.Headers = map[string]string{"if-match": etag(1)}
+		resp := RestoreMeditationRevisionHandler(req, store)
+		if resp.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		restored, _ := store.GetMeditation(m.ID)
+		if !restored.Public || restored.Status != StatusPublished || restored.Version != 2 {
+			t.Errorf("Expected it still public, got %+v", restored)
+		}
 	})
 
 	t.Run("Sequence Create:", func(t *testing.T) {
@@ -549,6 +703,9 @@ func TestHandlers(t *testing.T) {
 		}
 
 		req := buildGetOrDeleteSequenceRequest(userId, seq.ID)
+		req.Headers = map[string]string{
+			"if-match": etag(0),
+		}
 		resp := DeleteSequenceByIdHandler(req, store)
 		if resp.StatusCode != 204 {
 			t.Errorf("expected status code 204 but got %d", resp.StatusCode)
@@ -634,6 +791,119 @@ func TestHandlers(t *testing.T) {
 			t.Errorf("%+v", resp)
 		}
 	})
+	t.Run("Sequence Patch:", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		userId := "testUser"
+		meditations := createMeditations(4, userId, store)
+
+		seq := Sequence{
+			ID:          "1",
+			Name:        "Test Sequence",
+			Description: "Description of a sequence",
+			UserId:      userId,
+			Meditations: meditations,
+		}
+		err := store.SaveSequence(seq)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		req := buildGetOrDeleteSequenceRequest(userId, seq.ID)
+		req.Headers = map[string]string{
+			"if-match":     etag(0),
+			"content-type": "application/json-patch+json",
+		}
+		req.Body = `[{"op":"move","from":"/meditationIds/3","path":"/meditationIds/0"},{"op":"replace","path":"/isPublic","value":true}]`
+		resp := PatchSequenceHandler(req, store)
+		if resp.StatusCode != 200 {
+			t.Errorf("expected status code 200 but got %d", resp.StatusCode)
+			t.Errorf("%+v", resp)
+			return
+		}
+
+		sequence, err := store.GetSequenceById(seq.ID)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		// published, it waits for a moderator before going public
+		if sequence.Status != StatusPublished || sequence.Moderation != ModerationPending || sequence.Public || sequence.Name != seq.Name {
+			t.Errorf("expected only isPublic to change, got %+v", sequence)
+		}
+		if sequence.Meditations[0].ID != meditations[3].ID || sequence.Meditations[1].ID != meditations[0].ID {
+			t.Errorf("expected %s to be moved to the front", meditations[3].ID)
+		}
+
+		// a merge patch with the wrong content type is refused
+		req.Headers = map[string]string{
+			"if-match":     etag(1),
+			"content-type": "application/json",
+		}
+		req.Body = `{"isPublic":false}`
+		resp = PatchSequenceHandler(req, store)
+		if resp.StatusCode != 415 {
+			t.Errorf("expected status code 415 but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Sequence Patch after a borrowed meditation goes private:", func(t *testing.T) {
+		tableName := uuid.NewV4().String()
+		store := initializeTestingStore(tableName)
+		userId := "testUser"
+		theirs := createMeditations(2, "evagrius", store)
+		for i := range theirs {
+			theirs[i].Public = true
+			err := store.UpdateMeditation(theirs[i])
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			theirs[i].Version++
+		}
+		seq := Sequence{
+			ID:          "1",
+			Name:        "Test Sequence",
+			Description: "Description of a sequence",
+			UserId:      userId,
+			Meditations: theirs,
+		}
+		err := store.SaveSequence(seq)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		theirs[0].Public = false
+		err = store.UpdateMeditation(theirs[0])
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// renaming it still works, and keeps the private one in its place
+		req := buildGetOrDeleteSequenceRequest(userId, seq.ID)
+		req.Headers = map[string]string{
+			"if-match":     etag(0),
+			"content-type": "application/json-patch+json",
+		}
+		req.Body = `[{"op":"replace","path":"/name","value":"Renamed"}]`
+		resp := PatchSequenceHandler(req, store)
+		if resp.StatusCode != 200 {
+			t.Errorf("expected status code 200 but got %d", resp.StatusCode)
+			t.Errorf("%+v", resp)
+			return
+		}
+		sequence, err := store.GetSequenceById(seq.ID)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if sequence.Name != "Renamed" || len(sequence.Meditations) != 2 || !sequence.Meditations[0].Unavailable || sequence.Meditations[0].ID != theirs[0].ID {
+			t.Errorf("expected only the name to change, got %+v", sequence)
+		}
+	})
+
 	t.Run("Sequence Update with duplicate meditation in sequence:", func(t *testing.T) {
 		tableName := uuid.NewV4().String()
 		store := initializeTestingStore(tableName)
@@ -686,3 +956,32 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 }
+
+func TestIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version int64
+		matches bool
+	}{
+		{`"3"`, 3, true},
+		{`"3"`, 4, false},
+		{`W/"3"`, 3, true},
+		{`"1", "2", "3"`, 2, true},
+		{`*`, 7, true},
+		{`3`, 3, false},
+	}
+	for _, c := range cases {
+		if ifMatches(c.header, c.version) != c.matches {
+			t.Errorf("ifMatches(%s, %d): expected %v", c.header, c.version, c.matches)
+		}
+	}
+
+	req := events.APIGatewayV2HTTPRequest{}
+	if resp := checkIfMatch(req, 0); resp == nil || resp.StatusCode != 428 {
+		t.Errorf("Expected a 428 when If-Match is missing, got %+v", resp)
+	}
+	req.Headers = map[string]string{"If-Match": etag(0)}
+	if resp := checkIfMatch(req, 0); resp != nil {
+		t.Errorf("Expected no error response, got %+v", resp)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ListTrashHandler handles GET /trash, listing the caller's deleted
+// meditations and sequences that can still be restored.
+func ListTrashHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	items, err := store.ListTrash(userId, time.Now())
+	if err != nil {
+		return internalServerError("Problem listing the trash for userId " + userId)
+	}
+
+	// build the response
+	responseBodyBytes, _ := json.Marshal(&items)
+	return successful(string(responseBodyBytes))
+}
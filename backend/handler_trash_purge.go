@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// PurgeTrashHandler reads the table's stream. When TTL deletes a trashed
+// meditation or sequence for good it removes the item's revisions and
+// reports and, for a sequence, its relation records, so its meditations can
+// be deleted in turn.
+func PurgeTrashHandler(event events.DynamoDBEvent) error {
+	store := NewDynamoMeditationStore(os.Getenv("DDB_TABLE"), awsConfig)
+	for _, record := range event.Records {
+		if !isExpiry(record) {
+			continue
+		}
+		pk := record.Change.Keys["pk"].String()
+		if record.Change.Keys["sk"].String() != pk {
+			continue
+		}
+		// failing the batch has Lambda retry it
+		if strings.HasPrefix(pk, "seq#") {
+			err := store.purgeSequenceReferences(strings.TrimPrefix(pk, "seq#"))
+			if err != nil {
+				return err
+			}
+		}
+		if strings.HasPrefix(pk, "seq#") || strings.HasPrefix(pk, "med#") {
+			err := store.purgeRevisions(pk)
+			if err != nil {
+				return err
+			}
+			err = store.ClearReports(pk)
+			if err != nil && !errors.Is(err, ErrNoReports) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isExpiry reports whether a stream record is TTL deleting an item, rather
+// than anyone else.
+func isExpiry(record events.DynamoDBEventRecord) bool {
+	return record.EventName == "REMOVE" &&
+		record.UserIdentity != nil &&
+		record.UserIdentity.Type == "Service" &&
+		record.UserIdentity.PrincipalID == "dynamodb.amazonaws.com"
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RestoreTrashHandler handles POST /trash/{itemId}/restore, taking a
+// meditation or sequence back out of the caller's trash. It responds with
+// the restored item, as the item's own GET would.
+func RestoreTrashHandler(req events.APIGatewayV2HTTPRequest, store *DynamoMeditationStore) *events.APIGatewayV2HTTPResponse {
+	// get user id
+	userId, ok := req.RequestContext.Authorizer.JWT.Claims["sub"]
+	if !ok {
+		return userIdNotFoundError()
+	}
+
+	itemId, ok := req.PathParameters["itemId"]
+	if !ok {
+		return badRequest("no :itemId found as a path parameter")
+	}
+
+	// meditations and sequences share the trash, so try both
+	now := time.Now()
+	meditation, err := store.RestoreMeditation(itemId, userId, now)
+	if err == nil {
+		responseBodyBytes, _ := json.Marshal(&meditation)
+		return withETag(successful(string(responseBodyBytes)), meditation.Version)
+	}
+	if !errors.Is(err, ErrNotInTrash) {
+		return internalServerError(err.Error())
+	}
+	sequence, err := store.RestoreSequence(itemId, userId, now)
+	if errors.Is(err, ErrNotInTrash) {
+		return notFound("No item with id " + itemId + " was found in the trash")
+	}
+	if err != nil {
+		return internalServerError(err.Error())
+	}
+	responseBodyBytes, _ := json.Marshal(&sequence)
+	return withETag(successful(string(responseBodyBytes)), sequence.Version)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/segmentio/ksuid"
+)
+
+// The legacy catalog (scripts/old_catalog.json) lists authors, their works
+// and each work's numbered sections, with the text and audio of each.
+
+type LegacyAuthor struct {
+	Name  string       `json:"name"`
+	Info  string       `json:"info"`
+	Works []LegacyWork `json:"works"`
+}
+
+type LegacyWork struct {
+	Name     string          `json:"name"`
+	Info     string          `json:"info"`
+	Sections []LegacySection `json:"sections"`
+}
+
+type LegacySection struct {
+	Number string `json:"number"`
+	Text   string `json:"text"`
+	URL    string `json:"url"`
+}
+
+// imported ids are ksuids with a fixed time and a payload hashed from the
+// catalog, so importing again finds the same items
+var importEpoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func importId(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	id, _ := ksuid.FromParts(importEpoch, sum[:16])
+	return id.String()
+}
+
+type importedSection struct {
+	Meditation Meditation
+	AudioURL   string // where the legacy audio lives
+}
+
+type importedWork struct {
+	Sequence Sequence
+	Sections []importedSection
+}
+
+// planImport turns the catalog into the meditations and sequences it
+// describes, a sequence per work, owned by `userId`.
+func planImport(catalog []LegacyAuthor, userId string, public bool) ([]importedWork, error) {
+	publication := withPublic(Publication{Status: StatusDraft}, public, time.Now())
+	works := []importedWork{}
+	for _, author := range catalog {
+		for _, work := range author.Works {
+			planned := importedWork{
+				Sequence: Sequence{
+					ID:          importId(author.Name, work.Name),
+					UserId:      userId,
+					Name:        work.Name,
+					Description: work.Info,
+				},
+				Sections: []importedSection{},
+			}
+			planned.Sequence.setPublication(publication)
+			if planned.Sequence.Description == "" {
+				planned.Sequence.Description = work.Name + ", " + author.Name
+			}
+			for _, section := range work.Sections {
+				citation, err := legacyCitation(author.Name, work.Name, section.Number)
+				if err != nil {
+					return nil, fmt.Errorf("%s, %s %s: %w", author.Name, work.Name, section.Number, err)
+				}
+				name := work.Name + " " + section.Number
+				if citation.Scripture != nil {
+					name = citation.Scripture.String()
+				}
+				m := Meditation{
+					ID:       importId(author.Name, work.Name, section.Number),
+					UserId:   userId,
+					Name:     name,
+					Text:     strings.TrimSpace(section.Text),
+					Citation: citation,
+				}
+				m.setPublication(publication)
+				planned.Sections = append(planned.Sections, importedSection{Meditation: m, AudioURL: section.URL})
+			}
+			works = append(works, planned)
+		}
+	}
+	return works, nil
+}
+
+// legacyCitation cites a section. Scripture works name a chapter
+// ("Philippians 4") and number their sections by verse.
+func legacyCitation(author string, work string, number string) (*Citation, error) {
+	if ref, ok := parseScriptureReference(work); ok && ref.Verse == 0 && ref.EndChapter == 0 {
+		return normalizeCitation(&Citation{Author: author, Section: work + ":" + number})
+	}
+	return normalizeCitation(&Citation{Author: author, Work: work, Section: number})
+}
+
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+	ImportFailed    = "failed"
+)
+
+type Importer struct {
+	store    *DynamoMeditationStore
+	mediaDir string // read audio from here by file name rather than downloading it
+	dryRun   bool
+	out      io.Writer
+	failures int
+}
+
+func (imp *Importer) report(result string, kind string, id string, name string, err error) {
+	if result == ImportFailed {
+		imp.failures++
+		fmt.Fprintf(imp.out, "%-9s %s %s %q: %s\n", result, kind, id, name, err.Error())
+		return
+	}
+	fmt.Fprintf(imp.out, "%-9s %s %s %q\n", result, kind, id, name)
+}
+
+// loadAudio reads a section's audio from the media directory, or downloads
+// it, returning it with its content type.
+func (imp *Importer) loadAudio(url string) (string, []byte, error) {
+	contentType := audioContentTypes[strings.ToLower(path.Ext(url))]
+	if imp.mediaDir != "" {
+		audio, err := ioutil.ReadFile(filepath.Join(imp.mediaDir, path.Base(url)))
+		return contentType, audio, err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.New("could not download " + url + ": " + resp.Status)
+	}
+	if t := resp.Header.Get("Content-Type"); strings.HasPrefix(t, "audio/") {
+		contentType = t
+	}
+	audio, err := ioutil.ReadAll(resp.Body)
+	return contentType, audio, err
+}
+
+// putFile writes `content` to the bucket at `key`.
+func putFile(key string, contentType string, content []byte) error {
+	sess, _ := session.NewSession(awsConfig)
+	svc := s3.New(sess)
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(os.Getenv("AUDIO_BUCKET")),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// importSection creates the meditation for a section, or brings an earlier
+// import of it up to date. Audio is only fetched for new meditations.
+func (imp *Importer) importSection(section importedSection) (string, error) {
+	planned := section.Meditation
+	existing, err := imp.store.GetMeditation(planned.ID)
+	if err != nil {
+		return ImportFailed, err
+	}
+	now := time.Now()
+
+	if existing.ID != "" {
+		if existing.Name == planned.Name && existing.Text == planned.Text && existing.publication().Status == planned.Status && reflect.DeepEqual(existing.Citation, planned.Citation) {
+			return ImportUnchanged, nil
+		}
+		before := existing
+		existing.Name = planned.Name
+		existing.Text = planned.Text
+		existing.Citation = planned.Citation
+		existing.setPublication(withPublic(existing.publication(), planned.Status == StatusPublished, now))
+		existing.reviewEdits(Principal{UserId: existing.UserId}, before)
+		existing.UpdatedAt = now
+		if imp.dryRun {
+			return ImportUpdated, nil
+		}
+		return ImportUpdated, imp.store.UpdateMeditation(existing)
+	}
+
+	contentType, audio, err := imp.loadAudio(section.AudioURL)
+	if err != nil {
+		return ImportFailed, err
+	}
+	fileExt, duration, err := validateAudioContent(contentType, audio)
+	if err != nil {
+		return ImportFailed, err
+	}
+	if imp.dryRun {
+		return ImportCreated, nil
+	}
+	suffix := planned.ID + fileExt
+	err = putFile("public/"+suffix, contentType, audio)
+	if err != nil {
+		return ImportFailed, err
+	}
+	planned.URL = mapPathSuffixToFullURL(suffix)
+	planned.Duration = duration
+	planned.CreatedAt = now
+	planned.UpdatedAt = now
+	return ImportCreated, imp.store.SaveMeditation(planned)
+}
+
+// importWork imports a work's sections, then its sequence of the sections
+// that made it in.
+func (imp *Importer) importWork(work importedWork) {
+	meditationIds := []string{}
+	for _, section := range work.Sections {
+		result, err := imp.importSection(section)
+		if err != nil {
+			result = ImportFailed
+		}
+		imp.report(result, "meditation", section.Meditation.ID, section.Meditation.Name, err)
+		if result != ImportFailed {
+			meditationIds = append(meditationIds, section.Meditation.ID)
+		}
+	}
+
+	result, err := imp.importSequence(work.Sequence, meditationIds)
+	if err != nil {
+		result = ImportFailed
+	}
+	imp.report(result, "sequence", work.Sequence.ID, work.Sequence.Name, err)
+}
+
+func (imp *Importer) importSequence(planned Sequence, meditationIds []string) (string, error) {
+	planned.Meditations = make([]Meditation, len(meditationIds))
+	for i, id := range meditationIds {
+		planned.Meditations[i] = Meditation{ID: id}
+	}
+	now := time.Now()
+
+	record, err := imp.store.getSequenceRecord(planned.ID)
+	if err == nil {
+		existing := record.toSequence()
+		if existing.Name == planned.Name && existing.Description == planned.Description && existing.publication().Status == planned.Status && reflect.DeepEqual(record.Sequence.MeditationIDs, meditationIds) {
+			return ImportUnchanged, nil
+		}
+		before := existing
+		existing.Name = planned.Name
+		existing.Description = planned.Description
+		existing.Meditations = planned.Meditations
+		existing.Steps = stepsFromMeditationIDs(meditationIds)
+		existing.setPublication(withPublic(existing.publication(), planned.Status == StatusPublished, now))
+		existing.reviewEdits(Principal{UserId: existing.UserId}, before)
+		existing.UpdatedAt = now
+		if imp.dryRun {
+			return ImportUpdated, nil
+		}
+		return ImportUpdated, imp.store.UpdateSequence(existing)
+	}
+
+	if len(meditationIds) == 0 {
+		return ImportFailed, errors.New("none of its meditations could be imported")
+	}
+	if imp.dryRun {
+		return ImportCreated, nil
+	}
+	planned.CreatedAt = now
+	planned.UpdatedAt = now
+	return ImportCreated, imp.store.SaveSequence(planned)
+}
+
+// runImport is the `import` command:
+//
+//	meditation import -catalog old_catalog.json -user <user id> [-media dir] [-public] [-dry-run]
+//
+// It reads the table from DDB_TABLE and the audio bucket from AUDIO_BUCKET
+// and PUBLIC_AUDIO_BASE, as the API does.
+func runImport(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(out)
+	catalogPath := flags.String("catalog", "", "the legacy catalog to import")
+	userId := flags.String("user", "", "the user id that will own the imported items")
+	mediaDir := flags.String("media", "", "read audio from this directory instead of downloading it")
+	public := flags.Bool("public", false, "make the imported items public")
+	dryRun := flags.Bool("dry-run", false, "report what would change without changing it")
+	local := flags.Bool("local", false, "use localstack")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if *catalogPath == "" || *userId == "" {
+		fmt.Fprintln(out, "-catalog and -user are required")
+		flags.Usage()
+		return 2
+	}
+
+	raw, err := ioutil.ReadFile(*catalogPath)
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+	catalog := []LegacyAuthor{}
+	err = json.Unmarshal(raw, &catalog)
+	if err != nil {
+		fmt.Fprintln(out, "could not read the catalog: "+err.Error())
+		return 1
+	}
+	works, err := planImport(catalog, *userId, *public)
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+
+	awsConfig = getAwsConfig(*local)
+	store := NewDynamoMeditationStore(os.Getenv("DDB_TABLE"), awsConfig)
+	imp := Importer{
+		store:    &store,
+		mediaDir: *mediaDir,
+		dryRun:   *dryRun,
+		out:      out,
+	}
+	for _, work := range works {
+		imp.importWork(work)
+	}
+	if imp.failures > 0 {
+		fmt.Fprintf(out, "%d items could not be imported\n", imp.failures)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/segmentio/ksuid"
+)
+
+// Importing an export archive (see ExportManifest) recreates its meditations
+// and sequences for the importing user under new ids, e.g. to move a library
+// between installs. Audio and images get the same checks as uploads.
+
+const (
+	MAX_IMPORT_ARCHIVE_SIZE = 200 << 20 // what the API will read into memory
+	MAX_IMPORT_FILE_SIZE    = 20 << 20
+)
+
+var ErrInvalidArchive = errors.New("invalid export archive")
+
+type ImportArchiveInput struct {
+	UploadKey string `json:"uploadKey" validate:"required,uploadKey"`
+	DryRun    bool   `json:"dryRun"` // check everything but create nothing
+}
+
+// ArchiveImportItem is what became of one item in the archive.
+type ArchiveImportItem struct {
+	Type     string `json:"type"`         // meditation or sequence
+	SourceID string `json:"sourceId"`     // its id in the archive
+	ID       string `json:"id,omitempty"` // its new id
+	Name     string `json:"name"`
+	Result   string `json:"result"` // created or failed
+	Error    string `json:"error,omitempty"`
+	// meditations left out of a sequence because they did not make it in
+	MissingMeditationIDs []string `json:"missingMeditationIds,omitempty"`
+}
+
+type ArchiveImportReport struct {
+	DryRun  bool                `json:"dryRun,omitempty"`
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Items   []ArchiveImportItem `json:"items"`
+}
+
+func (r *ArchiveImportReport) add(item ArchiveImportItem, err error) {
+	if err != nil {
+		item.Result = ImportFailed
+		item.Error = err.Error()
+		item.ID = ""
+		r.Failed++
+	} else {
+		item.Result = ImportCreated
+		r.Created++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// readArchiveManifest reads the manifest of an export archive and indexes its
+// files by name.
+func readArchiveManifest(archive *zip.Reader) (ExportManifest, map[string]*zip.File, error) {
+	manifest := ExportManifest{}
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	raw, err := readArchiveFile(files, EXPORT_MANIFEST_FILE)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+	}
+	err = json.Unmarshal(raw, &manifest)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w: %s", ErrInvalidArchive, err.Error())
+	}
+	if manifest.Format < 1 || manifest.Format > EXPORT_FORMAT {
+		return manifest, nil, fmt.Errorf("%w: unknown format %d", ErrInvalidArchive, manifest.Format)
+	}
+	return manifest, files, nil
+}
+
+func readArchiveFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, errors.New("the archive has no " + name)
+	}
+	if f.UncompressedSize64 > MAX_IMPORT_FILE_SIZE {
+		return nil, errors.New(name + " is too large")
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// the header's size can't be trusted
+	content, err := ioutil.ReadAll(io.LimitReader(r, MAX_IMPORT_FILE_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MAX_IMPORT_FILE_SIZE {
+		return nil, errors.New(name + " is too large")
+	}
+	return content, nil
+}
+
+// archiveFile is audio or an image read from the archive and checked.
+type archiveFile struct {
+	Ext         string
+	ContentType string
+	Content     []byte
+}
+
+// prepareArchiveMeditation checks an archived meditation as if it had just
+// been created, returning it (without an id) owned by `userId`, and its
+// audio.
+func prepareArchiveMeditation(exported ExportedMeditation, files map[string]*zip.File, userId string) (Meditation, archiveFile, error) {
+	err := validate.Struct(UpdateMeditationInput{Name: exported.Name, Text: exported.Text, Public: exported.Public})
+	if err != nil {
+		return Meditation{}, archiveFile{}, err
+	}
+	tags, categories, err := normalizeTaxonomy(exported.Tags, exported.Categories)
+	if err != nil {
+		return Meditation{}, archiveFile{}, err
+	}
+	citation, err := normalizeCitation(exported.Citation)
+	if err != nil {
+		return Meditation{}, archiveFile{}, err
+	}
+
+	if exported.AudioFile == "" {
+		return Meditation{}, archiveFile{}, errors.New("the meditation has no audio")
+	}
+	audio, err := readArchiveFile(files, exported.AudioFile)
+	if err != nil {
+		return Meditation{}, archiveFile{}, err
+	}
+	contentType := audioContentTypes[strings.ToLower(path.Ext(exported.AudioFile))]
+	fileExt, duration, err := validateAudioContent(contentType, audio)
+	if err != nil {
+		return Meditation{}, archiveFile{}, errors.New(exported.AudioFile + ": " + err.Error())
+	}
+
+	m := Meditation{
+		UserId:     userId,
+		Duration:   duration,
+		Name:       exported.Name,
+		Text:       exported.Text,
+		AuthorName: exported.AuthorName,
+		Tags:       tags,
+		Categories: categories,
+		Citation:   citation,
+	}
+	// public ones are published again, pending moderation
+	m.setPublication(withPublic(Publication{Status: StatusDraft}, exported.Public, time.Now()))
+	return m, archiveFile{Ext: fileExt, ContentType: contentType, Content: audio}, nil
+}
+
+// prepareArchiveSequence checks an archived sequence as if it had just been
+// created, returning it (without an id) owned by `userId`, and its image.
+// Its steps are pointed at the new ids in `imported`; a step whose meditation
+// is neither imported nor `usable` as it is is left out, and listed in
+// `missing`.
+func prepareArchiveSequence(exported ExportedSequence, files map[string]*zip.File, userId string, imported map[string]string, usable map[string]bool) (Sequence, archiveFile, []string, error) {
+	err := validate.Struct(UpdateSequenceInput{Name: exported.Name, Description: exported.Description, Public: exported.Public})
+	if err != nil {
+		return Sequence{}, archiveFile{}, nil, err
+	}
+	tags, categories, err := normalizeTaxonomy(exported.Tags, exported.Categories)
+	if err != nil {
+		return Sequence{}, archiveFile{}, nil, err
+	}
+
+	steps := []SequenceStep{}
+	missing := []string{}
+	for _, step := range exported.Steps {
+		step.Duration = 0
+		if step.MeditationID != "" {
+			if id, ok := imported[step.MeditationID]; ok {
+				step.MeditationID = id
+			} else if !usable[step.MeditationID] {
+				missing = append(missing, step.MeditationID)
+				continue
+			}
+		}
+		steps = append(steps, step)
+	}
+	if len(stepMeditationIDs(steps)) == 0 && len(missing) > 0 {
+		return Sequence{}, archiveFile{}, missing, errors.New("none of the sequence's meditations could be imported")
+	}
+	err = validateSteps(steps)
+	if err != nil {
+		return Sequence{}, archiveFile{}, missing, err
+	}
+
+	if exported.ImageFile == "" {
+		return Sequence{}, archiveFile{}, missing, errors.New("the sequence has no image")
+	}
+	image, err := readArchiveFile(files, exported.ImageFile)
+	if err != nil {
+		return Sequence{}, archiveFile{}, missing, err
+	}
+	// trust what the image is rather than what it is called
+	contentType := http.DetectContentType(image)
+	fileExt, err := imageExtension(contentType)
+	if err != nil {
+		return Sequence{}, archiveFile{}, missing, errors.New(exported.ImageFile + ": " + err.Error())
+	}
+
+	meditationIds := stepMeditationIDs(steps)
+	meditations := make([]Meditation, len(meditationIds))
+	for i, id := range meditationIds {
+		meditations[i] = Meditation{ID: id}
+	}
+	s := Sequence{
+		UserId:      userId,
+		Name:        exported.Name,
+		Description: exported.Description,
+		Meditations: meditations,
+		Steps:       steps,
+		Tags:        tags,
+		Categories:  categories,
+	}
+	s.setPublication(withPublic(Publication{Status: StatusDraft}, exported.Public, time.Now()))
+	return s, archiveFile{Ext: fileExt, ContentType: contentType, Content: image}, missing, nil
+}
+
+type ArchiveImporter struct {
+	store  *DynamoMeditationStore
+	userId string
+	dryRun bool
+}
+
+// importArchive recreates everything in the archive, meditations first so
+// the sequences can refer to them.
+func (imp *ArchiveImporter) importArchive(archive *zip.Reader) (ArchiveImportReport, error) {
+	report := ArchiveImportReport{DryRun: imp.dryRun, Items: []ArchiveImportItem{}}
+	manifest, files, err := readArchiveManifest(archive)
+	if err != nil {
+		return report, err
+	}
+
+	imported := make(map[string]string) // archived id -> new id
+	for _, exported := range manifest.Meditations {
+		item := ArchiveImportItem{Type: "meditation", SourceID: exported.ID, Name: exported.Name}
+		planned, audio, err := prepareArchiveMeditation(exported, files, imp.userId)
+		if err == nil && !imp.dryRun {
+			item.ID, err = imp.createMeditation(planned, audio)
+		}
+		if err == nil && imp.dryRun {
+			imported[exported.ID] = exported.ID // stands in for its new id
+		} else if err == nil {
+			imported[exported.ID] = item.ID
+		}
+		report.add(item, err)
+	}
+
+	usable, err := imp.usableMeditations(manifest, imported)
+	if err != nil {
+		return report, err
+	}
+	for _, exported := range manifest.Sequences {
+		item := ArchiveImportItem{Type: "sequence", SourceID: exported.ID, Name: exported.Name}
+		planned, image, missing, err := prepareArchiveSequence(exported, files, imp.userId, imported, usable)
+		item.MissingMeditationIDs = missing
+		if err == nil && !imp.dryRun {
+			item.ID, err = imp.createSequence(planned, image)
+		}
+		report.add(item, err)
+	}
+	return report, nil
+}
+
+// usableMeditations finds which of the meditations the archive's sequences
+// use, but that it doesn't hold, the user may use here, e.g. public ones
+// moving between stages of the same install.
+func (imp *ArchiveImporter) usableMeditations(manifest ExportManifest, imported map[string]string) (map[string]bool, error) {
+	inArchive := make(map[string]bool)
+	for _, m := range manifest.Meditations {
+		inArchive[m.ID] = true
+	}
+	others := []string{}
+	for _, s := range manifest.Sequences {
+		for _, id := range stepMeditationIDs(s.Steps) {
+			if !inArchive[id] {
+				others = append(others, id)
+			}
+		}
+	}
+	usable := make(map[string]bool)
+	if len(others) == 0 {
+		return usable, nil
+	}
+	meditations, err := imp.store.GetMeditationsByIds(others)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range meditations {
+		usable[m.ID] = imp.store.CanUseMeditations(imp.userId, []Meditation{m})
+	}
+	return usable, nil
+}
+
+func (imp *ArchiveImporter) createMeditation(m Meditation, audio archiveFile) (string, error) {
+	m.ID = ksuid.New().String()
+	suffix := m.ID + audio.Ext
+	err := putFile("public/"+suffix, audio.ContentType, audio.Content)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	m.URL = mapPathSuffixToFullURL(suffix)
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return m.ID, imp.store.SaveMeditation(m)
+}
+
+func (imp *ArchiveImporter) createSequence(s Sequence, image archiveFile) (string, error) {
+	s.ID = ksuid.New().String()
+	suffix := s.ID + image.Ext
+	err := putFile("public/"+suffix, image.ContentType, image.Content)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	s.ImageURL = mapPathSuffixToFullURL(suffix)
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	return s.ID, imp.store.SaveSequence(s)
+}
+
+// downloadUpload reads an uploaded file into memory, refusing anything larger
+// than `maxSize`.
+func downloadUpload(uploadKey string, maxSize int64, config *aws.Config) ([]byte, error) {
+	sess, _ := session.NewSession(config)
+	svc := s3.New(sess)
+	bucket := os.Getenv("AUDIO_BUCKET")
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(uploadKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if aws.Int64Value(head.ContentLength) > maxSize {
+		return nil, errors.New("the upload is too large")
+	}
+	buffer := aws.NewWriteAtBuffer([]byte{})
+	_, err = s3manager.NewDownloader(sess).Download(buffer, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(uploadKey),
+	})
+	return buffer.Bytes(), err
+}
+
+// runImportArchive is the `import-archive` command, for archives too large
+// to import through the API:
+//
+//	meditation import-archive -archive tempora-export.zip -user <user id> [-dry-run]
+func runImportArchive(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("import-archive", flag.ContinueOnError)
+	flags.SetOutput(out)
+	archivePath := flags.String("archive", "", "the export archive to import")
+	userId := flags.String("user", "", "the user id that will own the imported items")
+	dryRun := flags.Bool("dry-run", false, "check the archive without importing anything")
+	local := flags.Bool("local", false, "use localstack")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if *archivePath == "" || *userId == "" {
+		fmt.Fprintln(out, "-archive and -user are required")
+		flags.Usage()
+		return 2
+	}
+
+	archive, err := zip.OpenReader(*archivePath)
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+	defer archive.Close()
+
+	awsConfig = getAwsConfig(*local)
+	store := NewDynamoMeditationStore(os.Getenv("DDB_TABLE"), awsConfig)
+	imp := ArchiveImporter{store: &store, userId: *userId, dryRun: *dryRun}
+	report, err := imp.importArchive(&archive.Reader)
+	for _, item := range report.Items {
+		fmt.Fprintf(out, "%-9s %s %s -> %s %q %s\n", item.Result, item.Type, item.SourceID, item.ID, item.Name, item.Error)
+		if len(item.MissingMeditationIDs) > 0 {
+			fmt.Fprintf(out, "          left out meditations %s\n", strings.Join(item.MissingMeditationIDs, ", "))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		return 1
+	}
+	if report.Failed > 0 {
+		fmt.Fprintf(out, "%d items could not be imported\n", report.Failed)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-test/deep"
+)
+
+func buildArchive(t *testing.T, manifest ExportManifest, files map[string][]byte) *zip.Reader {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	manifestJson, _ := json.Marshal(manifest)
+	files[EXPORT_MANIFEST_FILE] = manifestJson
+	for name, content := range files {
+		f, _ := archive.Create(name)
+		f.Write(content)
+	}
+	err := archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestArchiveImport(t *testing.T) {
+	validate = validator.New()
+	validate.RegisterValidation("uploadKey", uploadKeyValidator)
+	audio, err := ioutil.ReadFile("../media/evagrius.onprayer.003.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := ioutil.ReadFile("../media/evagrius.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := ExportManifest{
+		Format: EXPORT_FORMAT,
+		Meditations: []ExportedMeditation{
+			{Meditation: Meditation{ID: "m1", Name: "On Prayer 3", Text: "Prayer is...", Public: true, Tags: []string{"Evagrius"}}, AudioFile: "audio/m1.mp3"},
+			{Meditation: Meditation{ID: "m2", Name: "Not audio", Text: "..."}, AudioFile: "audio/m2.mp3"},
+		},
+		Sequences: []ExportedSequence{{
+			Sequence: Sequence{
+				ID:          "s1",
+				Name:        "On Prayer",
+				Description: "Chapters on prayer",
+				Steps: []SequenceStep{
+					{Type: StepMeditation, MeditationID: "m1", Duration: 30},
+					{Type: StepSilence, Seconds: 30},
+					{Type: StepMeditation, MeditationID: "m2"},
+					{Type: StepRepeat, MeditationID: "public1", Times: 2},
+					{Type: StepMeditation, MeditationID: "private1"},
+				},
+			},
+			ImageFile: "images/s1.jpg", // named wrongly, but a png
+		}},
+	}
+	files := map[string][]byte{
+		"audio/m1.mp3":  audio,
+		"audio/m2.mp3":  []byte("not an mp3"),
+		"images/s1.jpg": image,
+	}
+	read, archived, err := readArchiveManifest(buildArchive(t, manifest, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("meditations are checked like uploads", func(t *testing.T) {
+		m, file, err := prepareArchiveMeditation(read.Meditations[0], archived, "alex")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.UserId != "alex" || m.ID != "" || m.Status != StatusPublished || m.Moderation != ModerationPending || m.Duration < 1 || file.Ext != ".mp3" || file.ContentType != "audio/mpeg" {
+			t.Errorf("Unexpected meditation %+v %s %s", m, file.Ext, file.ContentType)
+		}
+		if diff := deep.Equal(m.Tags, []string{"evagrius"}); diff != nil {
+			t.Error(diff)
+		}
+
+		_, _, err = prepareArchiveMeditation(read.Meditations[1], archived, "alex")
+		if err == nil {
+			t.Error("Expected audio that isn't an mp3 to be refused")
+		}
+	})
+
+	t.Run("sequences point at the new ids", func(t *testing.T) {
+		imported := map[string]string{"m1": "new1"}
+		usable := map[string]bool{"public1": true, "private1": false}
+		s, file, missing, err := prepareArchiveSequence(read.Sequences[0], archived, "alex", imported, usable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []SequenceStep{
+			{Type: StepMeditation, MeditationID: "new1"},
+			{Type: StepSilence, Seconds: 30},
+			{Type: StepRepeat, MeditationID: "public1", Times: 2},
+		}
+		if diff := deep.Equal(s.Steps, expected); diff != nil {
+			t.Error(diff)
+		}
+		if diff := deep.Equal(missing, []string{"m2", "private1"}); diff != nil {
+			t.Error(diff)
+		}
+		if len(s.Meditations) != 2 || s.Meditations[0].ID != "new1" || file.Ext != ".png" {
+			t.Errorf("Unexpected sequence %+v %s", s, file.Ext)
+		}
+
+		_, _, _, err = prepareArchiveSequence(read.Sequences[0], archived, "alex", map[string]string{}, map[string]bool{})
+		if err == nil {
+			t.Error("Expected a sequence with none of its meditations to fail")
+		}
+	})
+
+	t.Run("archives that aren't exports are refused", func(t *testing.T) {
+		manifest.Format = EXPORT_FORMAT + 1
+		_, _, err := readArchiveManifest(buildArchive(t, manifest, map[string][]byte{}))
+		if !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("Expected ErrInvalidArchive, got %v", err)
+		}
+	})
+}
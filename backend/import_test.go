@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestPlanImport(t *testing.T) {
+	raw, err := ioutil.ReadFile("../scripts/old_catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := []LegacyAuthor{}
+	err = json.Unmarshal(raw, &catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	works, err := planImport(catalog, "alex", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(works) != 2 {
+		t.Fatalf("Expected a sequence per work, got %d", len(works))
+	}
+	onPrayer, philippians := works[0], works[1]
+	if onPrayer.Sequence.Name != "On Prayer" || len(onPrayer.Sections) != 31 {
+		t.Errorf("Unexpected work %s with %d sections", onPrayer.Sequence.Name, len(onPrayer.Sections))
+	}
+
+	first := onPrayer.Sections[0].Meditation
+	if first.Name != "On Prayer 1" || first.UserId != "alex" {
+		t.Errorf("Unexpected meditation %+v", first)
+	}
+	// public imports are submitted for review like any other published item
+	if first.Status != StatusPublished || first.Moderation != ModerationPending || first.Public {
+		t.Errorf("Expected the meditation awaiting review, got %s, %s", first.Status, first.Moderation)
+	}
+	if s := onPrayer.Sequence; s.Status != StatusPublished || s.Moderation != ModerationPending || s.Public {
+		t.Errorf("Expected the sequence awaiting review, got %s, %s", s.Status, s.Moderation)
+	}
+	drafts, _ := planImport(catalog, "alex", false)
+	if m := drafts[0].Sections[0].Meditation; m.Status != StatusDraft || m.Moderation != "" || m.Public {
+		t.Errorf("Expected a draft, got %s, %s", m.Status, m.Moderation)
+	}
+	expected := &Citation{Author: "Evagrius Ponticus", Work: "On Prayer", Section: "1"}
+	if diff := deep.Equal(first.Citation, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// scripture chapters are numbered by verse
+	verse := philippians.Sections[0].Meditation
+	if verse.Name != "Philippians 4:4" || verse.Citation.Scripture == nil || verse.Citation.Work != "Philippians" {
+		t.Errorf("Unexpected meditation %+v", verse)
+	}
+
+	// ids are stable across runs and distinct
+	again, _ := planImport(catalog, "alex", true)
+	if again[0].Sequence.ID != onPrayer.Sequence.ID || again[0].Sections[5].Meditation.ID != onPrayer.Sections[5].Meditation.ID {
+		t.Error("Expected the same ids on every run")
+	}
+	seen := make(map[string]bool)
+	for _, w := range works {
+		for _, id := range append([]string{w.Sequence.ID}, sectionIds(w)...) {
+			if seen[id] {
+				t.Errorf("Duplicate id %s", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func sectionIds(w importedWork) []string {
+	ids := []string{}
+	for _, s := range w.Sections {
+		ids = append(ids, s.Meditation.ID)
+	}
+	return ids
+}
+
+func TestImportAudioValidation(t *testing.T) {
+	imp := Importer{mediaDir: "../media"}
+	contentType, audio, err := imp.loadAudio("https://s3.amazonaws.com/tempora-pray-web-bucket/audio/evagrius.onprayer.003.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileExt, duration, err := validateAudioContent(contentType, audio)
+	if err != nil || fileExt != ".mp3" || duration < 1 {
+		t.Errorf("Expected a valid mp3, got %q %d %v", fileExt, duration, err)
+	}
+
+	contentType, audio, _ = imp.loadAudio("too_long_2m_9s.mp3")
+	_, _, err = validateAudioContent(contentType, audio)
+	if err == nil {
+		t.Error("Expected the long mp3 to be rejected")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWKS_CACHE_TTL is how long fetched signing keys are trusted before they're
+// fetched again, and JWKS_MIN_REFRESH how often at most they're fetched when
+// a token names a key we don't have.
+const JWKS_CACHE_TTL = 10 * time.Minute
+const JWKS_MIN_REFRESH = time.Minute
+
+// JWKS is a JSON Web Key Set, as identity providers publish their signing
+// keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k JWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s has an unusable exponent", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("key %s is on unsupported curve %s", k.Kid, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s is not on its curve", k.Kid)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("key %s has unsupported type %s", k.Kid, k.Kty)
+	}
+}
+
+// JWKSCache is a KeySource fetching the identity provider's keys from URL.
+// A token signed with a key it doesn't have, as when the provider rotates
+// its keys, has it fetch them again, though no more than MinRefresh apart.
+// Should the provider be unreachable, the keys it had keep being used.
+// Callers needing keys while a fetch is under way wait on it rather than
+// fetching again, and the rest carry on with the keys there are.
+type JWKSCache struct {
+	URL        string
+	Client     *http.Client
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	now         func() time.Time
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetching    chan struct{} // closed once the fetch under way is done
+	fetchErr    error         // how the last fetch failed, if it did
+}
+
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		URL:        url,
+		Client:     &http.Client{Timeout: 5 * time.Second},
+		TTL:        JWKS_CACHE_TTL,
+		MinRefresh: JWKS_MIN_REFRESH,
+		now:        time.Now,
+	}
+}
+
+func (c *JWKSCache) Key(kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	now := c.now()
+	key, ok := c.lookup(kid)
+	expired := c.keys == nil || now.Sub(c.fetchedAt) >= c.TTL
+	var done chan struct{}
+	if c.fetching != nil {
+		if !ok {
+			done = c.fetching
+		}
+	} else if (expired || !ok) && (c.attemptedAt.IsZero() || now.Sub(c.attemptedAt) >= c.MinRefresh) {
+		done = c.refresh(now)
+	}
+	c.mu.Unlock()
+
+	if done != nil {
+		<-done
+		c.mu.Lock()
+		key, ok = c.lookup(kid)
+		err := c.fetchErr
+		c.mu.Unlock()
+		if err != nil && !ok {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh fetches the keys without holding the lock, which it must be
+// called with, returning a channel closed once they're in.
+func (c *JWKSCache) refresh(now time.Time) chan struct{} {
+	done := make(chan struct{})
+	c.fetching, c.attemptedAt = done, now
+	go func() {
+		keys, err := c.fetch()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err == nil {
+			c.keys, c.fetchedAt = keys, now
+		}
+		c.fetching, c.fetchErr = nil, err
+		close(done)
+	}()
+	return done
+}
+
+// lookup finds `kid`, or for a token naming no key, the only key there is.
+func (c *JWKSCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *JWKSCache) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := c.Client.Get(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", c.URL, resp.Status)
+	}
+	set := JWKS{}
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip keys we can't use rather than failing on them
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys at " + c.URL)
+	}
+	return keys, nil
+}
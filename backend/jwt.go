@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tokens are normally verified by the HTTP API's JWT authorizer before a
+// request gets here, see serverless.yml. Deployed without one, the handler
+// verifies them itself, see auth.go. Only RS256 and ES256 are accepted, which
+// covers what identity providers such as Auth0 sign with.
+
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrBadSignature     = errors.New("bad signature")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrWrongIssuer      = errors.New("token is from another issuer")
+	ErrWrongAudience    = errors.New("token is for another audience")
+)
+
+// TokenPolicy is what a token must satisfy besides being signed.
+type TokenPolicy struct {
+	Issuer    string
+	Audiences []string      // the token must be for one of them
+	ClockSkew time.Duration // allowed between us and the issuer
+}
+
+// KeySource finds the public key a token was signed with.
+type KeySource interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeys is a KeySource that never changes.
+type StaticKeys map[string]crypto.PublicKey
+
+func (keys StaticKeys) Key(kid string) (crypto.PublicKey, error) {
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verifyJWT checks `token`'s signature and claims, returning the claims.
+func verifyJWT(token string, keys KeySource, policy TokenPolicy, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	header := jwtHeader{}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	key, err := keys.Key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature)
+	if err != nil {
+		return nil, err
+	}
+	err = checkClaims(claims, policy, now)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if decoder.Decode(v) != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		if rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrBadSignature
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		// r and s, each padded to 32 bytes
+		if len(signature) != 64 {
+			return ErrBadSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrBadSignature
+		}
+		return nil
+	default:
+		return ErrUnsupportedAlg
+	}
+}
+
+// checkClaims checks the token is from the issuer, for us and current,
+// give or take the clock skew.
+func checkClaims(claims map[string]interface{}, policy TokenPolicy, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != policy.Issuer {
+		return ErrWrongIssuer
+	}
+	if !hasAudience(claims["aud"], policy.Audiences) {
+		return ErrWrongAudience
+	}
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return ErrMalformedToken
+	}
+	if !now.Before(exp.Add(policy.ClockSkew)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(policy.ClockSkew).Before(nbf) {
+		return ErrTokenNotYetValid
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Add(policy.ClockSkew).Before(iat) {
+		return ErrTokenNotYetValid
+	}
+	return nil
+}
+
+// hasAudience reports whether `aud`, a string or a list of them, names one
+// of `audiences`.
+func hasAudience(aud interface{}, audiences []string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return containsString(audiences, aud)
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && containsString(audiences, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// flattenClaims renders claims as the HTTP API's authorizer passes them on:
+// every value a string, and lists as "[a b]".
+func flattenClaims(claims map[string]interface{}) map[string]string {
+	flat := make(map[string]string, len(claims))
+	for name, value := range claims {
+		flat[name] = flattenClaim(value)
+	}
+	return flat
+}
+
+func flattenClaim(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = flattenClaim(item)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case nil:
+		return ""
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(encoded)
+	}
+}
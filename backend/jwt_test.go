@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestJWT(t *testing.T) {
+	now := time.Date(2027, 2, 10, 12, 0, 0, 0, time.UTC)
+	signer, err := NewTestSigner("https://tempora.test/", "tempora")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	policy := TokenPolicy{Issuer: signer.Issuer, Audiences: []string{"other", "tempora"}, ClockSkew: time.Minute}
+
+	t.Run("Signed tokens verify and read like the authorizer's claims", func(t *testing.T) {
+		token, _ := signer.Sign(map[string]interface{}{"sub": "alex", "roles": []string{"moderator", "curator"}}, now, time.Hour)
+		claims, err := verifyJWT(token, signer.Keys(), policy, now)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		flat := flattenClaims(claims)
+		if flat["sub"] != "alex" || flat["roles"] != "[moderator curator]" || flat["exp"] != "1802264400" {
+			t.Errorf("Unexpected claims %v", flat)
+		}
+		caller, _ := callerPrincipal(requestWithClaims(flat))
+		if !caller.canModerate() || !caller.skipsReview() {
+			t.Errorf("Expected the roles to carry over, got %v", caller.Roles)
+		}
+	})
+
+	t.Run("Issuer, audience and times are checked", func(t *testing.T) {
+		cases := []struct {
+			claims map[string]interface{}
+			at     time.Time
+			err    error
+		}{
+			{map[string]interface{}{"iss": "https://evil.test/"}, now, ErrWrongIssuer},
+			{map[string]interface{}{"aud": "someone-else"}, now, ErrWrongAudience},
+			{map[string]interface{}{"aud": []string{"someone-else", "tempora"}}, now, nil},
+			{map[string]interface{}{}, now.Add(time.Hour + 30*time.Second), nil},
+			{map[string]interface{}{}, now.Add(time.Hour + 2*time.Minute), ErrTokenExpired},
+			{map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()}, now, ErrTokenNotYetValid},
+			{map[string]interface{}{"iat": now.Add(30 * time.Second).Unix()}, now, nil},
+			{map[string]interface{}{"exp": nil}, now, ErrMalformedToken},
+		}
+		for i, c := range cases {
+			c.claims["sub"] = "alex"
+			token, _ := signer.Sign(c.claims, now, time.Hour)
+			_, err := verifyJWT(token, signer.Keys(), policy, c.at)
+			if !errors.Is(err, c.err) {
+				t.Errorf("Case %d: expected %v, got %v", i, c.err, err)
+			}
+		}
+	})
+
+	t.Run("Tampered and unsigned tokens are rejected", func(t *testing.T) {
+		token, _ := signer.Sign(map[string]interface{}{"sub": "alex"}, now, time.Hour)
+		parts := strings.Split(token, ".")
+		admin, _ := json.Marshal(map[string]interface{}{"sub": "alex", "roles": "admin", "iss": signer.Issuer, "aud": "tempora", "exp": now.Add(time.Hour).Unix()})
+		forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(admin) + "." + parts[2]
+		if _, err := verifyJWT(forged, signer.Keys(), policy, now); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("Expected ErrBadSignature, got %v", err)
+		}
+
+		none, _ := json.Marshal(map[string]string{"alg": "none", "kid": testKeyId})
+		unsigned := base64.RawURLEncoding.EncodeToString(none) + "." + parts[1] + "."
+		if _, err := verifyJWT(unsigned, signer.Keys(), policy, now); !errors.Is(err, ErrUnsupportedAlg) {
+			t.Errorf("Expected ErrUnsupportedAlg, got %v", err)
+		}
+
+		other, _ := NewTestSigner(signer.Issuer, "tempora")
+		other.KeyId = "someone-elses"
+		token, _ = other.Sign(map[string]interface{}{"sub": "alex"}, now, time.Hour)
+		if _, err := verifyJWT(token, signer.Keys(), policy, now); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+		if _, err := verifyJWT("not.a-token", signer.Keys(), policy, now); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("Expected ErrMalformedToken, got %v", err)
+		}
+	})
+
+	t.Run("Keys are fetched, cached and refetched when rotated", func(t *testing.T) {
+		rotated, _ := NewTestSigner(signer.Issuer, "tempora")
+		rotated.KeyId = "rotated"
+		var mu sync.Mutex
+		published, fetches := signer.JWKS(), 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			fetches++
+			json.NewEncoder(w).Encode(published)
+		}))
+		defer server.Close()
+
+		clock := now
+		cache := NewJWKSCache(server.URL)
+		cache.now = func() time.Time { return clock }
+
+		token, _ := signer.Sign(map[string]interface{}{"sub": "alex"}, now, time.Hour)
+		for i := 0; i < 3; i++ {
+			if _, err := verifyJWT(token, cache, policy, now); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		if fetches != 1 {
+			t.Errorf("Expected the keys fetched once, got %d", fetches)
+		}
+
+		// the provider rotates its keys
+		mu.Lock()
+		published = rotated.JWKS()
+		mu.Unlock()
+		newToken, _ := rotated.Sign(map[string]interface{}{"sub": "alex"}, now, time.Hour)
+		clock = now.Add(10 * time.Second)
+		if _, err := verifyJWT(newToken, cache, policy, now); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected refetching to wait, got %v", err)
+		}
+		clock = now.Add(JWKS_MIN_REFRESH)
+		if _, err := verifyJWT(newToken, cache, policy, now); err != nil {
+			t.Errorf("Expected the new key to be fetched, got %v", err)
+		}
+		if _, err := verifyJWT(token, cache, policy, now); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected the retired key to be dropped, got %v", err)
+		}
+		if fetches != 2 {
+			t.Errorf("Expected 2 fetches, got %d", fetches)
+		}
+
+		// an unreachable provider leaves the keys we have
+		server.Close()
+		clock = now.Add(JWKS_MIN_REFRESH + JWKS_CACHE_TTL)
+		if _, err := verifyJWT(newToken, cache, policy, now); err != nil {
+			t.Errorf("Expected the cached key to be used, got %v", err)
+		}
+	})
+
+	t.Run("Concurrent lookups share a fetch without holding up cached keys", func(t *testing.T) {
+		var mu sync.Mutex
+		fetches := 0
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			fetches++
+			gated := fetches > 1
+			mu.Unlock()
+			if gated {
+				<-release
+			}
+			json.NewEncoder(w).Encode(signer.JWKS())
+		}))
+		defer server.Close()
+
+		cache := NewJWKSCache(server.URL)
+		cache.now = func() time.Time { return now }
+		if _, err := cache.Key(testKeyId); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// lookups of a key we don't have wait on a single fetch
+		cache.now = func() time.Time { return now.Add(JWKS_MIN_REFRESH) }
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := cache.Key("rotated"); !errors.Is(err, ErrUnknownKey) {
+					t.Errorf("Expected ErrUnknownKey, got %v", err)
+				}
+			}()
+		}
+		for {
+			cache.mu.Lock()
+			fetching := cache.fetching != nil
+			cache.mu.Unlock()
+			if fetching {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if _, err := cache.Key(testKeyId); err != nil {
+			t.Errorf("Expected the cached key while fetching, got %v", err)
+		}
+		close(release)
+		wg.Wait()
+		if fetches != 2 {
+			t.Errorf("Expected 2 fetches, got %d", fetches)
+		}
+	})
+
+	t.Run("Only public endpoints can be called without a token", func(t *testing.T) {
+		auth := Authenticator{Keys: signer.Keys(), Policy: policy}
+		request := func(method string, path string, token string) events.APIGatewayV2HTTPRequest {
+			req := events.APIGatewayV2HTTPRequest{Headers: map[string]string{}}
+			req.RequestContext.HTTP.Method = method
+			req.RequestContext.HTTP.Path = path
+			if token != "" {
+				req.Headers["authorization"] = "Bearer " + token
+			}
+			return req
+		}
+
+		req := request("GET", "/public/meditations", "")
+		if resp := auth.authenticate(&req, now); resp != nil || req.RequestContext.Authorizer == nil {
+			t.Errorf("Expected a public listing to need no token, got %v", resp)
+		}
+		req = request("POST", "/public/sequences/abc/fork", "")
+		if resp := auth.authenticate(&req, now); resp == nil || resp.StatusCode != 401 {
+			t.Errorf("Expected forking to need a token, got %v", resp)
+		}
+		req = request("GET", "/meditations", "garbage")
+		if resp := auth.authenticate(&req, now); resp == nil || resp.StatusCode != 401 {
+			t.Errorf("Expected a bad token to be rejected, got %v", resp)
+		}
+
+		token, _ := signer.Sign(map[string]interface{}{"sub": "alex"}, now, time.Hour)
+		req = request("GET", "/meditations", token)
+		if resp := auth.authenticate(&req, now); resp != nil || req.RequestContext.Authorizer.JWT.Claims["sub"] != "alex" {
+			t.Errorf("Expected the claims passed on, got %v", resp)
+		}
+	})
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"errors"
+	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -29,17 +31,61 @@ func getAwsConfig(local bool) *aws.Config {
 func handler(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
 	store := NewDynamoMeditationStore(os.Getenv("DDB_TABLE"), awsConfig)
 
+	// deployed without the HTTP API's authorizer, see auth.go
+	if authenticator != nil {
+		if resp := authenticator.authenticate(&req, time.Now()); resp != nil {
+			return resp, nil
+		}
+	}
+
 	// 0) miscellaneous
 	switch req.RequestContext.HTTP.Path {
 	case "/upload-url":
 		return uploadHandler(req), nil
 	case "/public/meditations":
 		return ListPublicMeditationsHandler(req, &store), nil
+	case "/public/tags":
+		return GetTagCloudHandler(req, &store), nil
+	case "/public/search":
+		return SearchPublicHandler(req, &store), nil
+	case "/public/authors":
+		return ListAuthorsHandler(req, &store), nil
+	case "/public/works":
+		return ListWorksHandler(req, &store), nil
+	case "/me/stats":
+		return GetStatsHandler(req, &store), nil
+	case "/me/export":
+		return GetExportHandler(req, &store), nil
+	case "/me/import":
+		return ImportArchiveHandler(req, &store), nil
 
 	}
 
+	// reports on public items, which need no login
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/public/") && strings.HasSuffix(req.RequestContext.HTTP.Path, "/reports") {
+		return CreateReportHandler(req, &store), nil
+	}
+
 	// 1) sequences
 	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/sequences") {
+		if strings.Contains(req.RequestContext.HTTP.Path, "/revisions") {
+			if req.RequestContext.HTTP.Method == "POST" {
+				return RestoreSequenceRevisionHandler(req, &store), nil
+			}
+			if _, ok := req.PathParameters["version"]; ok {
+				return GetSequenceRevisionHandler(req, &store), nil
+			}
+			return ListSequenceRevisionsHandler(req, &store), nil
+		}
+		if strings.HasSuffix(req.RequestContext.HTTP.Path, "/items:move") {
+			return MoveSequenceItemHandler(req, &store), nil
+		}
+		if strings.Contains(req.RequestContext.HTTP.Path, "/items") {
+			if req.RequestContext.HTTP.Method == "DELETE" {
+				return DeleteSequenceItemHandler(req, &store), nil
+			}
+			return InsertSequenceItemHandler(req, &store), nil
+		}
 		switch req.RequestContext.HTTP.Method {
 		case "GET":
 			if _, ok := req.PathParameters["sequenceId"]; ok {
@@ -51,7 +97,7 @@ func handler(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPRespon
 		case "PUT":
 			return UpdateSequenceHandler(req, &store), nil
 		case "PATCH":
-			return UpdateSequenceHandler(req, &store), nil
+			return PatchSequenceHandler(req, &store), nil
 		case "DELETE":
 			return DeleteSequenceByIdHandler(req, &store), nil
 		}
@@ -59,15 +105,90 @@ func handler(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPRespon
 	}
 
 	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/public/sequences") {
+		if strings.HasSuffix(req.RequestContext.HTTP.Path, "/fork") {
+			return ForkSequenceHandler(req, &store), nil
+		}
 		if _, ok := req.PathParameters["sequenceId"]; ok {
 			return GetPublicSequenceByIdHandler(req, &store), nil
 		}
 		return ListPublicSequencesHandler(req, &store), nil
 	}
 
-	// 2) meditations
+	// 2) practice sessions
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/sessions") {
+		_, hasId := req.PathParameters["sessionId"]
+		switch req.RequestContext.HTTP.Method {
+		case "GET":
+			if hasId {
+				return GetSessionHandler(req, &store), nil
+			}
+			return ListSessionsHandler(req, &store), nil
+		case "POST":
+			return CreateSessionHandler(req, &store), nil
+		case "PUT":
+			return UpdateSessionHandler(req, &store), nil
+		case "DELETE":
+			return DeleteSessionHandler(req, &store), nil
+		}
+	}
+
+	// 3) the user's library
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/library") {
+		switch req.RequestContext.HTTP.Method {
+		case "GET":
+			return ListLibraryHandler(req, &store), nil
+		case "PUT":
+			return AddToLibraryHandler(req, &store), nil
+		case "DELETE":
+			return RemoveFromLibraryHandler(req, &store), nil
+		}
+	}
+
+	// 4) the trash
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/trash") {
+		if req.RequestContext.HTTP.Method == "POST" {
+			return RestoreTrashHandler(req, &store), nil
+		}
+		return ListTrashHandler(req, &store), nil
+	}
+
+	// 5) moderation
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/moderation/reports") {
+		if req.RequestContext.HTTP.Method == "DELETE" {
+			return DismissReportsHandler(req, &store), nil
+		}
+		return ListReportsHandler(req, &store), nil
+	}
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/moderation") {
+		if req.RequestContext.HTTP.Method == "POST" {
+			return ModerateHandler(req, &store), nil
+		}
+		return ListModerationQueueHandler(req, &store), nil
+	}
+
+	// 6) notifications
+	if strings.HasPrefix(req.RequestContext.HTTP.Path, "/me/notifications") {
+		if req.RequestContext.HTTP.Method == "DELETE" {
+			return DeleteNotificationHandler(req, &store), nil
+		}
+		return ListNotificationsHandler(req, &store), nil
+	}
+
+	// 7) meditations
+	if strings.Contains(req.RequestContext.HTTP.Path, "/revisions") {
+		if req.RequestContext.HTTP.Method == "POST" {
+			return RestoreMeditationRevisionHandler(req, &store), nil
+		}
+		if _, ok := req.PathParameters["version"]; ok {
+			return GetMeditationRevisionHandler(req, &store), nil
+		}
+		return ListMeditationRevisionsHandler(req, &store), nil
+	}
 	switch req.RequestContext.HTTP.Method {
 	case "GET":
+		if strings.HasSuffix(req.RequestContext.HTTP.Path, "/usage") {
+			return GetMeditationUsageHandler(req, &store), nil
+		}
 		if _, ok := req.PathParameters["meditationId"]; ok {
 			// a get by medition id
 			return GetMeditationHandler(req, &store), nil
@@ -77,7 +198,7 @@ func handler(req events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPRespon
 	case "POST":
 		return CreateMeditationHandler(req, &store), nil
 	case "PATCH":
-		return UpdateMeditationHandler(req, &store), nil
+		return PatchMeditationHandler(req, &store), nil
 	case "PUT":
 		return UpdateMeditationHandler(req, &store), nil
 	case "DELETE":
@@ -93,5 +214,30 @@ func main() {
 	validate.RegisterValidation("uploadKey", uploadKeyValidator)
 	awsConfig = getAwsConfig(false)
 
-	lambda.Start(handler)
+	// the same binary imports the legacy catalog and export archives, see
+	// runImport and runImportArchive, and signs test tokens, see runSignToken
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-archive" {
+		os.Exit(runImportArchive(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sign-token" {
+		os.Exit(runSignToken(os.Args[2:], os.Stdout))
+	}
+
+	// ...and the workers, which are picked by $TEMPORA_WORKER
+	switch os.Getenv("TEMPORA_WORKER") {
+	case "purge":
+		lambda.Start(PurgeTrashHandler)
+	case "publish":
+		lambda.Start(PublishScheduledHandler)
+	default:
+		var err error
+		authenticator, err = authenticatorFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		lambda.Start(handler)
+	}
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// Publishing a meditation or sequence, now or on a schedule, submits it for
+// moderation. It only becomes public once it is both published and approved
+// by a moderator; until then a published one waits under pppk "review", which
+// is the moderators' queue. Approval sticks through unpublishing and
+// republishing, but changing what the public sees of an approved item sends
+// it back for review, unless a curator made the change. A rejection takes the
+// item back out of publication, and publishing it again resubmits it.
+
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// reviewPppk is the gs3 partition published items wait in for approval.
+const reviewPppk = "review"
+
+// MAX_MODERATION_REASON is how long a rejection's reason can be, in bytes.
+const MAX_MODERATION_REASON = 1000
+
+var ErrNotModerated = errors.New("not awaiting moderation")
+
+// moderationState fills in the moderation of items that were public before
+// moderation existed, which count as approved.
+func moderationState(moderation string, public bool) string {
+	if moderation == "" && public {
+		return ModerationApproved
+	}
+	return moderation
+}
+
+// nextModeration is the moderation of an item moving to publication `status`.
+func nextModeration(current string, status string) string {
+	if status != StatusScheduled && status != StatusPublished {
+		return current
+	}
+	if current == ModerationApproved {
+		return current
+	}
+	return ModerationPending
+}
+
+// isVisible reports whether an item is public.
+func isVisible(status string, moderation string) bool {
+	return status == StatusPublished && moderation == ModerationApproved
+}
+
+// awaitingReview reports whether an item is in the moderators' queue: it is
+// pending and published or about to be.
+func awaitingReview(status string, moderation string) bool {
+	return moderation == ModerationPending && (status == StatusPublished || status == StatusScheduled)
+}
+
+// Moderation is a moderator's decision on an item.
+type Moderation struct {
+	Approved    bool
+	Reason      string
+	ModeratorId string
+	At          time.Time
+}
+
+func (m *Meditation) moderate(decision Moderation) error {
+	if !awaitingReview(m.publication().Status, m.Moderation) && !(m.Moderation == ModerationApproved && !decision.Approved) {
+		return ErrNotModerated
+	}
+	at := decision.At.UTC()
+	m.ModeratedAt, m.ModerationNote = &at, decision.Reason
+	if decision.Approved {
+		m.Moderation = ModerationApproved
+		m.Public = isVisible(m.Status, m.Moderation)
+		return nil
+	}
+	m.Moderation = ModerationRejected
+	m.setPublication(Publication{Status: rejectedStatus(m.Status)})
+	return nil
+}
+
+func (s *Sequence) moderate(decision Moderation) error {
+	if !awaitingReview(s.publication().Status, s.Moderation) && !(s.Moderation == ModerationApproved && !decision.Approved) {
+		return ErrNotModerated
+	}
+	at := decision.At.UTC()
+	s.ModeratedAt, s.ModerationNote = &at, decision.Reason
+	if decision.Approved {
+		s.Moderation = ModerationApproved
+		s.Public = isVisible(s.Status, s.Moderation)
+		return nil
+	}
+	s.Moderation = ModerationRejected
+	s.setPublication(Publication{Status: rejectedStatus(s.Status)})
+	return nil
+}
+
+// rejectedStatus is where a rejection leaves an item: a published one is
+// unpublished, and a scheduled one goes back to being a draft.
+func rejectedStatus(status string) string {
+	if status == StatusPublished || status == StatusUnpublished {
+		return StatusUnpublished
+	}
+	return StatusDraft
+}
+
+// hide takes a public item back into the moderators' queue, as when enough
+// people report it. It reports whether there was anything to hide.
+func (m *Meditation) hide() bool {
+	if !m.Public {
+		return false
+	}
+	m.Moderation = ModerationPending
+	m.Public = isVisible(m.Status, m.Moderation)
+	return true
+}
+
+func (s *Sequence) hide() bool {
+	if !s.Public {
+		return false
+	}
+	s.Moderation = ModerationPending
+	s.Public = isVisible(s.Status, s.Moderation)
+	return true
+}
+
+// takeDown rejects an item on an admin's behalf as they delete it, so that
+// its author restoring it from the trash doesn't republish it without a
+// moderator.
+func (m *Meditation) takeDown(at time.Time) {
+	at = at.UTC()
+	m.ModeratedAt, m.ModerationNote = &at, "taken down by an admin"
+	m.Moderation = ModerationRejected
+	m.setPublication(Publication{Status: rejectedStatus(m.publication().Status)})
+}
+
+func (s *Sequence) takeDown(at time.Time) {
+	at = at.UTC()
+	s.ModeratedAt, s.ModerationNote = &at, "taken down by an admin"
+	s.Moderation = ModerationRejected
+	s.setPublication(Publication{Status: rejectedStatus(s.publication().Status)})
+}
+
+// publishAs is setPublication by `caller`. What a curator submits is
+// approved straight away, though not an item that was already waiting for
+// review, such as one reports have hidden.
+func (m *Meditation) publishAs(caller Principal, p Publication, at time.Time) {
+	waiting := awaitingReview(m.publication().Status, m.Moderation)
+	m.setPublication(p)
+	if caller.skipsReview() && !waiting && awaitingReview(m.Status, m.Moderation) {
+		at = at.UTC()
+		m.Moderation, m.ModerationNote, m.ModeratedAt = ModerationApproved, "", &at
+		m.Public = isVisible(m.Status, m.Moderation)
+	}
+}
+
+func (s *Sequence) publishAs(caller Principal, p Publication, at time.Time) {
+	waiting := awaitingReview(s.publication().Status, s.Moderation)
+	s.setPublication(p)
+	if caller.skipsReview() && !waiting && awaitingReview(s.Status, s.Moderation) {
+		at = at.UTC()
+		s.Moderation, s.ModerationNote, s.ModeratedAt = ModerationApproved, "", &at
+		s.Public = isVisible(s.Status, s.Moderation)
+	}
+}
+
+// resubmit sends an approved item back to the moderators' queue. It reports
+// whether it did.
+func (m *Meditation) resubmit() bool {
+	if m.Moderation != ModerationApproved {
+		return false
+	}
+	m.Moderation = ModerationPending
+	m.Public = isVisible(m.Status, m.Moderation)
+	return true
+}
+
+func (s *Sequence) resubmit() bool {
+	if s.Moderation != ModerationApproved {
+		return false
+	}
+	s.Moderation = ModerationPending
+	s.Public = isVisible(s.Status, s.Moderation)
+	return true
+}
+
+// reviewEdits resubmits the item if `caller` changed what the public sees of
+// it since `before`, unless they're a curator.
+func (m *Meditation) reviewEdits(caller Principal, before Meditation) {
+	if !caller.skipsReview() && !sameMeditationContent(before, *m) {
+		m.resubmit()
+	}
+}
+
+func (s *Sequence) reviewEdits(caller Principal, before Sequence) {
+	if !caller.skipsReview() && !sameSequenceContent(before, *s) {
+		s.resubmit()
+	}
+}
+
+func sameMeditationContent(a Meditation, b Meditation) bool {
+	return a.Name == b.Name && a.Text == b.Text && a.URL == b.URL &&
+		sameStrings(a.Tags, b.Tags) && sameStrings(a.Categories, b.Categories) &&
+		reflect.DeepEqual(a.Citation, b.Citation)
+}
+
+func sameSequenceContent(a Sequence, b Sequence) bool {
+	return a.Name == b.Name && a.Description == b.Description && a.ImageURL == b.ImageURL &&
+		sameStrings(a.Tags, b.Tags) && sameStrings(a.Categories, b.Categories) &&
+		sameSteps(a.Steps, b.Steps)
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSteps compares steps as saved, ignoring their computed durations.
+func sameSteps(a []SequenceStep, b []SequenceStep) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.Duration, y.Duration = 0, 0
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
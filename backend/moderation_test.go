@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestModeration(t *testing.T) {
+	now := time.Date(2027, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Publishing waits for approval, which sticks", func(t *testing.T) {
+		m := Meditation{}
+		m.setPublication(Publication{Status: StatusPublished})
+		if m.Public || m.Moderation != ModerationPending {
+			t.Fatalf("Expected a pending meditation, got %+v", m)
+		}
+		if err := m.moderate(Moderation{Approved: true, At: now}); err != nil || !m.Public {
+			t.Fatalf("Expected approval to make it public, got %+v %v", m, err)
+		}
+		if err := m.moderate(Moderation{Approved: true, At: now}); !errors.Is(err, ErrNotModerated) {
+			t.Errorf("Expected ErrNotModerated approving twice, got %v", err)
+		}
+
+		m.setPublication(Publication{Status: StatusUnpublished})
+		m.setPublication(Publication{Status: StatusPublished})
+		if !m.Public || m.Moderation != ModerationApproved {
+			t.Errorf("Expected republishing to need no review, got %+v", m)
+		}
+	})
+
+	t.Run("Rejection takes an item out of publication", func(t *testing.T) {
+		s := Sequence{}
+		publishAt := now.Add(time.Hour)
+		s.setPublication(Publication{Status: StatusScheduled, PublishAt: &publishAt})
+		if err := s.moderate(Moderation{Reason: "spam", At: now}); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Status != StatusDraft || s.PublishAt != nil || s.Moderation != ModerationRejected || s.ModerationNote != "spam" {
+			t.Errorf("Expected a rejected draft, got %+v", s)
+		}
+		if err := s.moderate(Moderation{Approved: true, At: now}); !errors.Is(err, ErrNotModerated) {
+			t.Errorf("Expected ErrNotModerated for a draft, got %v", err)
+		}
+
+		// publishing again resubmits it
+		s.setPublication(Publication{Status: StatusPublished})
+		if s.Public || s.Moderation != ModerationPending {
+			t.Errorf("Expected it pending again, got %+v", s)
+		}
+
+		// and an approved item can still be taken down
+		s.moderate(Moderation{Approved: true, At: now})
+		if err := s.moderate(Moderation{Reason: "plagiarised", At: now}); err != nil || s.Public || s.Status != StatusUnpublished {
+			t.Errorf("Expected it taken down, got %+v %v", s, err)
+		}
+	})
+
+	t.Run("Hiding a public item sends it back for review", func(t *testing.T) {
+		m := Meditation{Public: true}
+		m.setPublication(Publication{Status: StatusPublished})
+		if !m.hide() || m.Public || !awaitingReview(m.Status, m.Moderation) {
+			t.Fatalf("Expected it hidden and awaiting review, got %+v", m)
+		}
+		if m.hide() {
+			t.Error("Expected nothing to hide the second time")
+		}
+		if err := m.moderate(Moderation{Approved: true, At: now}); err != nil || !m.Public {
+			t.Errorf("Expected approval to show it again, got %+v %v", m, err)
+		}
+	})
+
+	t.Run("Editing an approved item sends it back for review", func(t *testing.T) {
+		member := Principal{UserId: "alex", Roles: []string{RoleMember}}
+		curator := Principal{UserId: "alex", Roles: []string{RoleCurator, RoleMember}}
+
+		m := Meditation{Name: "Prayer", Tags: []string{"evagrius"}}
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: now})
+		before := m
+		m.Tags = []string{"evagrius"}
+		m.reviewEdits(member, before)
+		if !m.Public || m.Moderation != ModerationApproved {
+			t.Fatalf("Expected an unchanged item to stay approved, got %+v", m)
+		}
+		m.Text = "Pray without ceasing."
+		m.reviewEdits(curator, before)
+		if !m.Public {
+			t.Fatalf("Expected a curator's edit to stand, got %+v", m)
+		}
+		m.reviewEdits(member, before)
+		if m.Public || !awaitingReview(m.Status, m.Moderation) {
+			t.Errorf("Expected the edit to await review, got %+v", m)
+		}
+
+		s := Sequence{Steps: []SequenceStep{{Type: StepBell, Duration: BELL_SECONDS}}}
+		s.setPublication(Publication{Status: StatusPublished})
+		s.moderate(Moderation{Approved: true, At: now})
+		before2 := s
+		s.Steps = []SequenceStep{{Type: StepBell}}
+		s.reviewEdits(member, before2)
+		if !s.Public {
+			t.Fatalf("Expected computed durations not to count as an edit, got %+v", s)
+		}
+		s.Steps = append(s.Steps, SequenceStep{Type: StepText, Text: "Amen"})
+		s.reviewEdits(member, before2)
+		if s.Public || s.Moderation != ModerationPending {
+			t.Errorf("Expected a new step to await review, got %+v", s)
+		}
+	})
+
+	t.Run("Taking an item down rejects it", func(t *testing.T) {
+		m := Meditation{}
+		m.setPublication(Publication{Status: StatusPublished})
+		m.moderate(Moderation{Approved: true, At: now})
+		m.takeDown(now)
+		if m.Public || m.Moderation != ModerationRejected || m.Status != StatusUnpublished {
+			t.Errorf("Expected it rejected and unpublished, got %+v", m)
+		}
+		m.setPublication(Publication{Status: StatusPublished})
+		if m.Public || !awaitingReview(m.Status, m.Moderation) {
+			t.Errorf("Expected republishing to await review, got %+v", m)
+		}
+	})
+
+	t.Run("Items public before moderation count as approved", func(t *testing.T) {
+		if moderationState("", true) != ModerationApproved || moderationState("", false) != "" {
+			t.Error("Unexpected moderation for a legacy item")
+		}
+	})
+}
@@ -49,7 +49,9 @@ func isDurationValid(duration int64) bool {
 	return true
 }
 
-func ValidateAudio(uploadKey string, config *aws.Config) (string, error) {
+// ValidateAudio checks the upload is an mp3 or m4a of an acceptable length,
+// returning its file extension and duration in seconds.
+func ValidateAudio(uploadKey string, config *aws.Config) (string, int64, error) {
 	// AWS-ey stuff
 	sess, _ := session.NewSession(config)
 	svc := s3.New(sess)
@@ -62,12 +64,11 @@ func ValidateAudio(uploadKey string, config *aws.Config) (string, error) {
 	}
 	contentTypeResponse, err := svc.HeadObject(contentTypeRequest)
 	if err != nil {
-		return "", err
+		return "", -1, err
 	}
-	isMp3 := *contentTypeResponse.ContentType == "audio/mpeg"
-	isMp4 := *contentTypeResponse.ContentType == "audio/mp4"
-	if !isMp3 && !isMp4 {
-		return "", errors.New("file is not an mp4 or mp3")
+	contentType := *contentTypeResponse.ContentType
+	if contentType != "audio/mpeg" && contentType != "audio/mp4" {
+		return "", -1, errors.New("file is not an mp4 or mp3")
 	}
 
 	// Read the audio file into memory from s3
@@ -77,13 +78,33 @@ func ValidateAudio(uploadKey string, config *aws.Config) (string, error) {
 		Key:    &uploadKey,
 	})
 	if err != nil {
-		return "", err
+		return "", -1, err
+	}
+
+	return validateAudioContent(contentType, audioBuffer.Bytes())
+}
+
+// audioContentTypes are the content types of the audio files we accept, by
+// file extension.
+var audioContentTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".m4a": "audio/mp4",
+}
+
+// validateAudioContent applies ValidateAudio's rules to audio already in
+// memory.
+func validateAudioContent(contentType string, audio []byte) (string, int64, error) {
+	isMp3 := contentType == "audio/mpeg"
+	isMp4 := contentType == "audio/mp4"
+	if !isMp3 && !isMp4 {
+		return "", -1, errors.New("file is not an mp4 or mp3")
 	}
 
 	// get the duration in seconds of the m4a or mp3
-	reader := bytes.NewReader(audioBuffer.Bytes())
+	reader := bytes.NewReader(audio)
 	dur := int64(-1)
 	fileExt := ""
+	var err error
 	if isMp3 {
 		fileExt = ".mp3"
 		dur, err = mp3duration(reader)
@@ -92,17 +113,17 @@ func ValidateAudio(uploadKey string, config *aws.Config) (string, error) {
 		dur, err = m4aduration(reader)
 	}
 	if err != nil {
-		return "", err
+		return "", -1, err
 	}
 
 	// confirm the duration is between 0 and 90 s
 	isValid := isDurationValid(dur)
 	if !isValid {
-		return "", errors.New("duration of the mp3 is not valid (must be less than 1 minute)")
+		return "", -1, errors.New("duration of the mp3 is not valid (must be less than 1 minute)")
 	}
 
 	// return nil error for happy path
-	return fileExt, nil
+	return fileExt, dur, nil
 }
 
 func ValidateImage(uploadKey string, config *aws.Config) (string, error) {
@@ -120,7 +141,12 @@ func ValidateImage(uploadKey string, config *aws.Config) (string, error) {
 		return "", err
 	}
 
-	contentType := *resp.ContentType
+	return imageExtension(*resp.ContentType)
+}
+
+// imageExtension is the file extension for an image of `contentType`, if it
+// is one we accept.
+func imageExtension(contentType string) (string, error) {
 	switch contentType {
 	case "image/jpeg":
 		return ".jpg", nil
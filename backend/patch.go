@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var ErrUnsupportedPatchType = errors.New("PATCH requires a Content-Type of " + mergePatchContentType + " or " + jsonPatchContentType)
+
+// applyPatch applies a PATCH request body to the JSON document `doc`, using
+// the patch format named by `contentType`.
+func applyPatch(contentType string, doc []byte, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedPatchType
+	}
+	switch mediaType {
+	case mergePatchContentType:
+		return applyMergePatch(doc, patch)
+	case jsonPatchContentType:
+		return applyJSONPatch(doc, patch)
+	}
+	return nil, ErrUnsupportedPatchType
+}
+
+// applyMergePatch implements JSON Merge Patch (RFC 7386).
+func applyMergePatch(doc []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch implements JSON Patch (RFC 6902). The operations are applied
+// in order and the whole patch fails if any one of them does.
+func applyJSONPatch(doc []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("invalid json patch: %w", err)
+	}
+
+	for i, op := range ops {
+		if op.Path == nil {
+			return nil, fmt.Errorf("operation %d: missing path", i)
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		var value interface{}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: missing value", i)
+			}
+			if err := json.Unmarshal(*op.Value, &value); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("operation %d: missing from", i)
+			}
+			from, err := parsePointer(*op.From)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			if op.Op == "move" && isProperPrefix(from, path) {
+				return nil, fmt.Errorf("operation %d: cannot move a value into one of its children", i)
+			}
+			value, err = pointerGet(target, from)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			if op.Op == "move" {
+				target, err = pointerRemove(target, from)
+			} else {
+				value, err = deepCopy(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+
+		switch op.Op {
+		case "add", "move", "copy":
+			target, err = pointerAdd(target, path, value)
+		case "remove":
+			target, err = pointerRemove(target, path)
+		case "replace":
+			target, err = pointerRemove(target, path)
+			if err == nil {
+				target, err = pointerAdd(target, path, value)
+			}
+		case "test":
+			var current interface{}
+			current, err = pointerGet(target, path)
+			if err == nil && !reflect.DeepEqual(current, value) {
+				err = errors.New("test failed at " + *op.Path)
+			}
+		default:
+			err = errors.New("unknown op \"" + op.Op + "\"")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("invalid JSON pointer \"" + pointer + "\"")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		t = strings.ReplaceAll(t, "~1", "/")
+		tokens[i] = strings.ReplaceAll(t, "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isProperPrefix(prefix []string, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return -1, errors.New("invalid array index \"" + token + "\"")
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return -1, errors.New("invalid array index \"" + token + "\"")
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if idx > max {
+		return -1, errors.New("array index " + token + " out of range")
+	}
+	return idx, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, errors.New("no value at \"" + token + "\"")
+			}
+			current = v
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, errors.New("no value at \"" + token + "\"")
+		}
+	}
+	return current, nil
+}
+
+// pointerAdd returns doc with value added at path. Slices may be reallocated,
+// so callers must use the returned document.
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, errors.New("no value at \"" + token + "\"")
+		}
+		updated, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+	case []interface{}:
+		if len(path) == 1 {
+			idx, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		idx, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerAdd(node[idx], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = updated
+		return node, nil
+	}
+	return nil, errors.New("no value at \"" + token + "\"")
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, errors.New("no value at \"" + token + "\"")
+		}
+		if len(path) == 1 {
+			delete(node, token)
+			return node, nil
+		}
+		updated, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 1 {
+			return append(node[:idx:idx], node[idx+1:]...), nil
+		}
+		updated, err := pointerRemove(node[idx], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = updated
+		return node, nil
+	}
+	return nil, errors.New("no value at \"" + token + "\"")
+}
+
+// strictUnmarshal is json.Unmarshal, but rejects fields `v` doesn't have so
+// that a patch to a misspelled field isn't silently dropped.
+func strictUnmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func assertJSONEqual(t *testing.T, expected string, actual []byte) {
+	t.Helper()
+	var e, a interface{}
+	json.Unmarshal([]byte(expected), &e)
+	json.Unmarshal(actual, &a)
+	if !reflect.DeepEqual(e, a) {
+		t.Errorf("Expected %s, got %s", expected, string(actual))
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	doc := `{"name":"Evagrius","text":"Prayer is...","isPublic":false,"meditationIds":["1","2"]}`
+
+	t.Run("Change a single field", func(t *testing.T) {
+		patched, err := applyPatch("application/merge-patch+json", []byte(doc), []byte(`{"isPublic":true}`))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		assertJSONEqual(t, `{"name":"Evagrius","text":"Prayer is...","isPublic":true,"meditationIds":["1","2"]}`, patched)
+	})
+
+	t.Run("Null removes a field and arrays are replaced", func(t *testing.T) {
+		patched, err := applyPatch("application/merge-patch+json; charset=utf-8", []byte(doc), []byte(`{"text":null,"meditationIds":["3"]}`))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		assertJSONEqual(t, `{"name":"Evagrius","isPublic":false,"meditationIds":["3"]}`, patched)
+	})
+
+	t.Run("Plain JSON is not a patch format", func(t *testing.T) {
+		_, err := applyPatch("application/json", []byte(doc), []byte(`{}`))
+		if err != ErrUnsupportedPatchType {
+			t.Errorf("Expected ErrUnsupportedPatchType, got %v", err)
+		}
+	})
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"name":"Lectio","isPublic":false,"meditationIds":["a","b","c","d"]}`
+
+	cases := []struct {
+		name     string
+		patch    string
+		expected string
+	}{
+		{
+			"move one meditation to the front",
+			`[{"op":"move","from":"/meditationIds/3","path":"/meditationIds/0"}]`,
+			`{"name":"Lectio","isPublic":false,"meditationIds":["d","a","b","c"]}`,
+		},
+		{
+			"move one meditation to the end",
+			`[{"op":"move","from":"/meditationIds/0","path":"/meditationIds/-"}]`,
+			`{"name":"Lectio","isPublic":false,"meditationIds":["b","c","d","a"]}`,
+		},
+		{
+			"insert, remove and replace",
+			`[{"op":"add","path":"/meditationIds/1","value":"x"},{"op":"remove","path":"/meditationIds/0"},{"op":"replace","path":"/isPublic","value":true}]`,
+			`{"name":"Lectio","isPublic":true,"meditationIds":["x","b","c","d"]}`,
+		},
+		{
+			"copy and test",
+			`[{"op":"test","path":"/name","value":"Lectio"},{"op":"copy","from":"/meditationIds/2","path":"/meditationIds/0"}]`,
+			`{"name":"Lectio","isPublic":false,"meditationIds":["c","a","b","c","d"]}`,
+		},
+		{
+			"escaped pointer tokens",
+			`[{"op":"add","path":"/a~1b~0c","value":1}]`,
+			`{"name":"Lectio","isPublic":false,"meditationIds":["a","b","c","d"],"a/b~c":1}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patched, err := applyPatch("application/json-patch+json", []byte(doc), []byte(c.patch))
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			assertJSONEqual(t, c.expected, patched)
+		})
+	}
+
+	failures := []struct {
+		name  string
+		patch string
+	}{
+		{"failed test", `[{"op":"test","path":"/name","value":"Other"}]`},
+		{"index out of range", `[{"op":"remove","path":"/meditationIds/4"}]`},
+		{"leading zero index", `[{"op":"remove","path":"/meditationIds/01"}]`},
+		{"missing parent", `[{"op":"add","path":"/missing/child","value":1}]`},
+		{"replace missing value", `[{"op":"replace","path":"/missing","value":1}]`},
+		{"unknown op", `[{"op":"frobnicate","path":"/name"}]`},
+		{"move into own child", `[{"op":"move","from":"/meditationIds","path":"/meditationIds/0"}]`},
+	}
+	for _, c := range failures {
+		t.Run("rejects "+c.name, func(t *testing.T) {
+			_, err := applyPatch("application/json-patch+json", []byte(doc), []byte(c.patch))
+			if err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
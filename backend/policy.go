@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Who may do what. Every signed in user is a member, who can only manage
+// their own meditations, sequences and sessions. The identity provider can
+// grant more through the JWT:
+//   - curators' publications are approved without waiting for moderation
+//   - moderators work the moderation queue and the reports
+//   - admins can do all of that, and edit or take down anyone's items
+// Roles are read from the claims named by $ROLES_CLAIM and
+// $PERMISSIONS_CLAIM. $ROLE_MAP maps values of either, such as Auth0
+// permissions, onto roles, e.g. "moderate:content=moderator,admin:all=admin".
+
+const (
+	RoleMember    = "member"
+	RoleCurator   = "curator"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// ROLES_CLAIM and PERMISSIONS_CLAIM are the claims roles are read from
+// unless $ROLES_CLAIM and $PERMISSIONS_CLAIM name others.
+const ROLES_CLAIM = "roles"
+const PERMISSIONS_CLAIM = "permissions"
+
+// impliedRoles lists the roles each role includes.
+var impliedRoles = map[string][]string{
+	RoleMember:    {},
+	RoleCurator:   {RoleMember},
+	RoleModerator: {RoleMember},
+	RoleAdmin:     {RoleCurator, RoleModerator, RoleMember},
+}
+
+// Principal is the caller of an authenticated endpoint.
+type Principal struct {
+	UserId string
+	Roles  []string
+}
+
+// callerPrincipal reads the caller from their JWT, failing if it has no
+// `sub`.
+func callerPrincipal(req events.APIGatewayV2HTTPRequest) (Principal, bool) {
+	claims := req.RequestContext.Authorizer.JWT.Claims
+	userId, ok := claims["sub"]
+	if !ok {
+		return Principal{}, false
+	}
+	return Principal{UserId: userId, Roles: claimedRoles(claims)}, true
+}
+
+// claimedRoles maps `claims` onto roles, adding the ones they imply.
+func claimedRoles(claims map[string]string) []string {
+	roleMap := parseRoleMap(os.Getenv("ROLE_MAP"))
+	roles := []string{}
+	var add func(role string)
+	add = func(role string) {
+		if containsString(roles, role) {
+			return
+		}
+		roles = append(roles, role)
+		for _, implied := range impliedRoles[role] {
+			add(implied)
+		}
+	}
+
+	add(RoleMember)
+	for _, value := range claimValues(claims[claimName("ROLES_CLAIM", ROLES_CLAIM)]) {
+		if role, ok := roleMap[value]; ok {
+			add(role)
+		} else if _, ok := impliedRoles[value]; ok {
+			add(value)
+		}
+	}
+	for _, value := range claimValues(claims[claimName("PERMISSIONS_CLAIM", PERMISSIONS_CLAIM)]) {
+		if role, ok := roleMap[value]; ok {
+			add(role)
+		}
+	}
+	return roles
+}
+
+func claimName(env string, fallback string) string {
+	if name := os.Getenv(env); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// claimValues splits a list claim. The HTTP API passes one on as "[a b]",
+// but a JSON array or a comma separated list work too.
+func claimValues(claim string) []string {
+	claim = strings.TrimSpace(claim)
+	if claim == "" {
+		return []string{}
+	}
+	values := []string{}
+	if json.Unmarshal([]byte(claim), &values) == nil {
+		return values
+	}
+	claim = strings.TrimSuffix(strings.TrimPrefix(claim, "["), "]")
+	return strings.FieldsFunc(claim, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// parseRoleMap parses "value=role,..." pairs, skipping any naming an unknown
+// role.
+func parseRoleMap(spec string) map[string]string {
+	roleMap := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, role := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if _, ok := impliedRoles[role]; ok && value != "" {
+			roleMap[value] = role
+		}
+	}
+	return roleMap
+}
+
+func (p Principal) has(role string) bool {
+	return containsString(p.Roles, role)
+}
+
+// canEdit reports whether the caller may change or delete an item
+// belonging to `ownerId`.
+func (p Principal) canEdit(ownerId string) bool {
+	return p.UserId == ownerId || p.has(RoleAdmin)
+}
+
+func (p Principal) canModerate() bool {
+	return p.has(RoleModerator)
+}
+
+// skipsReview reports whether what the caller publishes needs no moderation.
+func (p Principal) skipsReview() bool {
+	return p.has(RoleCurator)
+}
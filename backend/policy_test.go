@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func requestWithClaims(claims map[string]string) events.APIGatewayV2HTTPRequest {
+	req := events.APIGatewayV2HTTPRequest{}
+	req.RequestContext.Authorizer = &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+		JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+			Claims: claims,
+		},
+	}
+	return req
+}
+
+func TestPolicy(t *testing.T) {
+	t.Run("Roles are read from the JWT", func(t *testing.T) {
+		for _, claim := range []string{"[member moderator]", `["member","moderator"]`, "member,moderator"} {
+			caller, ok := callerPrincipal(requestWithClaims(map[string]string{"sub": "alex", "roles": claim}))
+			if !ok || !caller.canModerate() || caller.has(RoleAdmin) || caller.skipsReview() {
+				t.Errorf("Unexpected roles %v for claim %q", caller.Roles, claim)
+			}
+		}
+		if _, ok := callerPrincipal(requestWithClaims(map[string]string{})); ok {
+			t.Error("Expected no caller without a sub")
+		}
+	})
+
+	t.Run("Everyone is a member and admins are everything", func(t *testing.T) {
+		caller, _ := callerPrincipal(requestWithClaims(map[string]string{"sub": "alex", "roles": "[superuser]"}))
+		if len(caller.Roles) != 1 || !caller.has(RoleMember) {
+			t.Errorf("Expected only member, got %v", caller.Roles)
+		}
+		admin, _ := callerPrincipal(requestWithClaims(map[string]string{"sub": "sam", "roles": "[admin]"}))
+		if !admin.canModerate() || !admin.skipsReview() || !admin.has(RoleMember) {
+			t.Errorf("Expected admin to imply every role, got %v", admin.Roles)
+		}
+	})
+
+	t.Run("ROLE_MAP maps permissions onto roles", func(t *testing.T) {
+		os.Setenv("ROLE_MAP", "publish:unreviewed=curator, moderate:content=moderator,bogus=wizard")
+		defer os.Unsetenv("ROLE_MAP")
+		caller, _ := callerPrincipal(requestWithClaims(map[string]string{"sub": "alex", "permissions": "[publish:unreviewed bogus]"}))
+		if !caller.skipsReview() || caller.canModerate() || len(caller.Roles) != 2 {
+			t.Errorf("Unexpected roles %v", caller.Roles)
+		}
+	})
+
+	t.Run("Only owners and admins can edit", func(t *testing.T) {
+		member := Principal{UserId: "alex", Roles: []string{RoleMember}}
+		moderator := Principal{UserId: "jo", Roles: []string{RoleModerator, RoleMember}}
+		admin := Principal{UserId: "sam", Roles: []string{RoleAdmin, RoleCurator, RoleModerator, RoleMember}}
+		if !member.canEdit("alex") || member.canEdit("sam") || moderator.canEdit("alex") || !admin.canEdit("alex") {
+			t.Error("Unexpected edit permissions")
+		}
+	})
+
+	t.Run("Curators publish without review", func(t *testing.T) {
+		now := time.Now()
+		curator := Principal{UserId: "alex", Roles: []string{RoleCurator, RoleMember}}
+		m := Meditation{UserId: "alex"}
+		m.publishAs(curator, Publication{Status: StatusPublished}, now)
+		if !m.Public || m.Moderation != ModerationApproved {
+			t.Errorf("Expected it approved, got %+v", m)
+		}
+
+		// but not past reports that hid it
+		m.hide()
+		m.publishAs(curator, Publication{Status: StatusPublished}, now)
+		if m.Public {
+			t.Errorf("Expected it still hidden, got %+v", m)
+		}
+
+		member := Principal{UserId: "jo", Roles: []string{RoleMember}}
+		s := Sequence{UserId: "jo"}
+		s.publishAs(member, Publication{Status: StatusPublished}, now)
+		if s.Public || s.Moderation != ModerationPending {
+			t.Errorf("Expected it pending, got %+v", s)
+		}
+	})
+
+	t.Run("Signed in reporters are known by their user id", func(t *testing.T) {
+		anonymous := events.APIGatewayV2HTTPRequest{}
+		anonymous.RequestContext.HTTP.SourceIP = "10.0.0.1"
+		elsewhere := anonymous
+		elsewhere.RequestContext.HTTP.SourceIP = "10.0.0.2"
+		if reporterId(anonymous) == reporterId(elsewhere) {
+			t.Error("Expected addresses told apart")
+		}
+
+		alex := requestWithClaims(map[string]string{"sub": "alex"})
+		alex.RequestContext.HTTP.SourceIP = "10.0.0.1"
+		alexElsewhere := requestWithClaims(map[string]string{"sub": "alex"})
+		alexElsewhere.RequestContext.HTTP.SourceIP = "10.0.0.2"
+		if reporterId(alex) != reporterId(alexElsewhere) || reporterId(alex) == reporterId(anonymous) {
+			t.Error("Expected a signed in reporter known by their user id")
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Meditations and sequences have a publication status. Only published ones,
+// once approved (see moderation.go), are public (`isPublic`, and pppk
+// "public" or "public-seq"), so the public endpoints and indexes never see
+// the rest. Scheduled ones wait under pppk "scheduled" until
+// PublishScheduledHandler publishes them at `publishAt`.
+//
+//	draft ───────► scheduled ───► published ◄──► unpublished
+//	  │  ◄────────     │              ▲               │
+//	  └────────────────┴──────────────┘  scheduled ◄──┘
+
+const (
+	StatusDraft       = "draft"
+	StatusScheduled   = "scheduled"
+	StatusPublished   = "published"
+	StatusUnpublished = "unpublished"
+)
+
+// scheduledPppk is the gs3 partition scheduled items wait in.
+const scheduledPppk = "scheduled"
+
+var ErrInvalidPublication = errors.New("invalid publication change")
+
+// publicationTransitions lists the statuses each status can move to, besides
+// staying put.
+var publicationTransitions = map[string][]string{
+	StatusDraft:       {StatusScheduled, StatusPublished},
+	StatusScheduled:   {StatusDraft, StatusPublished},
+	StatusPublished:   {StatusUnpublished},
+	StatusUnpublished: {StatusScheduled, StatusPublished},
+}
+
+type Publication struct {
+	Status    string
+	PublishAt *time.Time
+}
+
+// publicationStatus fills in the status of items saved before statuses
+// existed, which were only public or not.
+func publicationStatus(status string, public bool) string {
+	if status != "" {
+		return status
+	}
+	if public {
+		return StatusPublished
+	}
+	return StatusDraft
+}
+
+// nextPublication works out the publication an update asks for. `status` is
+// the one requested, if any. Without one, a `publishAt` schedules the item
+// and otherwise `isPublic` publishes or unpublishes it, as it did before
+// statuses existed.
+func nextPublication(current Publication, status string, publishAt *time.Time, isPublic bool, now time.Time) (Publication, error) {
+	if status == "" {
+		switch {
+		case publishAt != nil:
+			status = StatusScheduled
+		case isPublic:
+			status = StatusPublished
+		case current.Status == StatusPublished:
+			status = StatusUnpublished
+		default:
+			status = current.Status
+		}
+	}
+	if status != current.Status && !containsString(publicationTransitions[current.Status], status) {
+		return Publication{}, fmt.Errorf("%w: a %s item can't be made %s", ErrInvalidPublication, current.Status, status)
+	}
+
+	if status != StatusScheduled {
+		if publishAt != nil {
+			return Publication{}, fmt.Errorf("%w: publishAt is only for scheduled items", ErrInvalidPublication)
+		}
+		return Publication{Status: status}, nil
+	}
+	if publishAt == nil {
+		if current.Status != StatusScheduled {
+			return Publication{}, fmt.Errorf("%w: scheduling needs a publishAt", ErrInvalidPublication)
+		}
+		return current, nil
+	}
+	if current.Status == StatusScheduled && current.PublishAt != nil && publishAt.Equal(*current.PublishAt) {
+		return current, nil
+	}
+	if !publishAt.After(now) {
+		return Publication{}, fmt.Errorf("%w: publishAt must be in the future", ErrInvalidPublication)
+	}
+	at := publishAt.UTC()
+	return Publication{Status: StatusScheduled, PublishAt: &at}, nil
+}
+
+// withPublic is the publication an update that only knows about isPublic
+// asks for, like a legacy import. It can't fail.
+func withPublic(current Publication, isPublic bool, now time.Time) Publication {
+	publication, _ := nextPublication(current, "", nil, isPublic, now)
+	return publication
+}
+
+// patchedPublication drops the status and publishAt a PATCH left as they
+// were, so whatever it did change (say isPublic) decides.
+func patchedPublication(current Publication, status string, publishAt *time.Time) (string, *time.Time) {
+	if status == current.Status {
+		status = ""
+	}
+	if publishAt != nil && current.PublishAt != nil && publishAt.Equal(*current.PublishAt) {
+		publishAt = nil
+	}
+	return status, publishAt
+}
+
+// isDue reports whether a scheduled item should be published by now.
+func (p Publication) isDue(now time.Time) bool {
+	return p.Status == StatusScheduled && p.PublishAt != nil && !p.PublishAt.After(now)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// waitingPppk is the gs3 partition of an item that isn't public but is
+// waiting to be, if any.
+func waitingPppk(status string, moderation string) string {
+	if status == StatusScheduled {
+		return scheduledPppk
+	}
+	if awaitingReview(status, moderation) {
+		return reviewPppk
+	}
+	return ""
+}
+
+func (m Meditation) publication() Publication {
+	return Publication{Status: publicationStatus(m.Status, m.Public), PublishAt: m.PublishAt}
+}
+
+// setPublication also submits the meditation for moderation if need be.
+func (m *Meditation) setPublication(p Publication) {
+	m.Status, m.PublishAt = p.Status, p.PublishAt
+	m.Moderation = nextModeration(moderationState(m.Moderation, m.Public), p.Status)
+	m.Public = isVisible(m.Status, m.Moderation)
+}
+
+func (s Sequence) publication() Publication {
+	return Publication{Status: publicationStatus(s.Status, s.Public), PublishAt: s.PublishAt}
+}
+
+func (s *Sequence) setPublication(p Publication) {
+	s.Status, s.PublishAt = p.Status, p.PublishAt
+	s.Moderation = nextModeration(moderationState(s.Moderation, s.Public), p.Status)
+	s.Public = isVisible(s.Status, s.Moderation)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublication(t *testing.T) {
+	now := time.Date(2027, 2, 10, 12, 0, 0, 0, time.UTC)
+	ashWednesday := time.Date(2027, 2, 10, 5, 0, 0, 0, time.FixedZone("EST", -5*3600)).Add(24 * time.Hour)
+	draft := Publication{Status: StatusDraft}
+	published := Publication{Status: StatusPublished}
+
+	t.Run("isPublic alone publishes and unpublishes", func(t *testing.T) {
+		p, err := nextPublication(draft, "", nil, true, now)
+		if err != nil || p.Status != StatusPublished {
+			t.Errorf("Expected published, got %+v %v", p, err)
+		}
+		p, err = nextPublication(published, "", nil, false, now)
+		if err != nil || p.Status != StatusUnpublished {
+			t.Errorf("Expected unpublished, got %+v %v", p, err)
+		}
+		p, err = nextPublication(draft, "", nil, false, now)
+		if err != nil || p.Status != StatusDraft {
+			t.Errorf("Expected a draft to stay a draft, got %+v %v", p, err)
+		}
+	})
+
+	t.Run("Scheduling needs a time in the future", func(t *testing.T) {
+		p, err := nextPublication(draft, StatusScheduled, &ashWednesday, false, now)
+		if err != nil || p.Status != StatusScheduled || !p.PublishAt.Equal(ashWednesday) || p.PublishAt.Location() != time.UTC {
+			t.Errorf("Expected it scheduled for %v, got %+v %v", ashWednesday, p, err)
+		}
+		if p.isDue(now) || !p.isDue(ashWednesday) {
+			t.Errorf("Expected it due at %v only", ashWednesday)
+		}
+
+		// a publishAt alone schedules, and an update that leaves it alone
+		// keeps the schedule
+		scheduled, _ := nextPublication(draft, "", &ashWednesday, false, now)
+		if scheduled.Status != StatusScheduled {
+			t.Errorf("Expected publishAt to schedule, got %+v", scheduled)
+		}
+		p, err = nextPublication(scheduled, "", nil, false, now)
+		if err != nil || p != scheduled {
+			t.Errorf("Expected the schedule to be kept, got %+v %v", p, err)
+		}
+
+		past := now.Add(-time.Minute)
+		_, err = nextPublication(draft, StatusScheduled, &past, false, now)
+		if !errors.Is(err, ErrInvalidPublication) {
+			t.Errorf("Expected a past publishAt to be rejected, got %v", err)
+		}
+		_, err = nextPublication(draft, StatusScheduled, nil, false, now)
+		if !errors.Is(err, ErrInvalidPublication) {
+			t.Errorf("Expected a missing publishAt to be rejected, got %v", err)
+		}
+		_, err = nextPublication(draft, StatusPublished, &ashWednesday, false, now)
+		if !errors.Is(err, ErrInvalidPublication) {
+			t.Errorf("Expected publishAt without scheduling to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("Only the allowed transitions are made", func(t *testing.T) {
+		_, err := nextPublication(published, StatusDraft, nil, false, now)
+		if !errors.Is(err, ErrInvalidPublication) {
+			t.Errorf("Expected published -> draft to be rejected, got %v", err)
+		}
+		_, err = nextPublication(draft, StatusUnpublished, nil, false, now)
+		if !errors.Is(err, ErrInvalidPublication) {
+			t.Errorf("Expected draft -> unpublished to be rejected, got %v", err)
+		}
+		p, err := nextPublication(Publication{Status: StatusUnpublished}, StatusPublished, nil, false, now)
+		if err != nil || p.Status != StatusPublished {
+			t.Errorf("Expected unpublished -> published, got %+v %v", p, err)
+		}
+	})
+
+	t.Run("Items from before statuses read as published or draft", func(t *testing.T) {
+		if (Meditation{Public: true}).publication().Status != StatusPublished || (Sequence{}).publication().Status != StatusDraft {
+			t.Error("Unexpected status for a legacy item")
+		}
+		m := Meditation{}
+		m.setPublication(Publication{Status: StatusPublished})
+		if m.Public || mapMeditationToMeditationRecord(m).Pppk != reviewPppk {
+			t.Errorf("Expected a published meditation to await review, got %+v", m)
+		}
+		s := Sequence{}
+		s.setPublication(Publication{Status: StatusScheduled, PublishAt: &ashWednesday})
+		if s.Public || mapSequenceToSequenceRecord(s).Pppk != scheduledPppk {
+			t.Errorf("Expected a scheduled sequence to wait under %s, got %+v", scheduledPppk, s)
+		}
+	})
+
+	t.Run("A patch that leaves the status alone lets isPublic decide", func(t *testing.T) {
+		scheduled := Publication{Status: StatusScheduled, PublishAt: &ashWednesday}
+		status, publishAt := patchedPublication(scheduled, StatusScheduled, &ashWednesday)
+		if status != "" || publishAt != nil {
+			t.Errorf("Expected nothing requested, got %q %v", status, publishAt)
+		}
+		p, _ := nextPublication(scheduled, status, publishAt, true, now)
+		if p.Status != StatusPublished {
+			t.Errorf("Expected isPublic to publish now, got %+v", p)
+		}
+	})
+}
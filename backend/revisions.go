@@ -0,0 +1,197 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// MAX_DIFF_CELLS bounds the work diffWords does; texts too different to
+// diff within it are shown as wholly replaced.
+const MAX_DIFF_CELLS = 4000000
+
+// Revision is an earlier version of a meditation or sequence, see
+// ddb_revisions.go.
+type Revision struct {
+	Version    int64       `json:"version"`
+	SavedAt    time.Time   `json:"savedAt"`    // when this version was saved
+	ReplacedAt time.Time   `json:"replacedAt"` // when an update replaced it
+	Meditation *Meditation `json:"meditation,omitempty"`
+	Sequence   *Sequence   `json:"sequence,omitempty"`
+
+	// in a list, the fields the update that replaced it changed
+	Changed []string `json:"changed,omitempty"`
+	// on its own, how the current version differs from it
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange is a field that differs between two versions, named as in the
+// item's JSON.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+	// for text, which words were kept, removed and added
+	Diff []DiffChunk `json:"diff,omitempty"`
+}
+
+const (
+	DiffKept    = "="
+	DiffRemoved = "-"
+	DiffAdded   = "+"
+)
+
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type changeList []FieldChange
+
+func (c *changeList) value(field string, before interface{}, after interface{}) {
+	if !reflect.DeepEqual(before, after) {
+		*c = append(*c, FieldChange{Field: field, Before: before, After: after})
+	}
+}
+
+// strings counts nil and empty lists as the same.
+func (c *changeList) strings(field string, before []string, after []string) {
+	if len(before) == 0 && len(after) == 0 {
+		return
+	}
+	c.value(field, before, after)
+}
+
+func (c *changeList) text(field string, before string, after string) {
+	if before != after {
+		*c = append(*c, FieldChange{Field: field, Before: before, After: after, Diff: diffWords(before, after)})
+	}
+}
+
+// meditationChanges lists the fields a user can edit that differ between
+// `before` and `after`.
+func meditationChanges(before Meditation, after Meditation) []FieldChange {
+	changes := changeList{}
+	changes.text("name", before.Name, after.Name)
+	changes.text("text", before.Text, after.Text)
+	changes.value("audioUrl", before.URL, after.URL)
+	changes.value("durationSeconds", before.Duration, after.Duration)
+	changes.value("isPublic", before.Public, after.Public)
+	changes.value("status", before.Status, after.Status)
+	changes.value("publishAt", before.PublishAt, after.PublishAt)
+	changes.strings("tags", before.Tags, after.Tags)
+	changes.strings("categories", before.Categories, after.Categories)
+	changes.value("citation", before.Citation, after.Citation)
+	return changes
+}
+
+// sequenceChanges is meditationChanges for sequences.
+func sequenceChanges(before Sequence, after Sequence) []FieldChange {
+	changes := changeList{}
+	changes.text("name", before.Name, after.Name)
+	changes.text("description", before.Description, after.Description)
+	changes.value("imageUrl", before.ImageURL, after.ImageURL)
+	changes.value("isPublic", before.Public, after.Public)
+	changes.value("status", before.Status, after.Status)
+	changes.value("publishAt", before.PublishAt, after.PublishAt)
+	changes.value("steps", untimedSteps(before.Steps), untimedSteps(after.Steps))
+	changes.strings("tags", before.Tags, after.Tags)
+	changes.strings("categories", before.Categories, after.Categories)
+	return changes
+}
+
+// untimedSteps drops the durations computed on read, which depend on the
+// meditations rather than the sequence.
+func untimedSteps(steps []SequenceStep) []SequenceStep {
+	if len(steps) == 0 {
+		return nil
+	}
+	untimed := make([]SequenceStep, len(steps))
+	for i, step := range steps {
+		step.Duration = 0
+		untimed[i] = step
+	}
+	return untimed
+}
+
+func changedFields(changes []FieldChange) []string {
+	fields := make([]string, len(changes))
+	for i, change := range changes {
+		fields[i] = change.Field
+	}
+	return fields
+}
+
+var diffTokenPattern = regexp.MustCompile(`\s+|\S+`)
+
+// diffWords diffs two texts word by word, keeping the whitespace, so the
+// kept and removed chunks put together are `before` and the kept and added
+// ones `after`.
+func diffWords(before string, after string) []DiffChunk {
+	a := diffTokenPattern.FindAllString(before, -1)
+	b := diffTokenPattern.FindAllString(after, -1)
+
+	// only the middle, past any common start and end, needs diffing
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	chunks := []DiffChunk{}
+	add := func(op string, tokens ...string) {
+		for _, token := range tokens {
+			if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+				chunks[n-1].Text += token
+			} else {
+				chunks = append(chunks, DiffChunk{Op: op, Text: token})
+			}
+		}
+	}
+
+	add(DiffKept, a[:prefix]...)
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > MAX_DIFF_CELLS {
+		add(DiffRemoved, midA...)
+		add(DiffAdded, midB...)
+	} else {
+		// lcs[i][j] is the longest common subsequence of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) && j < len(midB) {
+			switch {
+			case midA[i] == midB[j]:
+				add(DiffKept, midA[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(DiffRemoved, midA[i])
+				i++
+			default:
+				add(DiffAdded, midB[j])
+				j++
+			}
+		}
+		add(DiffRemoved, midA[i:]...)
+		add(DiffAdded, midB[j:]...)
+	}
+	add(DiffKept, a[len(a)-suffix:]...)
+	return chunks
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRevisions(t *testing.T) {
+	t.Run("Texts are diffed word by word", func(t *testing.T) {
+		before := "If you wish to pray, renounce all things"
+		after := "If you truly wish to pray, renounce everything"
+		chunks := diffWords(before, after)
+
+		kept, removed, added := "", "", ""
+		var old, new strings.Builder
+		for _, chunk := range chunks {
+			switch chunk.Op {
+			case DiffKept:
+				kept += chunk.Text
+				old.WriteString(chunk.Text)
+				new.WriteString(chunk.Text)
+			case DiffRemoved:
+				removed += chunk.Text
+				old.WriteString(chunk.Text)
+			case DiffAdded:
+				added += chunk.Text
+				new.WriteString(chunk.Text)
+			}
+		}
+		if old.String() != before || new.String() != after {
+			t.Errorf("Expected the chunks to rebuild both texts, got %+v", chunks)
+		}
+		if strings.TrimSpace(removed) != "all things" || strings.TrimSpace(added) != "truly everything" {
+			t.Errorf("Unexpected changes -%q +%q", removed, added)
+		}
+		for i := 1; i < len(chunks); i++ {
+			if chunks[i].Op == chunks[i-1].Op {
+				t.Errorf("Expected neighbouring chunks to be merged, got %+v", chunks)
+			}
+		}
+	})
+
+	t.Run("Identical texts are kept whole", func(t *testing.T) {
+		chunks := diffWords("Pray without ceasing", "Pray without ceasing")
+		if len(chunks) != 1 || chunks[0].Op != DiffKept {
+			t.Errorf("Unexpected chunks %+v", chunks)
+		}
+		if chunks := diffWords("", "Pray"); len(chunks) != 1 || chunks[0].Op != DiffAdded {
+			t.Errorf("Unexpected chunks %+v", chunks)
+		}
+	})
+
+	t.Run("Only the edited fields are reported", func(t *testing.T) {
+		before := Meditation{Name: "On Prayer 1", Text: "If you wish to pray", URL: "a.mp3", Tags: []string{}}
+		after := before
+		after.Text = "If you wish to pray truly"
+		after.URL = "b.mp3"
+		after.Tags = nil
+		changes := meditationChanges(before, after)
+		fields := changedFields(changes)
+		if len(fields) != 2 || fields[0] != "text" || fields[1] != "audioUrl" {
+			t.Errorf("Unexpected changes %+v", changes)
+		}
+		if len(changes[0].Diff) != 2 || changes[1].Diff != nil {
+			t.Errorf("Expected only the text to be diffed, got %+v", changes)
+		}
+	})
+
+	t.Run("Step durations don't count as changes", func(t *testing.T) {
+		before := Sequence{Steps: []SequenceStep{{Type: StepMeditation, MeditationID: "m1"}}}
+		after := Sequence{Steps: []SequenceStep{{Type: StepMeditation, MeditationID: "m1", Duration: 42}}}
+		if changes := sequenceChanges(before, after); len(changes) != 0 {
+			t.Errorf("Expected no changes, got %+v", changes)
+		}
+		after.Steps = append(after.Steps, SequenceStep{Type: StepBell})
+		if fields := changedFields(sequenceChanges(before, after)); len(fields) != 1 || fields[0] != "steps" {
+			t.Errorf("Unexpected changes %v", fields)
+		}
+	})
+}
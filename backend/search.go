@@ -0,0 +1,309 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// a phrase can't match across the name and the body
+	SEARCH_FIELD_GAP = 1000
+	// Okapi BM25 parameters
+	BM25_K1 = 1.2
+	BM25_B  = 0.75
+	// how far either side of the first hit a snippet reaches, in bytes
+	SNIPPET_CONTEXT = 80
+)
+
+// searchStopwords are too common to be worth indexing. They still take up a
+// position, so "prayer of the heart" only matches those words in that order.
+var searchStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "of": true,
+	"on": true, "or": true, "so": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "was": true, "will": true, "with": true,
+}
+
+type searchToken struct {
+	Term     string // the stem, or "" for a stopword
+	Position int
+	Start    int // byte offsets of the word in the text
+	End      int
+}
+
+// tokenize splits text into lowercase, stemmed words. Anything that isn't a
+// letter or digit separates words, except an apostrophe inside one
+// ("heart's" is "heart").
+func tokenize(text string) []searchToken {
+	tokens := []searchToken{}
+	start := -1
+	emit := func(end int) {
+		word := strings.ToLower(text[start:end])
+		if i := strings.IndexAny(word, "'’"); i > 0 {
+			word = word[:i]
+		}
+		term := ""
+		if !searchStopwords[word] {
+			term = stem(word)
+		}
+		tokens = append(tokens, searchToken{Term: term, Position: len(tokens), Start: start, End: end})
+		start = -1
+	}
+	for i, r := range text {
+		wordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if !wordRune && start >= 0 && (r == '\'' || r == '’') {
+			// keep going if the apostrophe is followed by a letter
+			next, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+			wordRune = unicode.IsLetter(next)
+		}
+		if wordRune && start < 0 {
+			start = i
+		}
+		if !wordRune && start >= 0 {
+			emit(i)
+		}
+	}
+	if start >= 0 {
+		emit(len(text))
+	}
+	return tokens
+}
+
+// SearchDocument is the searchable text of a public meditation or sequence.
+// Private items have the zero document, so they're never indexed.
+type SearchDocument struct {
+	Name string
+	Body string // a meditation's text, or a sequence's description
+}
+
+func (d SearchDocument) empty() bool {
+	return d.Name == "" && d.Body == ""
+}
+
+func meditationSearchDocument(m Meditation) SearchDocument {
+	if !m.Public {
+		return SearchDocument{}
+	}
+	return SearchDocument{Name: m.Name, Body: m.Text}
+}
+
+func sequenceSearchDocument(s Sequence) SearchDocument {
+	if !s.Public {
+		return SearchDocument{}
+	}
+	return SearchDocument{Name: s.Name, Body: s.Description}
+}
+
+// SearchPosting records where one term appears in one document.
+type SearchPosting struct {
+	Positions []int `dynamodbav:"positions"`
+	NameHits  int   `dynamodbav:"nameHits"` // how many of the positions are in the name
+	Length    int   `dynamodbav:"length"`   // the document's length in words
+}
+
+func (p SearchPosting) equal(other SearchPosting) bool {
+	if p.NameHits != other.NameHits || p.Length != other.Length || len(p.Positions) != len(other.Positions) {
+		return false
+	}
+	for i := range p.Positions {
+		if p.Positions[i] != other.Positions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// indexDocument returns the postings of every term in the document, and its
+// length in words.
+func indexDocument(d SearchDocument) (map[string]SearchPosting, int) {
+	postings := make(map[string]SearchPosting)
+	nameTokens := tokenize(d.Name)
+	bodyTokens := tokenize(d.Body)
+	length := len(nameTokens) + len(bodyTokens)
+	add := func(tokens []searchToken, offset int, inName bool) {
+		for _, t := range tokens {
+			if t.Term == "" {
+				continue
+			}
+			p := postings[t.Term]
+			p.Positions = append(p.Positions, t.Position+offset)
+			if inName {
+				p.NameHits++
+			}
+			p.Length = length
+			postings[t.Term] = p
+		}
+	}
+	add(nameTokens, 0, true)
+	add(bodyTokens, len(nameTokens)+SEARCH_FIELD_GAP, false)
+	return postings, length
+}
+
+type queryTerm struct {
+	Term   string
+	Offset int // position relative to the start of its phrase
+}
+
+// SearchQuery is a parsed query: every clause must match. A clause is a
+// single word, or a quoted phrase whose words must appear in order.
+type SearchQuery struct {
+	Clauses [][]queryTerm
+}
+
+// parseSearchQuery parses `pure "prayer of the heart"` into the clauses
+// [pure] and [prayer heart+3]. Stopwords on their own are dropped, and an
+// unclosed quote runs to the end of the query.
+func parseSearchQuery(q string) SearchQuery {
+	query := SearchQuery{Clauses: [][]queryTerm{}}
+	parts := strings.Split(q, "\"")
+	for i, part := range parts {
+		tokens := tokenize(part)
+		if i%2 == 1 {
+			// inside quotes
+			clause := []queryTerm{}
+			start := 0
+			for _, t := range tokens {
+				if t.Term == "" {
+					continue
+				}
+				if len(clause) == 0 {
+					start = t.Position
+				}
+				clause = append(clause, queryTerm{Term: t.Term, Offset: t.Position - start})
+			}
+			if len(clause) > 0 {
+				query.Clauses = append(query.Clauses, clause)
+			}
+			continue
+		}
+		for _, t := range tokens {
+			if t.Term != "" {
+				query.Clauses = append(query.Clauses, []queryTerm{{Term: t.Term}})
+			}
+		}
+	}
+	return query
+}
+
+func (q SearchQuery) empty() bool {
+	return len(q.Clauses) == 0
+}
+
+// terms returns each distinct term in the query once.
+func (q SearchQuery) terms() []string {
+	seen := make(map[string]bool)
+	terms := []string{}
+	for _, clause := range q.Clauses {
+		for _, t := range clause {
+			if !seen[t.Term] {
+				seen[t.Term] = true
+				terms = append(terms, t.Term)
+			}
+		}
+	}
+	return terms
+}
+
+// matches reports whether a document, given its postings for the query's
+// terms, satisfies every clause.
+func (q SearchQuery) matches(postings map[string]SearchPosting) bool {
+	for _, clause := range q.Clauses {
+		first, ok := postings[clause[0].Term]
+		if !ok {
+			return false
+		}
+		found := false
+		for _, start := range first.Positions {
+			found = true
+			for _, t := range clause[1:] {
+				if !containsInt(postings[t.Term].Positions, start+t.Offset) {
+					found = false
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(sorted []int, n int) bool {
+	i := sort.SearchInts(sorted, n)
+	return i < len(sorted) && sorted[i] == n
+}
+
+// bm25 scores a matching document with Okapi BM25. A term in the name counts
+// twice. `documentFrequency` is how many documents contain each term,
+// `documents` and `averageLength` describe the whole index.
+func bm25(postings map[string]SearchPosting, documentFrequency map[string]int, documents int, averageLength float64) float64 {
+	if averageLength <= 0 {
+		averageLength = 1
+	}
+	score := 0.0
+	for term, p := range postings {
+		df := float64(documentFrequency[term])
+		idf := math.Log(1 + (float64(documents)-df+0.5)/(df+0.5))
+		tf := float64(len(p.Positions) + p.NameHits)
+		norm := BM25_K1 * (1 - BM25_B + BM25_B*float64(p.Length)/averageLength)
+		score += idf * tf * (BM25_K1 + 1) / (tf + norm)
+	}
+	return score
+}
+
+// searchSnippet returns the stretch of text around the first word matching
+// one of `terms`, or the start of the text if none does.
+func searchSnippet(text string, terms []string) string {
+	wanted := make(map[string]bool)
+	for _, t := range terms {
+		wanted[t] = true
+	}
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+	hit := 0
+	for i, t := range tokens {
+		if wanted[t.Term] {
+			hit = i
+			break
+		}
+	}
+
+	// widen to whole words either side of the hit
+	first := hit
+	for first > 0 && tokens[hit].Start-tokens[first-1].Start <= SNIPPET_CONTEXT {
+		first--
+	}
+	last := hit
+	for last < len(tokens)-1 && tokens[last+1].End-tokens[hit].End <= SNIPPET_CONTEXT {
+		last++
+	}
+
+	start := tokens[first].Start
+	if first == 0 {
+		start = 0
+	}
+	end := tokens[last].End
+	if last == len(tokens)-1 {
+		// keep the closing punctuation
+		end = len(strings.TrimRightFunc(text, unicode.IsSpace))
+	}
+	snippet := strings.TrimSpace(text[start:end])
+	if first > 0 {
+		snippet = "…" + snippet
+	}
+	if last < len(tokens)-1 {
+		snippet = snippet + "…"
+	}
+	return snippet
+}
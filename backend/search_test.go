@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("The Heart's prayer, (praying) 24/7!")
+	expected := []searchToken{
+		{Term: "", Position: 0, Start: 0, End: 3},
+		{Term: "heart", Position: 1, Start: 4, End: 11},
+		{Term: "prayer", Position: 2, Start: 12, End: 18},
+		{Term: "prai", Position: 3, Start: 21, End: 28},
+		{Term: "24", Position: 4, Start: 30, End: 32},
+		{Term: "7", Position: 5, Start: 33, End: 34},
+	}
+	if diff := deep.Equal(tokens, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	query := parseSearchQuery(`demons "the prayer of the heart" of`)
+	expected := SearchQuery{Clauses: [][]queryTerm{
+		{{Term: "demon"}},
+		{{Term: "prayer"}, {Term: "heart", Offset: 3}},
+	}}
+	if diff := deep.Equal(query, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(query.terms(), []string{"demon", "prayer", "heart"}); diff != nil {
+		t.Error(diff)
+	}
+	if !parseSearchQuery("the of and").empty() {
+		t.Error("a query of stopwords should be empty")
+	}
+}
+
+func TestSearchMatchesAndRanks(t *testing.T) {
+	docs := []SearchDocument{
+		{Name: "The Jesus Prayer", Body: "Lord Jesus Christ, have mercy on me. The prayer of the heart."},
+		{Name: "On Prayer", Body: "Prayer is the ascent of the mind to God. The heart prays."},
+		{Name: "Evening", Body: "Let us complete our evening prayer unto the Lord."},
+	}
+	index := []map[string]SearchPosting{}
+	total := 0
+	for _, d := range docs {
+		postings, length := indexDocument(d)
+		index = append(index, postings)
+		total += length
+	}
+
+	search := func(q string) []int {
+		query := parseSearchQuery(q)
+		matches := []int{}
+		for i, postings := range index {
+			found := make(map[string]SearchPosting)
+			for _, term := range query.terms() {
+				if p, ok := postings[term]; ok {
+					found[term] = p
+				}
+			}
+			if query.matches(found) {
+				matches = append(matches, i)
+			}
+		}
+		return matches
+	}
+
+	if diff := deep.Equal(search("prayers"), []int{0, 1, 2}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(search("prayer heart"), []int{0, 1}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(search(`"prayer of the heart"`), []int{0}); diff != nil {
+		t.Error(diff)
+	}
+	// the name and body don't run together
+	if diff := deep.Equal(search(`"jesus prayer lord"`), []int{}); diff != nil {
+		t.Error(diff)
+	}
+
+	// a hit in the name outranks one in the body
+	df := map[string]int{"prayer": 3}
+	average := float64(total) / float64(len(docs))
+	withName := bm25(map[string]SearchPosting{"prayer": index[1]["prayer"]}, df, len(docs), average)
+	bodyOnly := bm25(map[string]SearchPosting{"prayer": index[2]["prayer"]}, df, len(docs), average)
+	if withName <= bodyOnly {
+		t.Errorf("expected the name hit to rank higher: %f <= %f", withName, bodyOnly)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	text := "In the beginning was the Word, and the Word was with God, and the Word was God. " +
+		"The same was in the beginning with God. All things were made by him; and without him was " +
+		"not any thing made that was made. In him was life; and the life was the light of men."
+	snippet := searchSnippet(text, []string{"light"})
+	expected := "…him was not any thing made that was made. In him was life; and the life was the light of men."
+	if snippet != expected {
+		t.Errorf("expected %q, got %q", expected, snippet)
+	}
+	if snippet := searchSnippet("Be still.", []string{"heart"}); snippet != "Be still." {
+		t.Errorf("expected the start of the text, got %q", snippet)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A sequence is an ordered list of steps. Sequences saved before steps
+// existed only have their meditationIds, which read back as one "meditation"
+// step per id.
+
+const (
+	StepMeditation = "meditation"
+	StepSilence    = "silence"
+	StepBell       = "bell"
+	StepText       = "text"
+	StepRepeat     = "repeat"
+)
+
+// BELL_SECONDS is how long a bell strike is allowed to ring out.
+const BELL_SECONDS = 10
+
+// TEXT_WORDS_PER_MINUTE is the pace used to time a text-only step that
+// doesn't give its own length.
+const TEXT_WORDS_PER_MINUTE = 130
+
+// Limits keeping a sequence's running time, and the work of timing it,
+// bounded.
+const (
+	MAX_STEPS        = 200
+	MAX_REPEAT_TIMES = 100
+	MAX_STEP_SECONDS = 86400
+)
+
+type SequenceStep struct {
+	Type         string `json:"type" dynamodbav:"type"`
+	MeditationID string `json:"meditationId,omitempty" dynamodbav:"meditationId,omitempty"` // meditation and repeat
+	Seconds      int64  `json:"seconds,omitempty" dynamodbav:"seconds,omitempty"`           // silence, optionally text
+	Text         string `json:"text,omitempty" dynamodbav:"text,omitempty"`                 // text
+	Times        int    `json:"times,omitempty" dynamodbav:"times,omitempty"`               // repeat
+
+	Duration int64 `json:"durationSeconds" dynamodbav:"-"` // computed on read
+}
+
+var ErrInvalidStep = errors.New("invalid sequence step")
+
+func validateSteps(steps []SequenceStep) error {
+	if len(steps) > MAX_STEPS {
+		return fmt.Errorf("%w: a sequence can have at most %d steps", ErrInvalidStep, MAX_STEPS)
+	}
+	for i, step := range steps {
+		invalid := func(msg string) error {
+			return fmt.Errorf("%w %d: %s", ErrInvalidStep, i, msg)
+		}
+		switch step.Type {
+		case StepMeditation:
+			if step.MeditationID == "" {
+				return invalid("a meditation step needs a meditationId")
+			}
+		case StepRepeat:
+			if step.MeditationID == "" {
+				return invalid("a repeat step needs a meditationId")
+			}
+			if step.Times < 1 || step.Times > MAX_REPEAT_TIMES {
+				return invalid(fmt.Sprintf("a repeat step needs times between 1 and %d", MAX_REPEAT_TIMES))
+			}
+		case StepSilence:
+			if step.Seconds < 1 || step.Seconds > MAX_STEP_SECONDS {
+				return invalid(fmt.Sprintf("a silence step needs between 1 and %d seconds", MAX_STEP_SECONDS))
+			}
+		case StepText:
+			if strings.TrimSpace(step.Text) == "" {
+				return invalid("a text step needs text")
+			}
+			if step.Seconds < 0 || step.Seconds > MAX_STEP_SECONDS {
+				return invalid(fmt.Sprintf("seconds must be between 0 and %d", MAX_STEP_SECONDS))
+			}
+		case StepBell:
+		default:
+			return invalid("unknown type \"" + step.Type + "\"")
+		}
+	}
+	return nil
+}
+
+// stepMeditationIDs lists the meditation referenced by each step that has one,
+// in order.
+func stepMeditationIDs(steps []SequenceStep) []string {
+	ids := []string{}
+	for _, step := range steps {
+		if step.MeditationID != "" {
+			ids = append(ids, step.MeditationID)
+		}
+	}
+	return ids
+}
+
+func stepsFromMeditationIDs(ids []string) []SequenceStep {
+	steps := make([]SequenceStep, len(ids))
+	for i, id := range ids {
+		steps[i] = SequenceStep{Type: StepMeditation, MeditationID: id}
+	}
+	return steps
+}
+
+// isPlainSteps reports whether every step is just a meditation, i.e. the steps
+// can be written as a list of meditationIds.
+func isPlainSteps(steps []SequenceStep) bool {
+	for _, step := range steps {
+		if step.Type != StepMeditation {
+			return false
+		}
+	}
+	return true
+}
+
+// timeSteps fills in each step's Duration and returns the total running time
+// in seconds. Meditations missing from `meditations` count as zero.
+func timeSteps(steps []SequenceStep, meditations []Meditation) ([]SequenceStep, int64) {
+	durations := make(map[string]int64)
+	for _, m := range meditations {
+		durations[m.ID] = m.Duration
+	}
+
+	timed := make([]SequenceStep, len(steps))
+	total := int64(0)
+	for i, step := range steps {
+		switch step.Type {
+		case StepMeditation:
+			step.Duration = durations[step.MeditationID]
+		case StepRepeat:
+			step.Duration = durations[step.MeditationID] * int64(step.Times)
+		case StepSilence:
+			step.Duration = step.Seconds
+		case StepBell:
+			step.Duration = BELL_SECONDS
+		case StepText:
+			step.Duration = step.Seconds
+			if step.Duration == 0 {
+				words := int64(len(strings.Fields(step.Text)))
+				step.Duration = (words*60 + TEXT_WORDS_PER_MINUTE - 1) / TEXT_WORDS_PER_MINUTE
+			}
+		}
+		timed[i] = step
+		total += step.Duration
+	}
+	return timed, total
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSequenceSteps(t *testing.T) {
+	t.Run("Steps are timed", func(t *testing.T) {
+		meditations := []Meditation{{ID: "a", Duration: 40}, {ID: "b", Duration: 25}}
+		steps := []SequenceStep{
+			{Type: StepBell},
+			{Type: StepMeditation, MeditationID: "a"},
+			{Type: StepSilence, Seconds: 1200},
+			{Type: StepText, Text: "Let the words of my mouth and the meditation of my heart be acceptable in thy sight"},
+			{Type: StepText, Text: "Amen", Seconds: 5},
+			{Type: StepRepeat, MeditationID: "b", Times: 4},
+			{Type: StepMeditation, MeditationID: "missing"},
+		}
+		expected := []int64{BELL_SECONDS, 40, 1200, 8, 5, 100, 0}
+
+		timed, total := timeSteps(steps, meditations)
+		sum := int64(0)
+		for i, step := range timed {
+			if step.Duration != expected[i] {
+				t.Errorf("step %d: expected %d seconds, got %d", i, expected[i], step.Duration)
+			}
+			sum += expected[i]
+		}
+		if total != sum {
+			t.Errorf("Expected a total of %d seconds, got %d", sum, total)
+		}
+	})
+
+	t.Run("Invalid steps are rejected", func(t *testing.T) {
+		invalid := []SequenceStep{
+			{Type: StepMeditation},
+			{Type: StepRepeat, MeditationID: "a"},
+			{Type: StepSilence},
+			{Type: StepText, Text: "  "},
+			{Type: "chant"},
+			{Type: StepRepeat, MeditationID: "a", Times: MAX_REPEAT_TIMES + 1},
+			{Type: StepSilence, Seconds: MAX_STEP_SECONDS + 1},
+			{Type: StepText, Text: "Amen", Seconds: MAX_STEP_SECONDS + 1},
+		}
+		for _, step := range invalid {
+			if err := validateSteps([]SequenceStep{step}); !errors.Is(err, ErrInvalidStep) {
+				t.Errorf("Expected ErrInvalidStep for %+v, got %v", step, err)
+			}
+		}
+		valid := []SequenceStep{
+			{Type: StepBell},
+			{Type: StepRepeat, MeditationID: "a", Times: MAX_REPEAT_TIMES},
+			{Type: StepSilence, Seconds: MAX_STEP_SECONDS},
+		}
+		if err := validateSteps(valid); err != nil {
+			t.Error(err.Error())
+		}
+
+		tooMany := make([]SequenceStep, MAX_STEPS+1)
+		for i := range tooMany {
+			tooMany[i] = SequenceStep{Type: StepBell}
+		}
+		if err := validateSteps(tooMany); !errors.Is(err, ErrInvalidStep) {
+			t.Errorf("Expected ErrInvalidStep for %d steps, got %v", len(tooMany), err)
+		}
+		if err := validateSteps(tooMany[:MAX_STEPS]); err != nil {
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("Meditation ids come from the steps in order", func(t *testing.T) {
+		steps := []SequenceStep{
+			{Type: StepMeditation, MeditationID: "a"},
+			{Type: StepBell},
+			{Type: StepRepeat, MeditationID: "b", Times: 2},
+		}
+		ids := stepMeditationIDs(steps)
+		if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+			t.Errorf("Unexpected ids %v", ids)
+		}
+		if isPlainSteps(steps) || !isPlainSteps(stepsFromMeditationIDs(ids)) {
+			t.Error("isPlainSteps misclassified the steps")
+		}
+	})
+}
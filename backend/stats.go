@@ -0,0 +1,189 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	STATS_DAILY_DAYS   = 30
+	STATS_WEEKLY_WEEKS = 12
+	STATS_HEATMAP_DAYS = 365
+	STATS_TOP_COUNT    = 5
+)
+
+const dateLayout = "2006-01-02"
+
+type DayTotal struct {
+	Date     string `json:"date"` // YYYY-MM-DD in the user's time zone
+	Minutes  int    `json:"minutes"`
+	Sessions int    `json:"sessions"`
+}
+
+type WeekTotal struct {
+	WeekStart string `json:"weekStart"` // the Monday, YYYY-MM-DD
+	Minutes   int    `json:"minutes"`
+	Sessions  int    `json:"sessions"`
+}
+
+type SequenceUsage struct {
+	SequenceID string `json:"sequenceId"`
+	Name       string `json:"name,omitempty"`
+	Sessions   int    `json:"sessions"`
+	Minutes    int    `json:"minutes"`
+}
+
+type PracticeStats struct {
+	TimeZone      string          `json:"timeZone"`
+	TotalSessions int             `json:"totalSessions"`
+	TotalMinutes  int             `json:"totalMinutes"`
+	CurrentStreak int             `json:"currentStreakDays"`
+	LongestStreak int             `json:"longestStreakDays"`
+	Daily         []DayTotal      `json:"daily"`   // the last STATS_DAILY_DAYS days, oldest first
+	Weekly        []WeekTotal     `json:"weekly"`  // the last STATS_WEEKLY_WEEKS weeks, oldest first
+	Heatmap       []DayTotal      `json:"heatmap"` // only days with practice in the last year
+	TopSequences  []SequenceUsage `json:"topSequences"`
+}
+
+// countsTowardStreak reports whether a session should keep a streak going;
+// an abandoned sitting doesn't.
+func countsTowardStreak(s PracticeSession) bool {
+	return s.Status != SessionAbandoned
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// computeStats summarises `sessions` as seen from `now` in `loc`. Days and
+// weeks are calendar days and (Monday-based) weeks in `loc`.
+func computeStats(sessions []PracticeSession, loc *time.Location, now time.Time) PracticeStats {
+	now = now.In(loc)
+	today := startOfDay(now)
+
+	stats := PracticeStats{
+		TimeZone:     loc.String(),
+		Daily:        []DayTotal{},
+		Weekly:       []WeekTotal{},
+		Heatmap:      []DayTotal{},
+		TopSequences: []SequenceUsage{},
+	}
+
+	days := make(map[string]*DayTotal)
+	weeks := make(map[string]*WeekTotal)
+	practiced := make(map[string]bool)
+	usage := make(map[string]*SequenceUsage)
+	for _, s := range sessions {
+		started := s.StartedAt.In(loc)
+		day := started.Format(dateLayout)
+		week := startOfWeek(started).Format(dateLayout)
+
+		stats.TotalSessions++
+		stats.TotalMinutes += s.Minutes
+		if days[day] == nil {
+			days[day] = &DayTotal{Date: day}
+		}
+		days[day].Minutes += s.Minutes
+		days[day].Sessions++
+		if weeks[week] == nil {
+			weeks[week] = &WeekTotal{WeekStart: week}
+		}
+		weeks[week].Minutes += s.Minutes
+		weeks[week].Sessions++
+		if countsTowardStreak(s) {
+			practiced[day] = true
+		}
+		if s.SequenceID != "" {
+			if usage[s.SequenceID] == nil {
+				usage[s.SequenceID] = &SequenceUsage{SequenceID: s.SequenceID}
+			}
+			usage[s.SequenceID].Sessions++
+			usage[s.SequenceID].Minutes += s.Minutes
+		}
+	}
+
+	// 1) daily and weekly totals, including the empty ones
+	for i := STATS_DAILY_DAYS - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format(dateLayout)
+		total := DayTotal{Date: day}
+		if days[day] != nil {
+			total = *days[day]
+		}
+		stats.Daily = append(stats.Daily, total)
+	}
+	thisWeek := startOfWeek(today)
+	for i := STATS_WEEKLY_WEEKS - 1; i >= 0; i-- {
+		week := thisWeek.AddDate(0, 0, -7*i).Format(dateLayout)
+		total := WeekTotal{WeekStart: week}
+		if weeks[week] != nil {
+			total = *weeks[week]
+		}
+		stats.Weekly = append(stats.Weekly, total)
+	}
+
+	// 2) the heatmap only carries the days that have something in them
+	heatmapStart := today.AddDate(0, 0, -(STATS_HEATMAP_DAYS - 1)).Format(dateLayout)
+	for day, total := range days {
+		if day >= heatmapStart && day <= today.Format(dateLayout) {
+			stats.Heatmap = append(stats.Heatmap, *total)
+		}
+	}
+	sort.Slice(stats.Heatmap, func(i, j int) bool {
+		return stats.Heatmap[i].Date < stats.Heatmap[j].Date
+	})
+
+	// 3) streaks; today not having been sat yet doesn't break the current one
+	practicedDays := []string{}
+	for day := range practiced {
+		practicedDays = append(practicedDays, day)
+	}
+	sort.Strings(practicedDays)
+	run := 0
+	previous := ""
+	for _, day := range practicedDays {
+		t, _ := time.ParseInLocation(dateLayout, day, loc)
+		if previous != "" && t.AddDate(0, 0, -1).Format(dateLayout) == previous {
+			run++
+		} else {
+			run = 1
+		}
+		if run > stats.LongestStreak {
+			stats.LongestStreak = run
+		}
+		previous = day
+	}
+	streakDay := today
+	if !practiced[streakDay.Format(dateLayout)] {
+		streakDay = streakDay.AddDate(0, 0, -1)
+	}
+	for practiced[streakDay.Format(dateLayout)] {
+		stats.CurrentStreak++
+		streakDay = streakDay.AddDate(0, 0, -1)
+	}
+
+	// 4) most used sequences
+	for _, u := range usage {
+		stats.TopSequences = append(stats.TopSequences, *u)
+	}
+	sort.Slice(stats.TopSequences, func(i, j int) bool {
+		a, b := stats.TopSequences[i], stats.TopSequences[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		if a.Minutes != b.Minutes {
+			return a.Minutes > b.Minutes
+		}
+		return a.SequenceID < b.SequenceID
+	})
+	if len(stats.TopSequences) > STATS_TOP_COUNT {
+		stats.TopSequences = stats.TopSequences[:STATS_TOP_COUNT]
+	}
+
+	return stats
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	chicago, _ := time.LoadLocation("America/Chicago")
+	// 9am on Wednesday 10 March 2021 in Chicago
+	now := time.Date(2021, 3, 10, 9, 0, 0, 0, chicago)
+	sat := func(t time.Time, minutes int, status string, sequenceId string) PracticeSession {
+		return PracticeSession{StartedAt: t.UTC(), Minutes: minutes, Status: status, SequenceID: sequenceId}
+	}
+
+	sessions := []PracticeSession{
+		// 10:30pm in Chicago on the 9th is already the 10th in UTC
+		sat(time.Date(2021, 3, 9, 22, 30, 0, 0, chicago), 20, SessionCompleted, "a"),
+		sat(time.Date(2021, 3, 8, 6, 0, 0, 0, chicago), 20, SessionCompleted, "a"),
+		sat(time.Date(2021, 3, 8, 18, 0, 0, 0, chicago), 10, SessionPartial, "b"),
+		sat(time.Date(2021, 3, 7, 6, 0, 0, 0, chicago), 5, SessionAbandoned, "b"),
+		sat(time.Date(2021, 3, 1, 6, 0, 0, 0, chicago), 30, SessionCompleted, "a"),
+		sat(time.Date(2021, 3, 2, 6, 0, 0, 0, chicago), 30, SessionCompleted, ""),
+		sat(time.Date(2021, 3, 3, 6, 0, 0, 0, chicago), 30, SessionCompleted, ""),
+		sat(time.Date(2021, 3, 4, 6, 0, 0, 0, chicago), 30, SessionCompleted, ""),
+	}
+	stats := computeStats(sessions, chicago, now)
+
+	if stats.TotalSessions != 8 || stats.TotalMinutes != 175 {
+		t.Errorf("Unexpected totals: %d sessions, %d minutes", stats.TotalSessions, stats.TotalMinutes)
+	}
+	// the 8th and 9th count; today hasn't been sat yet, and the 7th was abandoned
+	if stats.CurrentStreak != 2 {
+		t.Errorf("Expected a current streak of %d, got %d", 2, stats.CurrentStreak)
+	}
+	if stats.LongestStreak != 4 {
+		t.Errorf("Expected a longest streak of %d, got %d", 4, stats.LongestStreak)
+	}
+
+	if len(stats.Daily) != STATS_DAILY_DAYS {
+		t.Errorf("Expected %d days, got %d", STATS_DAILY_DAYS, len(stats.Daily))
+	}
+	last := stats.Daily[len(stats.Daily)-1]
+	yesterday := stats.Daily[len(stats.Daily)-2]
+	if last.Date != "2021-03-10" || last.Minutes != 0 || yesterday.Date != "2021-03-09" || yesterday.Minutes != 20 {
+		t.Errorf("Unexpected daily totals %+v %+v", yesterday, last)
+	}
+
+	// Monday the 8th starts this week
+	thisWeek := stats.Weekly[len(stats.Weekly)-1]
+	if thisWeek.WeekStart != "2021-03-08" || thisWeek.Minutes != 50 || thisWeek.Sessions != 3 {
+		t.Errorf("Unexpected weekly total %+v", thisWeek)
+	}
+
+	if len(stats.Heatmap) != 7 || stats.Heatmap[0].Date != "2021-03-01" {
+		t.Errorf("Unexpected heatmap %+v", stats.Heatmap)
+	}
+
+	if len(stats.TopSequences) != 2 || stats.TopSequences[0].SequenceID != "a" || stats.TopSequences[0].Sessions != 3 {
+		t.Errorf("Unexpected top sequences %+v", stats.TopSequences)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"sort"
+)
+
+// stem reduces an English word to its stem with the Porter algorithm
+// (M.F. Porter, "An algorithm for suffix stripping", 1980), so "prayers",
+// "praying" and "prayed" are all indexed and searched as one term. `word`
+// must already be lowercase; words with anything but a-z are left alone.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	w := []byte(word)
+	w = stemStep1a(w)
+	w = stemStep1b(w)
+	w = stemStep1c(w)
+	w = replaceLongestSuffix(w, stemStep2Suffixes, 0)
+	w = replaceLongestSuffix(w, stemStep3Suffixes, 0)
+	w = stemStep4(w)
+	w = stemStep5(w)
+	return string(w)
+}
+
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, the m in
+// [C](VC){m}[V].
+func measure(w []byte) int {
+	m := 0
+	i := 0
+	for i < len(w) && isConsonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !isConsonant(w, i) {
+			i++
+		}
+		if i >= len(w) {
+			break
+		}
+		for i < len(w) && isConsonant(w, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+func containsVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDoubleConsonant(w []byte) bool {
+	l := len(w)
+	return l >= 2 && w[l-1] == w[l-2] && isConsonant(w, l-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant, where the last
+// consonant isn't w, x or y (e.g. -hop, -fil).
+func endsCVC(w []byte) bool {
+	l := len(w)
+	if l < 3 || !isConsonant(w, l-3) || isConsonant(w, l-2) || !isConsonant(w, l-1) {
+		return false
+	}
+	last := w[l-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+func withSuffix(stem []byte, suffix string) []byte {
+	return append(append([]byte{}, stem...), suffix...)
+}
+
+func stemStep1a(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+		return w
+	case hasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func stemStep1b(w []byte) []byte {
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+	var s []byte
+	switch {
+	case hasSuffix(w, "ed") && containsVowel(w[:len(w)-2]):
+		s = w[:len(w)-2]
+	case hasSuffix(w, "ing") && containsVowel(w[:len(w)-3]):
+		s = w[:len(w)-3]
+	default:
+		return w
+	}
+	switch {
+	case hasSuffix(s, "at"), hasSuffix(s, "bl"), hasSuffix(s, "iz"):
+		return withSuffix(s, "e")
+	case endsDoubleConsonant(s):
+		last := s[len(s)-1]
+		if last != 'l' && last != 's' && last != 'z' {
+			return s[:len(s)-1]
+		}
+	case measure(s) == 1 && endsCVC(s):
+		return withSuffix(s, "e")
+	}
+	return s
+}
+
+func stemStep1c(w []byte) []byte {
+	if hasSuffix(w, "y") && containsVowel(w[:len(w)-1]) {
+		return withSuffix(w[:len(w)-1], "i")
+	}
+	return w
+}
+
+var stemStep2Suffixes = map[string]string{
+	"ational": "ate", "tional": "tion", "enci": "ence", "anci": "ance",
+	"izer": "ize", "abli": "able", "alli": "al", "entli": "ent",
+	"eli": "e", "ousli": "ous", "ization": "ize", "ation": "ate",
+	"ator": "ate", "alism": "al", "iveness": "ive", "fulness": "ful",
+	"ousness": "ous", "aliti": "al", "iviti": "ive", "biliti": "ble",
+}
+
+var stemStep3Suffixes = map[string]string{
+	"icate": "ic", "ative": "", "alize": "al", "iciti": "ic",
+	"ical": "ic", "ful": "", "ness": "",
+}
+
+var stemStep4Suffixes = map[string]string{
+	"al": "", "ance": "", "ence": "", "er": "", "ic": "", "able": "",
+	"ible": "", "ant": "", "ement": "", "ment": "", "ent": "", "ion": "",
+	"ou": "", "ism": "", "ate": "", "iti": "", "ous": "", "ive": "", "ize": "",
+}
+
+// longestSuffix returns the longest of `suffixes` that w ends with.
+func longestSuffix(w []byte, suffixes map[string]string) (string, bool) {
+	keys := make([]string, 0, len(suffixes))
+	for k := range suffixes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+	for _, k := range keys {
+		if hasSuffix(w, k) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// replaceLongestSuffix swaps the longest matching suffix for its
+// replacement, provided the stem left behind has a measure above `minMeasure`.
+// As in the original algorithm, a shorter suffix isn't tried if the longest
+// one fails the measure.
+func replaceLongestSuffix(w []byte, suffixes map[string]string, minMeasure int) []byte {
+	suffix, ok := longestSuffix(w, suffixes)
+	if !ok {
+		return w
+	}
+	s := w[:len(w)-len(suffix)]
+	if measure(s) <= minMeasure {
+		return w
+	}
+	return withSuffix(s, suffixes[suffix])
+}
+
+func stemStep4(w []byte) []byte {
+	suffix, ok := longestSuffix(w, stemStep4Suffixes)
+	if !ok {
+		return w
+	}
+	s := w[:len(w)-len(suffix)]
+	if measure(s) <= 1 {
+		return w
+	}
+	if suffix == "ion" && !hasSuffix(s, "s") && !hasSuffix(s, "t") {
+		return w
+	}
+	return s
+}
+
+func stemStep5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		s := w[:len(w)-1]
+		m := measure(s)
+		if m > 1 || (m == 1 && !endsCVC(s)) {
+			w = s
+		}
+	}
+	if measure(w) > 1 && endsDoubleConsonant(w) && hasSuffix(w, "l") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
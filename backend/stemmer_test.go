@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	// examples from Porter's paper, plus a few from our own content
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"hopefulness":    "hope",
+		"adjustment":     "adjust",
+		"controlling":    "control",
+		"rate":           "rate",
+		"prayers":        "prayer",
+		"praying":        "prai",
+		"prayed":         "prai",
+		"demons":         "demon",
+		"contemplation":  "contempl",
+		"contemplative":  "contempl",
+		"is":             "is",
+		"théologie":      "théologie",
+	}
+	for word, expected := range cases {
+		if actual := stem(word); actual != expected {
+			t.Errorf("stem(%q): expected %q, got %q", word, expected, actual)
+		}
+	}
+}
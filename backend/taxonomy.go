@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	MAX_TAGS       = 10
+	MAX_TAG_LENGTH = 32
+)
+
+// Categories is the curated list an item may be filed under, unlike tags
+// which are free-form.
+var Categories = []string{
+	"scripture",
+	"patristic",
+	"liturgy",
+	"prayer",
+	"lectio",
+	"silence",
+	"poetry",
+	"contemplative",
+}
+
+var ErrInvalidTaxonomy = errors.New("invalid tags or categories")
+
+// normalizeTag lowercases a tag and turns any run of spaces or punctuation
+// into a single "-", so "Desert Fathers" and "desert-fathers" are the same
+// tag.
+func normalizeTag(tag string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(tag)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// normalizeTaxonomy normalizes and dedups the tags and checks the categories
+// against the curated list.
+func normalizeTaxonomy(tags []string, categories []string) ([]string, []string, error) {
+	normalizedTags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		t := normalizeTag(tag)
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > MAX_TAG_LENGTH {
+			return nil, nil, fmt.Errorf("%w: tag %q is too long", ErrInvalidTaxonomy, t)
+		}
+		seen[t] = true
+		normalizedTags = append(normalizedTags, t)
+	}
+	if len(normalizedTags) > MAX_TAGS {
+		return nil, nil, fmt.Errorf("%w: no more than %d tags", ErrInvalidTaxonomy, MAX_TAGS)
+	}
+
+	curated := make(map[string]bool)
+	for _, c := range Categories {
+		curated[c] = true
+	}
+	normalizedCategories := []string{}
+	seen = make(map[string]bool)
+	for _, category := range categories {
+		c := strings.ToLower(strings.TrimSpace(category))
+		if !curated[c] {
+			return nil, nil, fmt.Errorf("%w: unknown category %q", ErrInvalidTaxonomy, category)
+		}
+		if !seen[c] {
+			seen[c] = true
+			normalizedCategories = append(normalizedCategories, c)
+		}
+	}
+	return normalizedTags, normalizedCategories, nil
+}
+
+// taxonomyIndexKeys lists the index partitions an item belongs in. Only
+// public items are indexed.
+func taxonomyIndexKeys(public bool, tags []string, categories []string) []string {
+	keys := []string{}
+	if !public {
+		return keys
+	}
+	for _, t := range tags {
+		keys = append(keys, "tag#"+t)
+	}
+	for _, c := range categories {
+		keys = append(keys, "cat#"+c)
+	}
+	return keys
+}
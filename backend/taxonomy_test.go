@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTaxonomy(t *testing.T) {
+	t.Run("Tags are normalized and dedupped", func(t *testing.T) {
+		tags, categories, err := normalizeTaxonomy(
+			[]string{"Desert Fathers", "desert-fathers", "  Evagrius! ", "", "???"},
+			[]string{"Patristic", "patristic"},
+		)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(tags) != 2 || tags[0] != "desert-fathers" || tags[1] != "evagrius" {
+			t.Errorf("Unexpected tags %v", tags)
+		}
+		if len(categories) != 1 || categories[0] != "patristic" {
+			t.Errorf("Unexpected categories %v", categories)
+		}
+	})
+
+	t.Run("Unknown categories and too many tags are rejected", func(t *testing.T) {
+		_, _, err := normalizeTaxonomy([]string{}, []string{"astrology"})
+		if err == nil {
+			t.Error("Expected an unknown category to be rejected")
+		}
+		tags := []string{}
+		for i := 0; i <= MAX_TAGS; i++ {
+			tags = append(tags, string(rune('a'+i)))
+		}
+		_, _, err = normalizeTaxonomy(tags, []string{})
+		if err == nil {
+			t.Error("Expected too many tags to be rejected")
+		}
+	})
+
+	t.Run("Only public items are indexed", func(t *testing.T) {
+		if keys := taxonomyIndexKeys(false, []string{"a"}, []string{"lectio"}); len(keys) != 0 {
+			t.Errorf("Expected no keys for a private item, got %v", keys)
+		}
+		keys := taxonomyIndexKeys(true, []string{"a"}, []string{"lectio"})
+		if len(keys) != 2 || keys[0] != "tag#a" || keys[1] != "cat#lectio" {
+			t.Errorf("Unexpected keys %v", keys)
+		}
+	})
+}
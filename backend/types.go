@@ -13,11 +13,27 @@ type Meditation struct {
 	UserId    string    `json:"_userId"`
 	CreatedAt time.Time `json:"_createdAt"`
 	UpdatedAt time.Time `json:"_updatedAt"`
-
-	URL    string `json:"audioUrl"`
-	Name   string `json:"name"`
-	Text   string `json:"text"`
-	Public bool   `json:"isPublic"`
+	Version   int64     `json:"_version" dynamodbav:"-"` // stored on the record
+
+	URL       string     `json:"audioUrl"`
+	Duration  int64      `json:"durationSeconds"` // length of the audio
+	Name      string     `json:"name"`
+	Text      string     `json:"text"`
+	Public    bool       `json:"isPublic"`            // while published
+	Status    string     `json:"status"`              // see publication.go
+	PublishAt *time.Time `json:"publishAt,omitempty"` // while scheduled
+	// see moderation.go
+	Moderation     string     `json:"moderation,omitempty"`
+	ModerationNote string     `json:"moderationNote,omitempty"` // why it was rejected
+	ModeratedAt    *time.Time `json:"moderatedAt,omitempty"`
+	AuthorName     string     `json:"authorName,omitempty"` // for attribution in other users' sequences
+	Tags           []string   `json:"tags,omitempty"`
+	Categories     []string   `json:"categories,omitempty"`
+	Citation       *Citation  `json:"citation,omitempty"` // where the text comes from
+
+	// set when the meditation is in someone else's sequence and its author
+	// has since made it private or deleted it
+	Unavailable bool `json:"unavailable,omitempty" dynamodbav:"-"`
 }
 
 type Sequence struct {
@@ -25,12 +41,50 @@ type Sequence struct {
 	UserId    string    `json:"_userId"`
 	CreatedAt time.Time `json:"_createdAt"`
 	UpdatedAt time.Time `json:"_updatedAt"`
+	Version   int64     `json:"_version" dynamodbav:"-"` // stored on the record
+
+	ImageURL    string     `json:"imageUrl"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Public      bool       `json:"isPublic"`            // while published
+	Status      string     `json:"status"`              // see publication.go
+	PublishAt   *time.Time `json:"publishAt,omitempty"` // while scheduled
+	// see moderation.go
+	Moderation     string       `json:"moderation,omitempty"`
+	ModerationNote string       `json:"moderationNote,omitempty"` // why it was rejected
+	ModeratedAt    *time.Time   `json:"moderatedAt,omitempty"`
+	Meditations    []Meditation `json:"meditations,omitempty" dynamodbav:"-"` // stored as a list of strings instead
+
+	Steps         []SequenceStep `json:"steps,omitempty" dynamodbav:"-"` // stored on the SequenceDAO
+	TotalDuration int64          `json:"totalDurationSeconds" dynamodbav:"-"`
+
+	Tags       []string `json:"tags,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+
+	ForkedFrom string `json:"forkedFrom,omitempty"`     // the public sequence this was copied from
+	ForkCount  int    `json:"forkCount" dynamodbav:"-"` // stored on the record
+}
+
+const (
+	SessionCompleted = "completed"
+	SessionPartial   = "partial"
+	SessionAbandoned = "abandoned"
+)
 
-	ImageURL    string       `json:"imageUrl"`
-	Name        string       `json:"name"`
-	Description string       `json:"description"`
-	Public      bool         `json:"isPublic"`
-	Meditations []Meditation `json:"meditations,omitempty" dynamodbav:"-"` // stored as a list of strings instead
+// PracticeSession records one sitting with a sequence or meditation. It is
+// only ever visible to the user who sat it.
+type PracticeSession struct {
+	ID        string    `json:"_id"`
+	UserId    string    `json:"_userId"`
+	CreatedAt time.Time `json:"_createdAt"`
+	UpdatedAt time.Time `json:"_updatedAt"`
+
+	SequenceID   string    `json:"sequenceId,omitempty"`
+	MeditationID string    `json:"meditationId,omitempty"`
+	StartedAt    time.Time `json:"startedAt"`
+	Minutes      int       `json:"minutes"`
+	Status       string    `json:"status"`
+	Note         string    `json:"note,omitempty"` // private journal entry
 }
 
 type CreateMeditationInput struct {
@@ -38,6 +92,12 @@ type CreateMeditationInput struct {
 	Name      string `json:"name" validate:"required"`
 	Text      string `json:"text" validate:"required"`
 	Public    bool   `json:"isPublic"`
+	// Status and PublishAt take precedence over isPublic, see nextPublication
+	Status     string     `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published unpublished"`
+	PublishAt  *time.Time `json:"publishAt,omitempty"`
+	Tags       []string   `json:"tags"`
+	Categories []string   `json:"categories"`
+	Citation   *Citation  `json:"citation"`
 }
 
 type UpdateMeditationInput struct {
@@ -45,22 +105,79 @@ type UpdateMeditationInput struct {
 	Name      string `json:"name" validate:"required"`
 	Text      string `json:"text" validate:"required"`
 	Public    bool   `json:"isPublic"`
+	// Status and PublishAt take precedence over isPublic, see nextPublication
+	Status     string     `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published unpublished"`
+	PublishAt  *time.Time `json:"publishAt,omitempty"`
+	Tags       []string   `json:"tags"`
+	Categories []string   `json:"categories"`
+	Citation   *Citation  `json:"citation"`
 }
 
 type CreateSequenceInput struct {
-	UploadKey     string   `json:"uploadKey" validate:"required,uploadKey"`
-	Name          string   `json:"name" validate:"required,excludes=<>"`
-	Description   string   `json:"description" validate:"required"`
-	Public        bool     `json:"isPublic"`
-	MeditationIDs []string `json:"meditationIds"`
+	UploadKey   string `json:"uploadKey" validate:"required,uploadKey"`
+	Name        string `json:"name" validate:"required,excludes=<>"`
+	Description string `json:"description" validate:"required"`
+	Public      bool   `json:"isPublic"`
+	// Status and PublishAt take precedence over isPublic, see nextPublication
+	Status        string     `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published unpublished"`
+	PublishAt     *time.Time `json:"publishAt,omitempty"`
+	MeditationIDs []string   `json:"meditationIds"`
+	// Steps replaces meditationIds when a sequence needs more than meditations
+	Steps      []SequenceStep `json:"steps,omitempty"`
+	Tags       []string       `json:"tags"`
+	Categories []string       `json:"categories"`
 }
 
 type UpdateSequenceInput struct {
-	UploadKey     string   `json:"uploadKey" validate:"uploadKey"`
-	Name          string   `json:"name" validate:"required,excludes=<>"`
-	Description   string   `json:"description" validate:"required"`
-	Public        bool     `json:"isPublic"`
-	MeditationIDs []string `json:"meditationIds"`
+	UploadKey   string `json:"uploadKey" validate:"uploadKey"`
+	Name        string `json:"name" validate:"required,excludes=<>"`
+	Description string `json:"description" validate:"required"`
+	Public      bool   `json:"isPublic"`
+	// Status and PublishAt take precedence over isPublic, see nextPublication
+	Status        string     `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published unpublished"`
+	PublishAt     *time.Time `json:"publishAt,omitempty"`
+	MeditationIDs []string   `json:"meditationIds"`
+	// Steps replaces meditationIds when a sequence needs more than meditations
+	Steps      []SequenceStep `json:"steps,omitempty"`
+	Tags       []string       `json:"tags"`
+	Categories []string       `json:"categories"`
+}
+
+type InsertSequenceItemInput struct {
+	// either a meditation, or any other kind of step
+	MeditationID string        `json:"meditationId" validate:"required_without=Step"`
+	Step         *SequenceStep `json:"step"`
+	// Position defaults to the end of the sequence
+	Position *int `json:"position"`
+}
+
+type MoveSequenceItemInput struct {
+	From *int `json:"from" validate:"required"`
+	To   *int `json:"to" validate:"required"`
+}
+
+type CreateSessionInput struct {
+	SequenceID   string    `json:"sequenceId" validate:"required_without=MeditationID,excluded_with=MeditationID"`
+	MeditationID string    `json:"meditationId"`
+	StartedAt    time.Time `json:"startedAt" validate:"required"`
+	Minutes      int       `json:"minutes" validate:"min=0,max=1440"`
+	Status       string    `json:"status" validate:"required,oneof=completed partial abandoned"`
+	Note         string    `json:"note" validate:"max=20000"`
+}
+
+type UpdateSessionInput struct {
+	Minutes int    `json:"minutes" validate:"min=0,max=1440"`
+	Status  string `json:"status" validate:"required,oneof=completed partial abandoned"`
+	Note    string `json:"note" validate:"max=20000"`
+}
+
+type ReportInput struct {
+	Reason  string `json:"reason" validate:"required,oneof=spam abuse copyright inappropriate other"`
+	Details string `json:"details" validate:"max=1000"`
+}
+
+type ModerationInput struct {
+	Reason string `json:"reason" validate:"max=1000"` // MAX_MODERATION_REASON, required to reject
 }
 
 func uploadKeyValidator(fl validator.FieldLevel) bool {
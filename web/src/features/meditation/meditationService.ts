@@ -8,6 +8,7 @@ export type Meditation = {
   _id: string;
   _updatedAt: number;
   _userId: string;
+  _version: number;
   audioUrl: string;
   isPublic: boolean;
   name: string;
@@ -19,6 +20,7 @@ export type MeditationDTO = {
   _id: string;
   _updatedAt: string;
   _userId: string;
+  _version: number;
   audioUrl: string;
   isPublic: boolean;
   name: string;
@@ -129,6 +131,7 @@ export const createMeditation = async (
 
 export const updateMeditation = async (
 	input: UpdateMeditationInput,
+	version: number,
 	token: IdToken
   ) => {
 	const {_id, ...body} =  input
@@ -138,6 +141,7 @@ export const updateMeditation = async (
 	  body: JSON.stringify(body),
 	  headers: {
 		Authorization: token.__raw,
+		"If-Match": `"${version}"`,
 	  },
 	});
 
@@ -150,12 +154,14 @@ export const updateMeditation = async (
 
 export const deleteMeditationById = async (
   meditationId: string,
+  version: number,
   token: IdToken
 ) => {
   await fetch(`${base}/meditations/${meditationId}`, {
     method: "DELETE",
     headers: {
       Authorization: token.__raw,
+      "If-Match": `"${version}"`,
     },
   });
 };
@@ -133,8 +133,9 @@ export const updateMeditationThunk = (
   dispatch,
   getState
 ): Promise<Meditation> => {
-    const newMeditation = await updateMeditation(meditation, idToken);
     const existingMeditations = getState().meditation.private.meditations;
+    const version = existingMeditations.find((m) => m._id === meditation._id)?._version ?? 0
+    const newMeditation = await updateMeditation(meditation, version, idToken);
     const i = existingMeditations.findIndex((m) => m._id === meditation._id)
 
     if (i >= 0) {
@@ -154,8 +155,9 @@ export const deleteMeditationThunk = (
   dispatch,
   getState
 ): Promise<void> => {
-    await deleteMeditationById(meditationId, idToken);
     const existingPrivateMeditations = getState().meditation.private.meditations;
+    const version = existingPrivateMeditations.find((m) => m._id === meditationId)?._version ?? 0
+    await deleteMeditationById(meditationId, version, idToken);
     const existingPublicMeditations = getState().meditation.public.meditations;
 
     const newPrivateMeditations = existingPrivateMeditations.filter((m) => m._id !== meditationId)
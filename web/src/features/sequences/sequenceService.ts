@@ -9,6 +9,7 @@ export type Sequence = {
   _id: string;
   _updatedAt: number;
   _userId: string;
+  _version: number;
   imageUrl: string;
   isPublic: boolean;
   name: string;
@@ -21,6 +22,7 @@ export type SequenceDTO = {
   _id: string;
   _updatedAt: string;
   _userId: string;
+  _version: number;
   imageUrl: string;
   isPublic: boolean;
   name: string;
@@ -120,6 +122,7 @@ export const createSequence = async (
 
 export const updateSequence = async (
   input: UpdateSequenceInput,
+  version: number,
   token: IdToken
 ) => {
   const { _id, ...body } = input;
@@ -129,6 +132,7 @@ export const updateSequence = async (
     body: JSON.stringify(body),
     headers: {
       Authorization: token.__raw,
+      "If-Match": `"${version}"`,
     },
   });
 
@@ -141,12 +145,14 @@ export const updateSequence = async (
 
 export const deleteSequenceById = async (
   sequenceId: string,
+  version: number,
   token: IdToken
 ) => {
   await fetch(`${base}/sequences/${sequenceId}`, {
     method: "DELETE",
     headers: {
       Authorization: token.__raw,
+      "If-Match": `"${version}"`,
     },
   });
 };
@@ -106,8 +106,9 @@ export const updateSequenceThunk = (
   dispatch,
   getState
 ): Promise<Sequence> => {
-    const newSequence = await updateSequence(sequence, idToken);
     const existingSequences = getState().sequence.private.sequences;
+    const version = existingSequences.find((s) => s._id === sequence._id)?._version ?? 0
+    const newSequence = await updateSequence(sequence, version, idToken);
     const i = existingSequences.findIndex((m) => m._id === sequence._id)
 
     if (i >= 0) {
@@ -127,8 +128,9 @@ export const deleteSequenceThunk = (
   dispatch,
   getState
 ): Promise<void> => {
-    await deleteSequenceById(sequenceId, idToken);
     const existingPrivateSequences = getState().sequence.private.sequences;
+    const version = existingPrivateSequences.find((s) => s._id === sequenceId)?._version ?? 0
+    await deleteSequenceById(sequenceId, version, idToken);
     const existingPublicSequences = getState().sequence.public.sequences;
 
     const newPrivateSequences = existingPrivateSequences.filter((s) => s._id !== sequenceId)